@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sametyildirim314/insider_case/controllers"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// runCommand runs the command named on the command line instead of the API
+// server. It returns false when there is no command to run.
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "backtest":
+		runBacktest(args[1:])
+	default:
+		log.Fatalf("Unknown command %q; the only command is backtest", args[0])
+	}
+	return true
+}
+
+// runBacktest scores the prediction model against the completed league
+// seasons and prints the report, as a summary or as JSON
+func runBacktest(args []string) {
+	flags := flag.NewFlagSet("backtest", flag.ExitOnError)
+	seasonID := flags.Int("season", 0, "ID of the season to backtest (default every completed league season)")
+	simulations := flags.Int("simulations", 0, "simulated seasons per forecast (default PREDICTION_SIMULATIONS)")
+	bins := flags.Int("bins", 10, "number of ranges in the calibration table")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "seed of the simulations")
+	asJSON := flags.Bool("json", false, "print the full report as JSON")
+	flags.Parse(args)
+
+	report, err := controllers.RunBacktest(*seasonID, *simulations, *seed, *bins)
+	if err != nil {
+		log.Fatalf("Failed to backtest predictions: %v", err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Failed to write report: %v", err)
+		}
+		return
+	}
+
+	printBacktest(report)
+}
+
+// printBacktest writes a backtest report as tables: the scores of every
+// season, then the calibration table
+func printBacktest(report models.BacktestReport) {
+	fmt.Printf("Model %s, %d simulations per forecast, seed %d\n\n", report.ModelVersion, report.Simulations, report.Seed)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Season\tChampion\tForecasts\tBrier\tLog loss\t")
+	for _, season := range report.Seasons {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.4f\t%.4f\t\n",
+			season.Season.Name, season.Champion.Name, len(season.Weeks), season.BrierScore, season.LogLoss)
+	}
+	fmt.Fprintf(w, "All\t\t%d\t%.4f\t%.4f\t\n", report.Forecasts, report.BrierScore, report.LogLoss)
+	w.Flush()
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "Probability\tForecasts\tMean\tObserved\t")
+	for _, bin := range report.Calibration {
+		fmt.Fprintf(w, "%.2f-%.2f\t%d\t%.4f\t%.4f\t\n",
+			bin.Lower, bin.Upper, bin.Forecasts, bin.MeanProbability, bin.ObservedFrequency)
+	}
+	w.Flush()
+}
@@ -13,6 +13,38 @@ type Config struct {
 	DBUser   string
 	DBPass   string
 	DBName   string
+
+	// Match engine settings
+	AverageGoals      float64
+	HomeAdvantage     float64
+	PenaltyConversion float64
+
+	// MatchEngine is the engine competitions play with unless they pick their own
+	MatchEngine string
+
+	// Prediction engine settings
+	PredictionSimulations int
+
+	// Form and fatigue settings: how many recent results make up a team's form,
+	// how much form changes its strength and how much each match in another
+	// competition since its last one tires it (0 turns fatigue off)
+	FormMatches   int
+	FormEffect    float64
+	FatigueEffect float64
+
+	// Availability settings: injuries per team and match, the longest an
+	// injury lasts in weeks and the share of its strength a team loses for
+	// each missing first-choice player
+	InjuriesPerMatch float64
+	InjuryMaxWeeks   int
+	AbsenceEffect    float64
+
+	// Elo rating settings
+	EloKFactor       float64
+	EloHomeAdvantage float64
+
+	// Live simulation settings: how long one match minute lasts on the live clock
+	LiveMinuteMillis int
 }
 
 
@@ -24,6 +56,26 @@ func GetConfig() *Config {
 		DBUser:   getEnv("DB_USER", "postgres"),
 		DBPass:   getEnv("DB_PASS", "postgres"),
 		DBName:   getEnv("DB_NAME", "premier_league"),
+
+		AverageGoals:      getEnvFloat("AVERAGE_GOALS", 1.35),
+		HomeAdvantage:     getEnvFloat("HOME_ADVANTAGE", 1.20),
+		PenaltyConversion: getEnvFloat("PENALTY_CONVERSION", 0.75),
+		MatchEngine:       getEnv("MATCH_ENGINE", "poisson"),
+
+		PredictionSimulations: getEnvInt("PREDICTION_SIMULATIONS", 10000),
+
+		FormMatches:   getEnvInt("FORM_MATCHES", 5),
+		FormEffect:    getEnvFloat("FORM_EFFECT", 0.05),
+		FatigueEffect: getEnvFloat("FATIGUE_EFFECT", 0),
+
+		InjuriesPerMatch: getEnvFloat("INJURIES_PER_MATCH", 0.1),
+		InjuryMaxWeeks:   getEnvInt("INJURY_MAX_WEEKS", 4),
+		AbsenceEffect:    getEnvFloat("ABSENCE_EFFECT", 0.04),
+
+		EloKFactor:       getEnvFloat("ELO_K_FACTOR", 20),
+		EloHomeAdvantage: getEnvFloat("ELO_HOME_ADVANTAGE", 60),
+
+		LiveMinuteMillis: getEnvInt("LIVE_MINUTE_MS", 250),
 	}
 	
 
@@ -43,4 +95,20 @@ func getEnv(key, defaultValue string) string {
 		return defaultValue
 	}
 	return value
-} 
\ No newline at end of file
+} 
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,345 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// yellowCardsForBan is the number of yellow cards in a season after which a
+// player misses the team's next match, again at every multiple of it. A red
+// card always costs the next match.
+const yellowCardsForBan = 5
+
+// minAvailability is the lowest share of its strength a team keeps however
+// many players it is without
+const minAvailability = 0.5
+
+// availabilityTracker follows the injuries and suspensions of a season while
+// its matches are played, so a player hurt or sent off misses the matches
+// that follow in the same run
+type availabilityTracker struct {
+	seasonID int
+	cfg      *config.Config
+	squads   map[int][]models.Player
+
+	absences []models.Absence
+	// yellowCards counts each player's yellow cards in the season
+	yellowCards map[int]int
+}
+
+// loadAvailability reads the absences and yellow cards of a season so far
+func loadAvailability(q querier, seasonID int, squads map[int][]models.Player, cfg *config.Config) (*availabilityTracker, error) {
+	absences, err := loadAbsences(q, seasonID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load absences: %v", err)
+	}
+
+	rows, err := q.Query(`
+		SELECT e.player_id, COUNT(*)
+		FROM match_events e
+		JOIN matches m ON e.match_id = m.id
+		WHERE m.season_id = $1 AND e.event_type = $2 AND e.player_id IS NOT NULL
+		GROUP BY e.player_id
+	`, seasonID, models.EventYellowCard)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load yellow cards: %v", err)
+	}
+	defer rows.Close()
+
+	yellowCards := make(map[int]int)
+	for rows.Next() {
+		var playerID, count int
+		if err := rows.Scan(&playerID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan yellow cards: %v", err)
+		}
+		yellowCards[playerID] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to load yellow cards: %v", err)
+	}
+
+	return &availabilityTracker{
+		seasonID:    seasonID,
+		cfg:         cfg,
+		squads:      squads,
+		absences:    absences,
+		yellowCards: yellowCards,
+	}, nil
+}
+
+// absent returns the absences that keep players out of a team's match in a week
+func (a *availabilityTracker) absent(teamID, week int) []models.Absence {
+	return absencesInWeek(a.absences, teamID, week)
+}
+
+// strength lowers a team's base strength by the absences it has in a week
+func (a *availabilityTracker) strength(base teamStrength, teamID, week int) teamStrength {
+	factor := 1 - totalStrengthLoss(a.absent(teamID, week))
+	factor = math.Max(minAvailability, factor)
+	return base.scaled(factor)
+}
+
+// matchSquads returns the squads of the given teams without the players who
+// are injured or suspended in a week, for the match engine to pick lineups from
+func (a *availabilityTracker) matchSquads(week int, teamIDs ...int) map[int][]models.Player {
+	squads := make(map[int][]models.Player, len(teamIDs))
+	for _, teamID := range teamIDs {
+		out := make(map[int]bool)
+		for _, absence := range a.absent(teamID, week) {
+			if absence.Player != nil {
+				out[absence.Player.ID] = true
+			}
+		}
+
+		var available []models.Player
+		for _, player := range a.squads[teamID] {
+			if !out[player.ID] {
+				available = append(available, player)
+			}
+		}
+		squads[teamID] = available
+	}
+	return squads
+}
+
+// record creates the injuries and suspensions a just-played match caused and
+// stores them. Red cards and every yellowCardsForBan-th yellow card suspend a
+// player for the team's next match; injuries are drawn among the players who
+// took part and last from one to INJURY_MAX_WEEKS weeks. Teams without a squad
+// lose a share of their strength instead of a player.
+func (a *availabilityTracker) record(tx *sql.Tx, rng *rand.Rand, matchID, week, homeTeamID, awayTeamID int, timeline matchTimeline) error {
+	squads := a.matchSquads(week, homeTeamID, awayTeamID)
+
+	for _, teamID := range []int{homeTeamID, awayTeamID} {
+		nextWeek, err := nextMatchWeek(tx, a.seasonID, teamID, week)
+		if err != nil {
+			return err
+		}
+
+		// Players who took part: the lineup and those who came on
+		squad := squads[teamID]
+		starters := len(squad)
+		if starters > startingPlayers {
+			starters = startingPlayers
+		}
+		tookPart := append([]models.Player{}, squad[:starters]...)
+
+		for _, event := range timeline.Events {
+			if event.TeamID == nil || *event.TeamID != teamID {
+				continue
+			}
+
+			switch event.Type {
+			case models.EventSubstitution:
+				if player, ok := a.player(teamID, event.RelatedPlayerID); ok {
+					tookPart = append(tookPart, player)
+				}
+
+			case models.EventRedCard:
+				absence := models.Absence{
+					Reason:   models.AbsenceSuspension,
+					Detail:   "Sent off in week " + strconv.Itoa(week),
+					FromWeek: nextWeek,
+				}
+				if err := a.add(tx, matchID, teamID, event.PlayerID, absence, nextWeek); err != nil {
+					return err
+				}
+
+			case models.EventYellowCard:
+				if event.PlayerID == nil {
+					continue
+				}
+				a.yellowCards[*event.PlayerID]++
+				if cards := a.yellowCards[*event.PlayerID]; cards%yellowCardsForBan == 0 {
+					absence := models.Absence{
+						Reason:   models.AbsenceSuspension,
+						Detail:   strconv.Itoa(cards) + " yellow cards",
+						FromWeek: nextWeek,
+					}
+					if err := a.add(tx, matchID, teamID, event.PlayerID, absence, nextWeek); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if a.cfg.InjuryMaxWeeks <= 0 {
+			continue
+		}
+		injuries := samplePoisson(rng, a.cfg.InjuriesPerMatch)
+		for i := 0; i < injuries; i++ {
+			var playerID *int
+			if len(a.squads[teamID]) > 0 {
+				// Nobody left to get hurt
+				if len(tookPart) == 0 {
+					break
+				}
+				j := rng.Intn(len(tookPart))
+				id := tookPart[j].ID
+				playerID = &id
+				tookPart = append(tookPart[:j:j], tookPart[j+1:]...)
+			}
+
+			weeks := 1 + rng.Intn(a.cfg.InjuryMaxWeeks)
+			absence := models.Absence{
+				Reason:   models.AbsenceInjury,
+				Detail:   "Injured in week " + strconv.Itoa(week),
+				FromWeek: week + 1,
+			}
+			if err := a.add(tx, matchID, teamID, playerID, absence, week+weeks); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// player returns a member of a team's squad
+func (a *availabilityTracker) player(teamID int, playerID *int) (models.Player, bool) {
+	if playerID == nil {
+		return models.Player{}, false
+	}
+	for _, player := range a.squads[teamID] {
+		if player.ID == *playerID {
+			return player, true
+		}
+	}
+	return models.Player{}, false
+}
+
+// add stores an absence of a player, or of a team without a squad when
+// playerID is nil, lasting until the given week
+func (a *availabilityTracker) add(tx *sql.Tx, matchID, teamID int, playerID *int, absence models.Absence, untilWeek int) error {
+	absence.SeasonID = a.seasonID
+	absence.TeamID = teamID
+	absence.MatchID = matchID
+	absence.UntilWeek = untilWeek
+	absence.StrengthLoss = a.cfg.AbsenceEffect
+	if playerID != nil {
+		player, _ := a.player(teamID, playerID)
+		absence.Player = &player
+		absence.StrengthLoss = playerStrengthLoss(a.squads[teamID], player, a.cfg)
+	}
+	absence.StrengthLoss = roundStrengthLoss(absence.StrengthLoss)
+
+	err := tx.QueryRow(`
+		INSERT INTO absences (season_id, team_id, player_id, match_id, reason, detail, from_week, until_week, strength_loss)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, absence.SeasonID, teamID, playerID, matchID, absence.Reason, absence.Detail,
+		absence.FromWeek, absence.UntilWeek, absence.StrengthLoss).Scan(&absence.ID)
+	if err != nil {
+		return fmt.Errorf("failed to store absence of team %d: %v", teamID, err)
+	}
+
+	a.absences = append(a.absences, absence)
+	return nil
+}
+
+// playerStrengthLoss is the share of its strength a team loses without a
+// player: ABSENCE_EFFECT scaled by the player's rating for one of the first
+// eleven of the squad, nothing for a squad player
+func playerStrengthLoss(squad []models.Player, player models.Player, cfg *config.Config) float64 {
+	for i, member := range squad {
+		if i >= startingPlayers {
+			break
+		}
+		if member.ID == player.ID {
+			return cfg.AbsenceEffect * player.Rating
+		}
+	}
+	return 0
+}
+
+// nextMatchWeek returns the week of a team's next match in a season after the
+// given week, or the week after it when none is scheduled yet
+func nextMatchWeek(q querier, seasonID, teamID, week int) (int, error) {
+	var next int
+	err := q.QueryRow(`
+		SELECT COALESCE(MIN(week), $3 + 1)
+		FROM matches
+		WHERE season_id = $1 AND (home_team_id = $2 OR away_team_id = $2) AND week > $3
+	`, seasonID, teamID, week).Scan(&next)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find the next match of team %d: %v", teamID, err)
+	}
+	return next, nil
+}
+
+// loadAbsences returns every absence of a season with the players it concerns
+func loadAbsences(q querier, seasonID int) ([]models.Absence, error) {
+	rows, err := q.Query(`
+		SELECT a.id, a.season_id, a.team_id, a.match_id, a.reason, a.detail,
+		       a.from_week, a.until_week, a.strength_loss,
+		       p.id, p.team_id, p.name, p.position, p.rating, p.shirt_number
+		FROM absences a
+		LEFT JOIN players p ON a.player_id = p.id
+		WHERE a.season_id = $1
+		ORDER BY a.from_week, a.id
+	`, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	absences := []models.Absence{}
+	for rows.Next() {
+		var absence models.Absence
+		var playerID, playerTeamID, shirtNumber sql.NullInt64
+		var name, position sql.NullString
+		var rating sql.NullFloat64
+		err := rows.Scan(
+			&absence.ID, &absence.SeasonID, &absence.TeamID, &absence.MatchID, &absence.Reason, &absence.Detail,
+			&absence.FromWeek, &absence.UntilWeek, &absence.StrengthLoss,
+			&playerID, &playerTeamID, &name, &position, &rating, &shirtNumber,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if playerID.Valid {
+			absence.Player = &models.Player{
+				ID:          int(playerID.Int64),
+				TeamID:      int(playerTeamID.Int64),
+				Name:        name.String,
+				Position:    position.String,
+				Rating:      rating.Float64,
+				ShirtNumber: int(shirtNumber.Int64),
+			}
+		}
+		absences = append(absences, absence)
+	}
+
+	return absences, rows.Err()
+}
+
+// absencesInWeek picks the absences that keep players out of a team's match in a week
+func absencesInWeek(absences []models.Absence, teamID, week int) []models.Absence {
+	var out []models.Absence
+	for _, absence := range absences {
+		if absence.TeamID == teamID && absence.FromWeek <= week && week <= absence.UntilWeek {
+			out = append(out, absence)
+		}
+	}
+	return out
+}
+
+// totalStrengthLoss adds up the strength a team loses to its absences
+func totalStrengthLoss(absences []models.Absence) float64 {
+	loss := 0.0
+	for _, absence := range absences {
+		loss += absence.StrengthLoss
+	}
+	return loss
+}
+
+// roundStrengthLoss rounds a strength loss to the three decimals it is stored with
+func roundStrengthLoss(loss float64) float64 {
+	return math.Round(loss*1000) / 1000
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/database"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// GetTeamAvailability handles the request to list who a team is without in a
+// week of the current season, or of the season given in the route, and why.
+// The week defaults to the next one to be played and can be picked with
+// ?week=.
+func GetTeamAvailability(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+
+	team, err := getTeam(database.DB, id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Team not found",
+		})
+	}
+
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+
+	var entered bool
+	err = database.DB.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM league_table WHERE season_id = $1 AND team_id = $2)", season.ID, id,
+	).Scan(&entered)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check season entry: " + err.Error(),
+		})
+	}
+	if !entered {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Team " + team.Name + " is not in season " + season.Name,
+		})
+	}
+
+	week := 0
+	if value := c.Query("week"); value != "" {
+		week, err = strconv.Atoi(value)
+		if err != nil || week < 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid week number",
+			})
+		}
+	} else {
+		err := database.DB.QueryRow(`
+			SELECT COALESCE(MIN(week) FILTER (WHERE played = false), MAX(week) + 1, 1)
+			FROM matches
+			WHERE season_id = $1
+		`, season.ID).Scan(&week)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to get the next week: " + err.Error(),
+			})
+		}
+	}
+
+	absences, err := loadAbsences(database.DB, season.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get absences: " + err.Error(),
+		})
+	}
+
+	unavailable := absencesInWeek(absences, id, week)
+	if unavailable == nil {
+		unavailable = []models.Absence{}
+	}
+
+	return c.JSON(models.TeamAvailability{
+		Team:         team,
+		SeasonID:     season.ID,
+		Week:         week,
+		StrengthLoss: roundStrengthLoss(totalStrengthLoss(unavailable)),
+		Unavailable:  unavailable,
+	})
+}
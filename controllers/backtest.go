@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/database"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// errNoCompletedSeasons is returned when there is no finished league season to backtest
+var errNoCompletedSeasons = errors.New("no completed league seasons to backtest")
+
+// errSeasonNotFinished is returned when the season asked for is not a league season with every match played
+var errSeasonNotFinished = errors.New("season is not a finished league season")
+
+// defaultCalibrationBins is the number of equal probability ranges the
+// calibration table is split into
+const defaultCalibrationBins = 10
+
+// RunBacktest replays completed league seasons week by week, asks the
+// prediction model for title probabilities after every week, and scores them
+// against the team that really won. seasonID picks one season; 0 backtests
+// every completed league season. Each season is simulated with its own
+// generator seeded from seed, so a season scores the same alone or with others.
+//
+// Teams are simulated with the ratings they have now rather than the ones they
+// had at the time, so old seasons are forecast with some hindsight.
+func RunBacktest(seasonID, simulations int, seed int64, bins int) (models.BacktestReport, error) {
+	cfg := config.GetConfig()
+	if simulations <= 0 {
+		simulations = cfg.PredictionSimulations
+	}
+	if bins <= 0 {
+		bins = defaultCalibrationBins
+	}
+
+	report := models.BacktestReport{
+		ModelVersion: predictionModelVersion,
+		Simulations:  simulations,
+		Seed:         seed,
+		Seasons:      []models.BacktestSeason{},
+	}
+
+	seasons, err := completedLeagueSeasons(database.DB, seasonID)
+	if err != nil {
+		return report, fmt.Errorf("failed to get completed seasons: %v", err)
+	}
+	if len(seasons) == 0 {
+		if seasonID != 0 {
+			return report, errSeasonNotFinished
+		}
+		return report, errNoCompletedSeasons
+	}
+
+	for _, season := range seasons {
+		state, err := loadSeasonState(season.ID)
+		if err != nil {
+			return report, fmt.Errorf("failed to load season %d: %v", season.ID, err)
+		}
+		table, err := rankedSeasonTable(database.DB, season)
+		if err != nil {
+			return report, fmt.Errorf("failed to get final table of season %d: %v", season.ID, err)
+		}
+		if len(table) == 0 {
+			continue
+		}
+
+		rng := rand.New(rand.NewSource(seed + int64(season.ID)))
+		replayed := backtestSeason(state, table[0].Team, simulations, rng)
+		replayed.Season = season
+		report.Seasons = append(report.Seasons, replayed)
+	}
+
+	var brier, logLoss float64
+	for _, season := range report.Seasons {
+		for _, week := range season.Weeks {
+			brier += week.BrierScore
+			logLoss += week.LogLoss
+			report.Forecasts++
+		}
+	}
+	if report.Forecasts > 0 {
+		report.BrierScore = roundScore(brier / float64(report.Forecasts))
+		report.LogLoss = roundScore(logLoss / float64(report.Forecasts))
+	}
+	report.Calibration = calibrationTable(report.Seasons, bins)
+
+	return report, nil
+}
+
+// completedLeagueSeasons returns the league seasons, or the one with the given
+// ID when id is not 0, that have matches and none left to play
+func completedLeagueSeasons(q querier, id int) ([]models.Season, error) {
+	rows, err := q.Query(`
+		SELECT s.id, s.competition_id, s.name, s.status, s.started_at, s.archived_at
+		FROM seasons s
+		JOIN competitions c ON s.competition_id = c.id
+		WHERE c.format = $1 AND ($2 = 0 OR s.id = $2)
+		  AND EXISTS (SELECT 1 FROM matches m WHERE m.season_id = s.id)
+		  AND NOT EXISTS (SELECT 1 FROM matches m WHERE m.season_id = s.id AND m.played = false)
+		ORDER BY s.id
+	`, models.FormatLeague, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var seasons []models.Season
+	for rows.Next() {
+		season, err := scanSeason(rows)
+		if err != nil {
+			return nil, err
+		}
+		seasons = append(seasons, season)
+	}
+
+	return seasons, rows.Err()
+}
+
+// backtestSeason forecasts a completed season after each of its weeks but the
+// last, from week 0 before any match, as if the later results were not known
+func backtestSeason(state *seasonState, champion models.Team, simulations int, rng *rand.Rand) models.BacktestSeason {
+	replayed := models.BacktestSeason{
+		Champion:     champion,
+		ModelVersion: modelVersion(state.Engine),
+		Weeks:        []models.BacktestWeek{},
+	}
+
+	var weeks []int
+	seen := make(map[int]bool)
+	for _, result := range state.Results {
+		if !seen[result.Week] {
+			seen[result.Week] = true
+			weeks = append(weeks, result.Week)
+		}
+	}
+	sort.Ints(weeks)
+	if len(weeks) == 0 {
+		return replayed
+	}
+	cutoffs := append([]int{0}, weeks[:len(weeks)-1]...)
+
+	// A title the model gave no chance still costs a finite log loss: half a
+	// simulation's worth of probability
+	minProbability := 0.5 / float64(simulations)
+
+	var brier, logLoss float64
+	for _, cutoff := range cutoffs {
+		past := *state
+		past.Results, past.Remaining, past.Absences = nil, nil, nil
+		// The form loaded is today's, not the cutoff's. An absence caused by a
+		// match after the cutoff starts two weeks after it at the earliest.
+		past.Form = nil
+		for _, absence := range state.Absences {
+			if absence.FromWeek <= cutoff+1 {
+				past.Absences = append(past.Absences, absence)
+			}
+		}
+		for _, result := range state.Results {
+			if result.Week <= cutoff {
+				past.Results = append(past.Results, result)
+				continue
+			}
+			past.Remaining = append(past.Remaining, fixture{
+				HomeTeamID: result.HomeTeamID,
+				AwayTeamID: result.AwayTeamID,
+				Week:       result.Week,
+			})
+		}
+
+		summary := runMonteCarlo(&past, simulations, rng)
+		forecast := models.BacktestWeek{Week: cutoff}
+		for _, team := range state.Teams {
+			forecast.Probabilities = append(forecast.Probabilities, models.BacktestProbability{
+				Team:        team,
+				Probability: float64(summary.TitleCounts[team.ID]) / float64(simulations),
+				Champion:    team.ID == champion.ID,
+			})
+		}
+		sort.SliceStable(forecast.Probabilities, func(i, j int) bool {
+			return forecast.Probabilities[i].Probability > forecast.Probabilities[j].Probability
+		})
+
+		forecastBrier, forecastLogLoss := scoreForecast(forecast.Probabilities, minProbability)
+		brier += forecastBrier
+		logLoss += forecastLogLoss
+		for i := range forecast.Probabilities {
+			forecast.Probabilities[i].Probability = roundScore(forecast.Probabilities[i].Probability)
+		}
+		forecast.BrierScore = roundScore(forecastBrier)
+		forecast.LogLoss = roundScore(forecastLogLoss)
+		replayed.Weeks = append(replayed.Weeks, forecast)
+	}
+
+	replayed.BrierScore = roundScore(brier / float64(len(cutoffs)))
+	replayed.LogLoss = roundScore(logLoss / float64(len(cutoffs)))
+	return replayed
+}
+
+// scoreForecast returns the Brier score of a title forecast, the squared
+// errors summed over the teams, and its log loss, the negative log of the
+// probability given to the champion
+func scoreForecast(probabilities []models.BacktestProbability, minProbability float64) (float64, float64) {
+	var brier, logLoss float64
+	for _, probability := range probabilities {
+		outcome := 0.0
+		if probability.Champion {
+			outcome = 1
+			logLoss = math.Log(1 / math.Max(probability.Probability, minProbability))
+		}
+		brier += (probability.Probability - outcome) * (probability.Probability - outcome)
+	}
+	return brier, logLoss
+}
+
+// calibrationTable sorts every team's title probability into bins equal
+// ranges and compares the average probability of each bin with the share of
+// its teams that won the title
+func calibrationTable(seasons []models.BacktestSeason, bins int) []models.CalibrationBin {
+	table := make([]models.CalibrationBin, bins)
+	sums := make([]float64, bins)
+	wins := make([]int, bins)
+	for i := range table {
+		table[i].Lower = roundScore(float64(i) / float64(bins))
+		table[i].Upper = roundScore(float64(i+1) / float64(bins))
+	}
+
+	for _, season := range seasons {
+		for _, week := range season.Weeks {
+			for _, probability := range week.Probabilities {
+				bin := int(probability.Probability * float64(bins))
+				if bin >= bins {
+					bin = bins - 1
+				}
+				table[bin].Forecasts++
+				sums[bin] += probability.Probability
+				if probability.Champion {
+					wins[bin]++
+				}
+			}
+		}
+	}
+
+	for i := range table {
+		if table[i].Forecasts == 0 {
+			continue
+		}
+		table[i].MeanProbability = roundScore(sums[i] / float64(table[i].Forecasts))
+		table[i].ObservedFrequency = roundScore(float64(wins[i]) / float64(table[i].Forecasts))
+	}
+	return table
+}
+
+// roundScore rounds a probability or score to the four decimals it is reported with
+func roundScore(score float64) float64 {
+	return math.Round(score*10000) / 10000
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/database"
+)
+
+// maxCalibrationBins is the most ranges the calibration table can be split into
+const maxCalibrationBins = 100
+
+// GetBacktest handles the request to score the prediction model against every
+// completed league season, or against the season given in the route. It
+// accepts ?simulations= (defaults to PREDICTION_SIMULATIONS), ?bins= for the
+// calibration table and ?seed= to make the report repeatable.
+func GetBacktest(c *fiber.Ctx) error {
+	simulations := 0
+	if value := c.Query("simulations"); value != "" {
+		var err error
+		simulations, err = strconv.Atoi(value)
+		if err != nil || simulations < 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid simulations, it must be a positive number",
+			})
+		}
+	}
+
+	bins := defaultCalibrationBins
+	if value := c.Query("bins"); value != "" {
+		var err error
+		bins, err = strconv.Atoi(value)
+		if err != nil || bins < 1 || bins > maxCalibrationBins {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid bins, it must be between 1 and " + strconv.Itoa(maxCalibrationBins),
+			})
+		}
+	}
+
+	seed, err := simulationSeed(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid seed, it must be an integer",
+		})
+	}
+
+	seasonID := 0
+	if c.Params("seasonId") != "" {
+		season, err := requestSeason(c)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Season not found",
+			})
+		}
+		if status, msg := checkLeagueSeason(database.DB, season); status != 0 {
+			return c.Status(status).JSON(fiber.Map{
+				"error": msg,
+			})
+		}
+		seasonID = season.ID
+	}
+
+	report, err := RunBacktest(seasonID, simulations, seed, bins)
+	switch {
+	case err == errNoCompletedSeasons:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "There are no completed league seasons to backtest",
+		})
+	case err == errSeasonNotFinished:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Only completed seasons can be backtested; this one still has matches to play",
+		})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to backtest predictions: " + err.Error(),
+		})
+	}
+
+	return c.JSON(report)
+}
@@ -0,0 +1,467 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/database"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// clinchSearchLimit is the number of positions the solver may visit for one
+// question before giving up; a team whose positions cannot be worked out in
+// that many gets an undetermined outlook rather than a guess
+var clinchSearchLimit = 100000
+
+// pointsRace is the part of a league the clinch solver works on: every team's
+// points and the matches still to be played, as indexes into teams
+type pointsRace struct {
+	teams     []int
+	points    []int
+	games     []int
+	remaining [][2]int
+}
+
+// newPointsRace builds the race from a league table and the unplayed fixtures
+func newPointsRace(table []models.TeamStats, remaining []fixture) *pointsRace {
+	race := &pointsRace{
+		teams:  make([]int, len(table)),
+		points: make([]int, len(table)),
+		games:  make([]int, len(table)),
+	}
+	index := make(map[int]int, len(table))
+	for i, stats := range table {
+		race.teams[i] = stats.Team.ID
+		race.points[i] = stats.Points
+		index[stats.Team.ID] = i
+	}
+
+	for _, f := range remaining {
+		home, okHome := index[f.HomeTeamID]
+		away, okAway := index[f.AwayTeamID]
+		if !okHome || !okAway {
+			continue
+		}
+		race.remaining = append(race.remaining, [2]int{home, away})
+		race.games[home]++
+		race.games[away]++
+	}
+
+	return race
+}
+
+// solveOutlooks works out every team's outlook from a ranked league table and
+// the fixtures still to play. Teams level on points are counted above the team
+// for its worst position and below it for its best, since the tie-breakers of
+// matches not played yet can go either way. Once every match is played the
+// outlook is the final position. top asks for a top-N finish as well; 0 leaves
+// it out. Every team of the table gets an outlook, marked undetermined when the
+// search for its positions hits clinchSearchLimit.
+func solveOutlooks(table []models.TeamStats, remaining []fixture, top int) map[int]*models.TeamOutlook {
+	race := newPointsRace(table, remaining)
+	outlooks := make(map[int]*models.TeamOutlook, len(table))
+
+	for t, stats := range table {
+		var best, worst int
+		var magic *int
+		if len(race.remaining) == 0 {
+			best, worst = stats.Position, stats.Position
+			if stats.Position == 1 {
+				magic = new(int)
+			}
+		} else {
+			if m, ok := race.magicNumber(t); ok {
+				magic = &m
+			}
+
+			var ok bool
+			best, ok = race.bestPosition(t)
+			if ok {
+				worst, ok = race.worstPosition(t)
+			}
+			if !ok {
+				// The magic number does not need the search, so it still
+				// tells whether the title is won
+				outlooks[stats.Team.ID] = &models.TeamOutlook{
+					Undetermined:  true,
+					ClinchedTitle: magic != nil && *magic == 0,
+					MagicNumber:   magic,
+					Top:           top,
+				}
+				continue
+			}
+		}
+
+		outlook := &models.TeamOutlook{
+			BestPosition:        best,
+			WorstPosition:       worst,
+			ClinchedTitle:       worst == 1,
+			EliminatedFromTitle: best > 1,
+			MagicNumber:         magic,
+		}
+		if top > 0 {
+			outlook.Top = top
+			outlook.ClinchedTop = worst <= top
+			outlook.EliminatedFromTop = best > top
+		}
+		outlooks[stats.Team.ID] = outlook
+	}
+
+	return outlooks
+}
+
+// seasonOutlooks works out the outlook of every team of a season from its
+// current table and unplayed fixtures. Only league seasons have one.
+func seasonOutlooks(season models.Season, top int) (map[int]*models.TeamOutlook, error) {
+	competition, err := loadCompetition(database.DB, season.CompetitionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get competition: %v", err)
+	}
+	if competition.Format != models.FormatLeague {
+		return nil, nil
+	}
+
+	table, err := rankedSeasonTable(database.DB, season)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get league table: %v", err)
+	}
+
+	remaining, err := loadUnplayedFixtures(season.ID, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remaining matches: %v", err)
+	}
+
+	return solveOutlooks(table, remaining, top), nil
+}
+
+// outlookTop reads the top-N finish asked for with ?top=, 0 when none is
+func outlookTop(c *fiber.Ctx) (int, error) {
+	value := c.Query("top")
+	if value == "" {
+		return 0, nil
+	}
+	top, err := strconv.Atoi(value)
+	if err != nil || top < 1 {
+		return 0, fmt.Errorf("invalid top %q", value)
+	}
+	return top, nil
+}
+
+// bestPosition returns the highest position team t can still finish in: the
+// team wins all its matches and the other results leave as few teams as
+// possible with more points
+func (r *pointsRace) bestPosition(t int) (int, bool) {
+	points := append([]int{}, r.points...)
+	var others [][2]int
+	for _, match := range r.remaining {
+		if match[0] != t && match[1] != t {
+			others = append(others, match)
+		}
+	}
+
+	passed, ok := crossings(points, others, t, r.points[t]+3*r.games[t], false)
+	return 1 + passed, ok
+}
+
+// worstPosition returns the lowest position team t can still finish in: the
+// team loses all its matches and the other results leave as many teams as
+// possible level with it or above
+func (r *pointsRace) worstPosition(t int) (int, bool) {
+	points := append([]int{}, r.points...)
+	var others [][2]int
+	for _, match := range r.remaining {
+		switch t {
+		case match[0]:
+			points[match[1]] += 3
+		case match[1]:
+			points[match[0]] += 3
+		default:
+			others = append(others, match)
+		}
+	}
+
+	caught, ok := crossings(points, others, t, r.points[t]-1, true)
+	return 1 + caught, ok
+}
+
+// crossings returns the fewest teams other than t, or the most when most is
+// set, that can end up with more than threshold points over every result of
+// the given matches, and false when there were too many results to look at.
+// The results are searched match by match, dropping every branch that cannot
+// beat the best count found so far.
+func crossings(points []int, matches [][2]int, t, threshold int, most bool) (int, bool) {
+	crossed := 0
+	for i := range points {
+		if i != t && points[i] > threshold {
+			crossed++
+		}
+	}
+
+	left := make([]int, len(points))
+	for _, match := range matches {
+		left[match[0]]++
+		left[match[1]]++
+	}
+
+	// room is how many more points a team can take without crossing, or
+	// settled once it has crossed or cannot cross with the matches it has left
+	const settled = -1
+	room := func(i int) int {
+		if points[i] > threshold || threshold-points[i] >= 3*left[i] {
+			return settled
+		}
+		return threshold - points[i]
+	}
+
+	// between counts the matches left between every two teams
+	between := make([][]int, len(points))
+	for i := range between {
+		between[i] = make([]int, len(points))
+	}
+	for _, match := range matches {
+		between[match[0]][match[1]]++
+		between[match[1]][match[0]]++
+	}
+
+	// bound is the best the matches left could still add. For the most
+	// crossings it is every team that can still cross. For the fewest, the
+	// matches within any group of teams that have not crossed hand out at least
+	// two points each, and a team that does not cross takes no more than its
+	// room; when the rooms cannot hold the points, teams of the group must
+	// cross, each taking what it can win beyond its room. Groups are made of
+	// the teams with the least room, which are the likeliest to overflow.
+	bound := func() int {
+		var group []int
+		for i := range points {
+			if i == t {
+				continue
+			}
+			if r := room(i); r != settled {
+				group = append(group, i)
+			}
+		}
+		if most {
+			return len(group)
+		}
+		sort.Slice(group, func(x, y int) bool {
+			return threshold-points[group[x]] < threshold-points[group[y]]
+		})
+
+		result := 0
+		inGroup := make([]int, len(points))
+		handedOut := 0
+		for size, i := range group {
+			for _, j := range group[:size] {
+				handedOut += 2 * between[i][j]
+				inGroup[j] += between[i][j]
+				inGroup[i] += between[i][j]
+			}
+
+			deficit := handedOut
+			var extra []int
+			for _, j := range group[:size+1] {
+				r := threshold - points[j]
+				if r > 3*inGroup[j] {
+					r = 3 * inGroup[j]
+				}
+				deficit -= r
+				extra = append(extra, 3*inGroup[j]-r)
+			}
+			if deficit <= 0 {
+				continue
+			}
+
+			sort.Sort(sort.Reverse(sort.IntSlice(extra)))
+			count := 0
+			for _, e := range extra {
+				if deficit <= 0 {
+					break
+				}
+				deficit -= e
+				count++
+			}
+			if count > result {
+				result = count
+			}
+		}
+		return result
+	}
+
+	best := -1
+	nodes := 0
+	var search func(k, count int) bool
+	search = func(k, count int) bool {
+		nodes++
+		if nodes > clinchSearchLimit {
+			return false
+		}
+		if best >= 0 {
+			reach := count + bound()
+			if (most && reach <= best) || (!most && reach >= best) {
+				return true
+			}
+		}
+		if k == len(matches) {
+			best = count
+			return true
+		}
+
+		a, b := matches[k][0], matches[k][1]
+		roomA, roomB := room(a), room(b)
+
+		// A settled team is best off taking every point when the fewest
+		// crossings are wanted and giving them all away for the most.
+		// Otherwise the likeliest results come first: a win for the team
+		// with more room, or for the most crossings less.
+		winA, winB, draw := [2]int{3, 0}, [2]int{0, 3}, [2]int{1, 1}
+		var outcomes [][2]int
+		switch {
+		case roomA == settled && roomB == settled:
+			outcomes = [][2]int{winA}
+		case roomA == settled:
+			outcomes = [][2]int{winA}
+			if most {
+				outcomes = [][2]int{winB}
+			}
+		case roomB == settled:
+			outcomes = [][2]int{winB}
+			if most {
+				outcomes = [][2]int{winA}
+			}
+		case most && roomA <= roomB:
+			outcomes = [][2]int{winA, winB, draw}
+		case most:
+			outcomes = [][2]int{winB, winA, draw}
+		default:
+			// spare is the room a team has beyond drawing every match it has left
+			spareA, spareB := roomA-2*left[a], roomB-2*left[b]
+			switch {
+			case spareA < 3 && spareB < 3:
+				outcomes = [][2]int{draw, winA, winB}
+				if spareB > spareA {
+					outcomes = [][2]int{draw, winB, winA}
+				}
+			case spareA >= spareB:
+				outcomes = [][2]int{winA, draw, winB}
+			default:
+				outcomes = [][2]int{winB, draw, winA}
+			}
+		}
+
+		left[a]--
+		left[b]--
+		between[a][b]--
+		between[b][a]--
+		defer func() {
+			left[a]++
+			left[b]++
+			between[a][b]++
+			between[b][a]++
+		}()
+
+		for _, outcome := range outcomes {
+			before := 0
+			for _, i := range []int{a, b} {
+				if points[i] > threshold {
+					before++
+				}
+			}
+			points[a] += outcome[0]
+			points[b] += outcome[1]
+			after := 0
+			for _, i := range []int{a, b} {
+				if points[i] > threshold {
+					after++
+				}
+			}
+			ok := search(k+1, count+after-before)
+			points[a] -= outcome[0]
+			points[b] -= outcome[1]
+			if !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	if !search(0, 0) {
+		return 0, false
+	}
+	return crossed + best, true
+}
+
+// magicNumber returns the fewest points team t must still take to be sure of
+// finishing first whatever the other results, and false when winning every
+// match would not be enough. Whether a rival can finish level or above does
+// not depend on the other rivals, so each one is checked on its own over every
+// way t could take its points.
+func (r *pointsRace) magicNumber(t int) (int, bool) {
+	for m := 0; m <= 3*r.games[t]; m++ {
+		// A total no set of results adds up to is no target
+		if rest, _ := fewestPoints(m, r.games[t]); rest != m {
+			continue
+		}
+		if r.securesTitle(t, m) {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+// securesTitle reports whether team t finishes first in every outcome in
+// which it takes at least m more points
+func (r *pointsRace) securesTitle(t, m int) bool {
+	for rival := range r.teams {
+		if rival == t {
+			continue
+		}
+
+		// k matches against the rival, the rest against other teams
+		k := r.gamesBetween(t, rival)
+		others := r.games[t] - k
+		rivalMax := r.points[rival] + 3*(r.games[rival]-k)
+
+		for wins := 0; wins <= k; wins++ {
+			for draws := 0; wins+draws <= k; draws++ {
+				losses := k - wins - draws
+				// The fewest points t can take from its other matches and still reach m
+				rest, ok := fewestPoints(m-3*wins-draws, others)
+				if !ok {
+					continue
+				}
+				if rivalMax+draws+3*losses >= r.points[t]+3*wins+draws+rest {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// fewestPoints returns the smallest number of points at least need that a
+// team can take from n matches, and false when it cannot take that many. Every
+// total up to 3n can be reached except 3n-1.
+func fewestPoints(need, n int) (int, bool) {
+	switch {
+	case need <= 0:
+		return 0, true
+	case need > 3*n:
+		return 0, false
+	case need == 3*n-1:
+		return 3 * n, true
+	}
+	return need, true
+}
+
+// gamesBetween counts the remaining matches between two teams
+func (r *pointsRace) gamesBetween(a, b int) int {
+	count := 0
+	for _, match := range r.remaining {
+		if (match[0] == a && match[1] == b) || (match[0] == b && match[1] == a) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// bruteOutlook works out a team's outlook by playing out every result of the
+// remaining matches. Teams level on points count above the team for its worst
+// position and below it for its best, as the solver counts them.
+func bruteOutlook(points map[int]int, remaining []fixture, teamID int) (int, int, *int) {
+	best, worst := len(points), 1
+	// secured[m] is false once some outcome in which the team takes exactly m
+	// points leaves it short of first place; reached[m] that m can be taken
+	reached := make(map[int]bool)
+	secured := make(map[int]bool)
+
+	final := make(map[int]int, len(points))
+	var play func(k, gained int)
+	play = func(k, gained int) {
+		if k == len(remaining) {
+			above, levelOrAbove := 0, 0
+			for id, p := range final {
+				if id == teamID {
+					continue
+				}
+				if p > final[teamID] {
+					above++
+				}
+				if p >= final[teamID] {
+					levelOrAbove++
+				}
+			}
+			if 1+above < best {
+				best = 1 + above
+			}
+			if 1+levelOrAbove > worst {
+				worst = 1 + levelOrAbove
+			}
+			if !reached[gained] {
+				reached[gained] = true
+				secured[gained] = true
+			}
+			if levelOrAbove > 0 {
+				secured[gained] = false
+			}
+			return
+		}
+
+		f := remaining[k]
+		for _, outcome := range [][2]int{{3, 0}, {1, 1}, {0, 3}} {
+			final[f.HomeTeamID] += outcome[0]
+			final[f.AwayTeamID] += outcome[1]
+			won := 0
+			if f.HomeTeamID == teamID {
+				won = outcome[0]
+			} else if f.AwayTeamID == teamID {
+				won = outcome[1]
+			}
+			play(k+1, gained+won)
+			final[f.HomeTeamID] -= outcome[0]
+			final[f.AwayTeamID] -= outcome[1]
+		}
+	}
+	for id, p := range points {
+		final[id] = p
+	}
+	play(0, 0)
+
+	// The magic number is the smallest total the team can take that secures
+	// first place along with every larger one
+	var totals []int
+	for m := range reached {
+		totals = append(totals, m)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(totals)))
+	var magic *int
+	for _, m := range totals {
+		if !secured[m] {
+			break
+		}
+		m := m
+		magic = &m
+	}
+
+	return best, worst, magic
+}
+
+// randomRace builds a league of n teams with random points, ranked by points,
+// and up to maxRemaining random fixtures between them
+func randomRace(rng *rand.Rand, n, maxRemaining int) ([]models.TeamStats, map[int]int, []fixture) {
+	points := make(map[int]int, n)
+	table := make([]models.TeamStats, n)
+	for i := range table {
+		table[i].Team = models.Team{ID: i + 1}
+		table[i].Points = rng.Intn(13)
+		points[i+1] = table[i].Points
+	}
+	sort.SliceStable(table, func(i, j int) bool {
+		return table[i].Points > table[j].Points
+	})
+	for i := range table {
+		table[i].Position = i + 1
+	}
+
+	remaining := make([]fixture, 1+rng.Intn(maxRemaining))
+	for i := range remaining {
+		home := 1 + rng.Intn(n)
+		away := 1 + rng.Intn(n-1)
+		if away >= home {
+			away++
+		}
+		remaining[i] = fixture{ID: i + 1, HomeTeamID: home, AwayTeamID: away, Week: 1 + i/2}
+	}
+
+	return table, points, remaining
+}
+
+func TestSolveOutlooksMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	const top = 2
+
+	for c := 0; c < 400; c++ {
+		n := 4 + c%2
+		table, points, remaining := randomRace(rng, n, 8)
+		outlooks := solveOutlooks(table, remaining, top)
+
+		for _, stats := range table {
+			id := stats.Team.ID
+			outlook := outlooks[id]
+			if outlook == nil {
+				t.Fatalf("case %d: team %d has no outlook", c, id)
+			}
+
+			best, worst, magic := bruteOutlook(points, remaining, id)
+			if outlook.BestPosition != best || outlook.WorstPosition != worst {
+				t.Fatalf("case %d: points %v, fixtures %v: team %d positions %d-%d, want %d-%d",
+					c, points, remaining, id, outlook.BestPosition, outlook.WorstPosition, best, worst)
+			}
+			if outlook.ClinchedTitle != (worst == 1) || outlook.EliminatedFromTitle != (best > 1) {
+				t.Fatalf("case %d: team %d title flags %v/%v with positions %d-%d",
+					c, id, outlook.ClinchedTitle, outlook.EliminatedFromTitle, best, worst)
+			}
+			if outlook.Top != top || outlook.ClinchedTop != (worst <= top) || outlook.EliminatedFromTop != (best > top) {
+				t.Fatalf("case %d: team %d top %d flags %v/%v with positions %d-%d",
+					c, id, outlook.Top, outlook.ClinchedTop, outlook.EliminatedFromTop, best, worst)
+			}
+
+			switch {
+			case magic == nil && outlook.MagicNumber != nil:
+				t.Fatalf("case %d: points %v, fixtures %v: team %d magic number %d, want none",
+					c, points, remaining, id, *outlook.MagicNumber)
+			case magic != nil && outlook.MagicNumber == nil:
+				t.Fatalf("case %d: points %v, fixtures %v: team %d has no magic number, want %d",
+					c, points, remaining, id, *magic)
+			case magic != nil && *outlook.MagicNumber != *magic:
+				t.Fatalf("case %d: points %v, fixtures %v: team %d magic number %d, want %d",
+					c, points, remaining, id, *outlook.MagicNumber, *magic)
+			}
+		}
+	}
+}
+
+func TestSolveOutlooksFinishedSeason(t *testing.T) {
+	table := []models.TeamStats{
+		{Position: 1, Team: models.Team{ID: 3}, Points: 10},
+		{Position: 2, Team: models.Team{ID: 1}, Points: 10},
+		{Position: 3, Team: models.Team{ID: 2}, Points: 4},
+	}
+	outlooks := solveOutlooks(table, nil, 0)
+
+	for _, stats := range table {
+		outlook := outlooks[stats.Team.ID]
+		if outlook.BestPosition != stats.Position || outlook.WorstPosition != stats.Position {
+			t.Fatalf("team %d: positions %d-%d, want %d", stats.Team.ID, outlook.BestPosition, outlook.WorstPosition, stats.Position)
+		}
+		if (outlook.MagicNumber != nil) != (stats.Position == 1) {
+			t.Fatalf("team %d: magic number set %v at position %d", stats.Team.ID, outlook.MagicNumber != nil, stats.Position)
+		}
+		if outlook.Top != 0 {
+			t.Fatalf("team %d: top %d without one asked for", stats.Team.ID, outlook.Top)
+		}
+	}
+}
+
+func TestSolveOutlooksSearchLimit(t *testing.T) {
+	table, _, _ := randomRace(rand.New(rand.NewSource(1)), 4, 1)
+	teams := make([]int, len(table))
+	for i, stats := range table {
+		teams[i] = stats.Team.ID
+	}
+	remaining := roundRobinFixtures(teams)
+
+	solved := solveOutlooks(table, remaining, 2)
+	for id, outlook := range solved {
+		if outlook.Undetermined {
+			t.Fatalf("team %d undetermined within the limit", id)
+		}
+	}
+
+	defer func(limit int) { clinchSearchLimit = limit }(clinchSearchLimit)
+	clinchSearchLimit = 1
+
+	// Every team has matches between others left to search, so none can be
+	// solved within one position and each is marked rather than guessed
+	outlooks := solveOutlooks(table, remaining, 2)
+	if len(outlooks) != len(table) {
+		t.Fatalf("got %d outlooks past the search limit, want %d", len(outlooks), len(table))
+	}
+	for id, outlook := range outlooks {
+		if !outlook.Undetermined || outlook.BestPosition != 0 || outlook.WorstPosition != 0 || outlook.EliminatedFromTitle {
+			t.Fatalf("team %d past the search limit: got %+v, want an undetermined outlook", id, outlook)
+		}
+		// The magic number needs no search and is still given
+		if outlook.Top != 2 || !reflect.DeepEqual(outlook.MagicNumber, solved[id].MagicNumber) {
+			t.Fatalf("team %d past the search limit: got %+v, want top 2 and magic number %v", id, outlook, solved[id].MagicNumber)
+		}
+	}
+}
+
+// A 20-team league with 10 weeks left is the usual size. Every team gets an
+// outlook, and the ones worked out agree with each other.
+func TestSolveOutlooksFullSizeLeague(t *testing.T) {
+	for seed := int64(1); seed <= 4; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		teams := makeTeams(20)
+		var results []matchResult
+		var remaining []fixture
+		for _, f := range roundRobinFixtures(makeTeamIDs(20)) {
+			if f.Week > 28 {
+				remaining = append(remaining, f)
+				continue
+			}
+			results = append(results, matchResult{
+				HomeTeamID: f.HomeTeamID,
+				AwayTeamID: f.AwayTeamID,
+				HomeScore:  rng.Intn(4),
+				AwayScore:  rng.Intn(3),
+				Week:       f.Week,
+			})
+		}
+		table := computeStandings(teams, results)
+		rankStandings(table, results, nil, seed)
+
+		outlooks := solveOutlooks(table, remaining, 4)
+		if len(outlooks) != len(table) {
+			t.Fatalf("seed %d: got %d outlooks, want %d", seed, len(outlooks), len(table))
+		}
+
+		champions := 0
+		for _, stats := range table {
+			outlook := outlooks[stats.Team.ID]
+			if outlook.ClinchedTitle {
+				champions++
+			}
+			if outlook.Undetermined {
+				continue
+			}
+			if outlook.BestPosition < 1 || outlook.BestPosition > outlook.WorstPosition || outlook.WorstPosition > len(table) {
+				t.Fatalf("seed %d: team %d positions %d-%d", seed, stats.Team.ID, outlook.BestPosition, outlook.WorstPosition)
+			}
+			if outlook.ClinchedTop && outlook.EliminatedFromTop {
+				t.Fatalf("seed %d: team %d both clinched and eliminated from the top 4", seed, stats.Team.ID)
+			}
+			if (outlook.MagicNumber != nil && *outlook.MagicNumber == 0) != outlook.ClinchedTitle {
+				t.Fatalf("seed %d: team %d magic number %v with clinched title %v",
+					seed, stats.Team.ID, outlook.MagicNumber, outlook.ClinchedTitle)
+			}
+		}
+		if champions > 1 {
+			t.Fatalf("seed %d: %d teams clinched the title", seed, champions)
+		}
+	}
+}
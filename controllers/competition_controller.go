@@ -0,0 +1,533 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/database"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// GetAllCompetitions handles the request to list all competitions, top division first
+func GetAllCompetitions(c *fiber.Ctx) error {
+	rows, err := database.DB.Query("SELECT " + competitionColumns + " FROM competitions ORDER BY tier, id")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get competitions: " + err.Error(),
+		})
+	}
+	defer rows.Close()
+	
+	var competitions []models.Competition
+	for rows.Next() {
+		competition, err := scanCompetition(rows)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to scan competition: " + err.Error(),
+			})
+		}
+		competitions = append(competitions, competition)
+	}
+	
+	return c.JSON(competitions)
+}
+
+// GetCompetitionByID handles the request to get a single competition
+func GetCompetitionByID(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid competition ID",
+		})
+	}
+	
+	competition, err := loadCompetition(database.DB, id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Competition not found",
+		})
+	}
+	
+	return c.JSON(competition)
+}
+
+// GetMatchEngines handles the request to list the match engines a competition
+// can be played with, and the parameters each one takes
+func GetMatchEngines(c *fiber.Ctx) error {
+	return c.JSON(matchEngineList(config.GetConfig()))
+}
+
+// UpdateTieBreakers handles the request to change the tie-break chain of a competition.
+// The body lists the rules in order; lots_seed optionally fixes the drawing of lots.
+func UpdateTieBreakers(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid competition ID",
+		})
+	}
+	
+	var req struct {
+		TieBreakers []string `json:"tie_breakers"`
+		LotsSeed    *int64   `json:"lots_seed"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to parse request body: " + err.Error(),
+		})
+	}
+	
+	if len(req.TieBreakers) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one tie-break rule is required",
+		})
+	}
+	if msg := validateTieBreakers(req.TieBreakers); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	
+	competition, err := loadCompetition(database.DB, id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Competition not found",
+		})
+	}
+	
+	competition.TieBreakers = req.TieBreakers
+	if req.LotsSeed != nil {
+		competition.LotsSeed = *req.LotsSeed
+	}
+	
+	_, err = database.DB.Exec(
+		"UPDATE competitions SET tie_breakers = $1, lots_seed = $2 WHERE id = $3",
+		strings.Join(competition.TieBreakers, ","), competition.LotsSeed, competition.ID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update tie-breakers: " + err.Error(),
+		})
+	}
+	
+	return c.JSON(fiber.Map{
+		"message": "Tie-breakers updated successfully",
+		"competition": competition,
+	})
+}
+
+// competitionRequest is the body accepted by the create and update competition
+// endpoints. Fields left out of an update keep their current value.
+type competitionRequest struct {
+	Name             *string            `json:"name"`
+	Format           *string            `json:"format"`
+	Tier             *int               `json:"tier"`
+	PromotionPlaces  *int               `json:"promotion_places"`
+	RelegationPlaces *int               `json:"relegation_places"`
+	PlayoffPlaces    *int               `json:"playoff_places"`
+	TwoLegged        *bool              `json:"two_legged"`
+	AwayGoalsRule    *bool              `json:"away_goals_rule"`
+	GroupCount       *int               `json:"group_count"`
+	GroupQualifiers  *int               `json:"group_qualifiers"`
+	TieBreakers      []string           `json:"tie_breakers"`
+	MatchEngine      *string            `json:"match_engine"`
+	EngineParams     map[string]float64 `json:"engine_params"`
+}
+
+// CreateCompetition handles the request to add a division to the pyramid, a
+// knockout cup or a tournament. The competition starts with a season of its
+// own: a division's is empty and teams are entered by creating them with its
+// competition_id, while cups and tournaments enter every team playing in a
+// division this season.
+func CreateCompetition(c *fiber.Ctx) error {
+	var req competitionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to parse request body: " + err.Error(),
+		})
+	}
+	
+	competition := models.Competition{
+		TieBreakers: parseTieBreakers(defaultTieBreakers),
+		Format:      models.FormatLeague,
+	}
+	if req.Format != nil {
+		competition.Format = *req.Format
+	}
+	
+	if req.Name == nil || (competition.Format == models.FormatLeague && req.Tier == nil) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Name is required, and so is tier for a league",
+		})
+	}
+	
+	if msg := applyCompetitionRequest(&competition, req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction: " + err.Error(),
+		})
+	}
+	defer tx.Rollback()
+	
+	if status, msg := checkCompetitionUnique(tx, competition); msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	
+	competition, err = scanCompetition(tx.QueryRow(`
+		INSERT INTO competitions (
+			name, tie_breakers, format, tier, promotion_places, relegation_places, playoff_places,
+			two_legged, away_goals_rule, group_count, group_qualifiers, match_engine, engine_params
+		)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING `+competitionColumns,
+		competition.Name, strings.Join(competition.TieBreakers, ","), competition.Format, competition.Tier,
+		competition.PromotionPlaces, competition.RelegationPlaces, competition.PlayoffPlaces,
+		competition.TwoLegged, competition.AwayGoalsRule, competition.GroupCount, competition.GroupQualifiers,
+		competition.MatchEngine, engineParamsJSON(competition.EngineParams),
+	))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create competition: " + err.Error(),
+		})
+	}
+	
+	// Name the first season after the current one so the competitions line up
+	name := "Season 1"
+	if current, err := loadCurrentSeason(tx); err == nil {
+		name = current.Name
+	}
+	
+	season, err := scanSeason(tx.QueryRow(`
+		INSERT INTO seasons (competition_id, name, status)
+		VALUES ($1, $2, $3)
+		RETURNING id, competition_id, name, status, started_at, archived_at
+	`, competition.ID, name, models.SeasonActive))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create season: " + err.Error(),
+		})
+	}
+	
+	if competition.Format != models.FormatLeague {
+		if err := enterCupTeams(tx, season.ID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to enter teams: " + err.Error(),
+			})
+		}
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction: " + err.Error(),
+		})
+	}
+	
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Competition created successfully",
+		"competition": competition,
+		"season": season,
+	})
+}
+
+// UpdateCompetition handles the request to change a competition's settings:
+// a division's tier and number of promotion, relegation and playoff places,
+// how a cup's ties are played, or a tournament's groups. The format of a
+// competition cannot change. New division places and groups are used the next
+// time a season is started.
+func UpdateCompetition(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid competition ID",
+		})
+	}
+	
+	var req competitionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to parse request body: " + err.Error(),
+		})
+	}
+	
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction: " + err.Error(),
+		})
+	}
+	defer tx.Rollback()
+	
+	competition, err := loadCompetition(tx, id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Competition not found",
+		})
+	}
+	
+	if req.Format != nil && *req.Format != competition.Format {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "The format of a competition cannot be changed",
+		})
+	}
+	
+	if msg := applyCompetitionRequest(&competition, req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	
+	if status, msg := checkCompetitionUnique(tx, competition); msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	
+	_, err = tx.Exec(`
+		UPDATE competitions SET
+		name = $1,
+		tie_breakers = $2,
+		tier = NULLIF($3, 0),
+		promotion_places = $4,
+		relegation_places = $5,
+		playoff_places = $6,
+		two_legged = $7,
+		away_goals_rule = $8,
+		group_count = $9,
+		group_qualifiers = $10,
+		match_engine = $11,
+		engine_params = $12
+		WHERE id = $13
+	`,
+		competition.Name, strings.Join(competition.TieBreakers, ","), competition.Tier,
+		competition.PromotionPlaces, competition.RelegationPlaces, competition.PlayoffPlaces,
+		competition.TwoLegged, competition.AwayGoalsRule, competition.GroupCount, competition.GroupQualifiers,
+		competition.MatchEngine, engineParamsJSON(competition.EngineParams),
+		competition.ID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update competition: " + err.Error(),
+		})
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction: " + err.Error(),
+		})
+	}
+	
+	return c.JSON(fiber.Map{
+		"message": "Competition updated successfully",
+		"competition": competition,
+	})
+}
+
+// applyCompetitionRequest copies the fields present in req onto competition and
+// validates the result. It returns a message describing the first problem found.
+func applyCompetitionRequest(competition *models.Competition, req competitionRequest) string {
+	if req.Name != nil {
+		competition.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Tier != nil {
+		competition.Tier = *req.Tier
+	}
+	if req.PromotionPlaces != nil {
+		competition.PromotionPlaces = *req.PromotionPlaces
+	}
+	if req.RelegationPlaces != nil {
+		competition.RelegationPlaces = *req.RelegationPlaces
+	}
+	if req.PlayoffPlaces != nil {
+		competition.PlayoffPlaces = *req.PlayoffPlaces
+	}
+	if req.TwoLegged != nil {
+		competition.TwoLegged = *req.TwoLegged
+	}
+	if req.AwayGoalsRule != nil {
+		competition.AwayGoalsRule = *req.AwayGoalsRule
+	}
+	if req.GroupCount != nil {
+		competition.GroupCount = *req.GroupCount
+	}
+	if req.GroupQualifiers != nil {
+		competition.GroupQualifiers = *req.GroupQualifiers
+	}
+	if req.TieBreakers != nil {
+		if msg := validateTieBreakers(req.TieBreakers); msg != "" {
+			return msg
+		}
+		competition.TieBreakers = req.TieBreakers
+	}
+	if req.MatchEngine != nil {
+		// Parameters of the old engine do not carry over to a new one
+		engine := strings.TrimSpace(*req.MatchEngine)
+		if engine != competition.MatchEngine && req.EngineParams == nil {
+			competition.EngineParams = nil
+		}
+		competition.MatchEngine = engine
+	}
+	if req.EngineParams != nil {
+		competition.EngineParams = req.EngineParams
+	}
+	if _, err := newMatchEngine(competition.MatchEngine, competition.EngineParams, config.GetConfig()); err != nil {
+		return "Invalid match engine: " + err.Error()
+	}
+	
+	if competition.Name == "" || len(competition.Name) > 100 {
+		return "Competition name must be between 1 and 100 characters"
+	}
+	
+	if competition.Format != models.FormatTournament && (competition.GroupCount != 0 || competition.GroupQualifiers != 0) {
+		return "Groups only apply to tournaments"
+	}
+	
+	switch competition.Format {
+	case models.FormatLeague:
+		if competition.Tier < 1 {
+			return "Tier must be 1 or more"
+		}
+		if competition.PromotionPlaces < 0 || competition.RelegationPlaces < 0 || competition.PlayoffPlaces < 0 {
+			return "Promotion, relegation and playoff places cannot be negative"
+		}
+		if competition.PlayoffPlaces == 1 {
+			return "A playoff needs at least 2 places"
+		}
+		if competition.TwoLegged || competition.AwayGoalsRule {
+			return "Two-legged ties and the away goals rule only apply to cups"
+		}
+	case models.FormatCup, models.FormatTournament:
+		if competition.Tier != 0 || competition.PromotionPlaces != 0 || competition.RelegationPlaces != 0 || competition.PlayoffPlaces != 0 {
+			return "Only leagues have a tier and promotion, relegation or playoff places"
+		}
+		if competition.AwayGoalsRule && !competition.TwoLegged {
+			return "The away goals rule needs two-legged ties"
+		}
+	default:
+		return "Format must be league, cup or tournament"
+	}
+	
+	if competition.Format == models.FormatTournament {
+		if competition.GroupCount < 1 || competition.GroupCount > maxGroups {
+			return "A tournament needs between 1 and " + strconv.Itoa(maxGroups) + " groups"
+		}
+		qualifiers := competition.GroupCount * competition.GroupQualifiers
+		if competition.GroupQualifiers < 1 || qualifiers < 2 || qualifiers&(qualifiers-1) != 0 {
+			return "group_count times group_qualifiers must be a power of two, so the knockout needs no byes"
+		}
+	}
+	
+	return ""
+}
+
+// checkCompetitionUnique makes sure no other competition uses the same name or tier
+func checkCompetitionUnique(tx *sql.Tx, competition models.Competition) (int, string) {
+	var count int
+	err := tx.QueryRow(
+		"SELECT COUNT(*) FROM competitions WHERE LOWER(name) = LOWER($1) AND id <> $2",
+		competition.Name, competition.ID,
+	).Scan(&count)
+	if err != nil {
+		return fiber.StatusInternalServerError, "Failed to check competition name: " + err.Error()
+	}
+	if count > 0 {
+		return fiber.StatusConflict, "A competition named " + competition.Name + " already exists"
+	}
+	
+	if competition.Tier == 0 {
+		return 0, ""
+	}
+	
+	err = tx.QueryRow(
+		"SELECT COUNT(*) FROM competitions WHERE tier = $1 AND id <> $2",
+		competition.Tier, competition.ID,
+	).Scan(&count)
+	if err != nil {
+		return fiber.StatusInternalServerError, "Failed to check tier: " + err.Error()
+	}
+	if count > 0 {
+		return fiber.StatusConflict, "Another division already plays in tier " + strconv.Itoa(competition.Tier)
+	}
+	
+	return 0, ""
+}
+
+// competitionColumns lists the columns read by scanCompetition, in order
+const competitionColumns = "id, name, tie_breakers, lots_seed, format, COALESCE(tier, 0), promotion_places, relegation_places, playoff_places, " +
+	"two_legged, away_goals_rule, group_count, group_qualifiers, created_at, match_engine, engine_params"
+
+// defaultTieBreakers is the chain given to competitions created without one
+const defaultTieBreakers = "goal_difference,goals_for,head_to_head_points,head_to_head_goal_difference,away_goals,wins,fair_play,drawing_of_lots"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanCompetition reads a competition row selected with competitionColumns
+func scanCompetition(row rowScanner) (models.Competition, error) {
+	var competition models.Competition
+	var tieBreakers, engineParams string
+	var createdAt sql.NullTime
+	
+	err := row.Scan(
+		&competition.ID, &competition.Name, &tieBreakers, &competition.LotsSeed, &competition.Format, &competition.Tier,
+		&competition.PromotionPlaces, &competition.RelegationPlaces, &competition.PlayoffPlaces,
+		&competition.TwoLegged, &competition.AwayGoalsRule, &competition.GroupCount, &competition.GroupQualifiers, &createdAt,
+		&competition.MatchEngine, &engineParams,
+	)
+	if err != nil {
+		return competition, err
+	}
+	
+	if err := json.Unmarshal([]byte(engineParams), &competition.EngineParams); err != nil {
+		return competition, fmt.Errorf("failed to read match engine parameters: %v", err)
+	}
+	if len(competition.EngineParams) == 0 {
+		competition.EngineParams = nil
+	}
+	
+	competition.TieBreakers = parseTieBreakers(tieBreakers)
+	if createdAt.Valid {
+		competition.CreatedAt = createdAt.Time
+	}
+	
+	return competition, nil
+}
+
+// engineParamsJSON encodes the match engine parameters of a competition for storage
+func engineParamsJSON(params map[string]float64) string {
+	if len(params) == 0 {
+		return "{}"
+	}
+	encoded, _ := json.Marshal(params)
+	return string(encoded)
+}
+
+// loadCompetition reads a single competition
+func loadCompetition(q querier, id int) (models.Competition, error) {
+	return scanCompetition(q.QueryRow(
+		"SELECT "+competitionColumns+" FROM competitions WHERE id = $1", id,
+	))
+}
+
+// loadDefaultCompetition reads the competition the league endpoints work on,
+// which is the top division
+func loadDefaultCompetition(q querier) (models.Competition, error) {
+	return scanCompetition(q.QueryRow(
+		"SELECT "+competitionColumns+" FROM competitions WHERE format = $1 ORDER BY tier, id LIMIT 1",
+		models.FormatLeague,
+	))
+}
@@ -0,0 +1,643 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// cupLeg is the outcome of one match of a cup tie. The score includes
+// extra time; penalties are only set when the tie went to a shootout.
+type cupLeg struct {
+	HomeTeamID    int
+	AwayTeamID    int
+	HomeScore     int
+	AwayScore     int
+	ExtraTime     bool
+	HomeExtra     int
+	AwayExtra     int
+	HomePenalties *int
+	AwayPenalties *int
+}
+
+// cupOutcome is the result of a whole cup tie
+type cupOutcome struct {
+	Legs      []cupLeg
+	WinnerID  int
+	DecidedBy string
+}
+
+// playCupTie plays a cup tie between two teams with the cup's match engine. A
+// single-leg tie is hosted by homeID; in a two-legged tie awayID hosts the
+// second leg. When the aggregate is level the away goals rule is applied if
+// enabled, then extra time is played at the end of the last leg, after which
+// away goals count again, and finally penalties decide.
+func playCupTie(rng *rand.Rand, engine MatchEngine, strengths map[int]teamStrength, cfg *config.Config, homeID, awayID int, twoLegged, awayGoalsRule bool) cupOutcome {
+	play := func(home, away int) cupLeg {
+		homeScore, awayScore := engine.Score(rng, strengthOf(strengths, home), strengthOf(strengths, away))
+		return cupLeg{HomeTeamID: home, AwayTeamID: away, HomeScore: homeScore, AwayScore: awayScore}
+	}
+
+	outcome := cupOutcome{Legs: []cupLeg{play(homeID, awayID)}}
+	if twoLegged {
+		outcome.Legs = append(outcome.Legs, play(awayID, homeID))
+	}
+	last := &outcome.Legs[len(outcome.Legs)-1]
+
+	// decide reports the winner on aggregate, or on away goals when the rule applies
+	decide := func(decidedBy string) bool {
+		homeTotal, awayTotal := cupAggregate(outcome.Legs, homeID)
+		if homeTotal != awayTotal {
+			outcome.WinnerID = homeID
+			if awayTotal > homeTotal {
+				outcome.WinnerID = awayID
+			}
+			outcome.DecidedBy = decidedBy
+			return true
+		}
+
+		if twoLegged && awayGoalsRule {
+			// homeID plays away in the second leg and awayID in the first
+			homeAway, awayAway := outcome.Legs[1].AwayScore, outcome.Legs[0].AwayScore
+			if homeAway != awayAway {
+				outcome.WinnerID = homeID
+				if awayAway > homeAway {
+					outcome.WinnerID = awayID
+				}
+				outcome.DecidedBy = models.DecidedByAwayGoals
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if decide(models.DecidedByScore) {
+		return outcome
+	}
+
+	lastHome, lastAway := strengthOf(strengths, last.HomeTeamID), strengthOf(strengths, last.AwayTeamID)
+	homeExtra, awayExtra := simulateExtraTime(rng, lastHome, lastAway, cfg)
+	last.HomeScore += homeExtra
+	last.AwayScore += awayExtra
+	last.ExtraTime = true
+	last.HomeExtra, last.AwayExtra = homeExtra, awayExtra
+	if decide(models.DecidedByExtraTime) {
+		return outcome
+	}
+
+	homePenalties, awayPenalties := simulateShootout(rng, lastHome, lastAway, cfg)
+	last.HomePenalties = &homePenalties
+	last.AwayPenalties = &awayPenalties
+	outcome.WinnerID = last.HomeTeamID
+	if awayPenalties > homePenalties {
+		outcome.WinnerID = last.AwayTeamID
+	}
+	outcome.DecidedBy = models.DecidedByPenalties
+
+	return outcome
+}
+
+// cupAggregate returns the goals scored over all legs by the team listed
+// first in the tie and by its opponent
+func cupAggregate(legs []cupLeg, homeID int) (int, int) {
+	homeTotal, awayTotal := 0, 0
+	for _, leg := range legs {
+		if leg.HomeTeamID == homeID {
+			homeTotal += leg.HomeScore
+			awayTotal += leg.AwayScore
+		} else {
+			homeTotal += leg.AwayScore
+			awayTotal += leg.HomeScore
+		}
+	}
+	return homeTotal, awayTotal
+}
+
+// cupRoundName names a round after the number of ties in it
+func cupRoundName(round, ties int) string {
+	switch ties {
+	case 1:
+		return "Final"
+	case 2:
+		return "Semi-finals"
+	case 4:
+		return "Quarter-finals"
+	}
+	return "Round " + strconv.Itoa(round)
+}
+
+// Errors returned when a cup round cannot be drawn or played yet
+var (
+	errCupFinished     = errors.New("the cup already has a winner")
+	errCupRoundPending = errors.New("the current round has not been played yet")
+	errCupNotDrawn     = errors.New("there is no drawn round to play; draw the next round first")
+)
+
+// cupTieRow is a cup_ties row as stored
+type cupTieRow struct {
+	ID          int
+	Round       int
+	Position    int
+	HomeTeamID  int
+	AwayTeamID  sql.NullInt64
+	FirstLegID  sql.NullInt64
+	SecondLegID sql.NullInt64
+	WinnerID    sql.NullInt64
+	DecidedBy   sql.NullString
+}
+
+// loadCupTies returns a cup season's ties, optionally only those of one round
+func loadCupTies(q querier, seasonID, round int) ([]cupTieRow, error) {
+	rows, err := q.Query(`
+		SELECT id, round, position, home_team_id, away_team_id,
+		       first_leg_id, second_leg_id, winner_id, decided_by
+		FROM cup_ties
+		WHERE season_id = $1 AND ($2 = 0 OR round = $2)
+		ORDER BY round, position
+	`, seasonID, round)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ties []cupTieRow
+	for rows.Next() {
+		var tie cupTieRow
+		err := rows.Scan(
+			&tie.ID, &tie.Round, &tie.Position, &tie.HomeTeamID, &tie.AwayTeamID,
+			&tie.FirstLegID, &tie.SecondLegID, &tie.WinnerID, &tie.DecidedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+		ties = append(ties, tie)
+	}
+
+	return ties, rows.Err()
+}
+
+// currentCupRound returns the latest round drawn in a cup season, or 0
+func currentCupRound(q querier, seasonID int) (int, error) {
+	var round int
+	err := q.QueryRow("SELECT COALESCE(MAX(round), 0) FROM cup_ties WHERE season_id = $1", seasonID).Scan(&round)
+	return round, err
+}
+
+// drawCupRound draws the next round of a cup season inside the given
+// transaction. The first round is drawn from every team entered in the season
+// and gives byes to enough teams to leave a power of two; later rounds are
+// drawn from the winners of the round before. It returns the round drawn.
+func drawCupRound(tx *sql.Tx, season models.Season, competition models.Competition, rng *rand.Rand) (int, error) {
+	round, field, err := nextCupField(tx, season.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	if round == 0 {
+		teams, err := loadSeasonTeams(tx, season.ID)
+		if err != nil {
+			return 0, err
+		}
+		if len(teams) < 2 {
+			return 0, errNotEnoughTeams
+		}
+		for _, team := range teams {
+			field = append(field, team.ID)
+		}
+	}
+
+	rng.Shuffle(len(field), func(i, j int) {
+		field[i], field[j] = field[j], field[i]
+	})
+
+	size := 1
+	for size < len(field) {
+		size *= 2
+	}
+	byes := size - len(field)
+
+	var pairs [][2]int
+	for i := byes; i+1 < len(field); i += 2 {
+		pairs = append(pairs, [2]int{field[i], field[i+1]})
+	}
+
+	round++
+	if err := insertCupRound(tx, season.ID, competition.TwoLegged, round, field[:byes], pairs); err != nil {
+		return 0, err
+	}
+	return round, nil
+}
+
+// nextCupField returns the latest round drawn in a cup season together with
+// its winners in bracket order, ready to be drawn into the next round. It
+// returns no teams when no round has been drawn yet.
+func nextCupField(q querier, seasonID int) (int, []int, error) {
+	round, err := currentCupRound(q, seasonID)
+	if err != nil || round == 0 {
+		return round, nil, err
+	}
+
+	ties, err := loadCupTies(q, seasonID, round)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var field []int
+	for _, tie := range ties {
+		if !tie.WinnerID.Valid {
+			return 0, nil, errCupRoundPending
+		}
+		field = append(field, int(tie.WinnerID.Int64))
+	}
+	if len(field) == 1 {
+		return 0, nil, errCupFinished
+	}
+
+	return round, field, nil
+}
+
+// insertCupRound stores a drawn round: a tie decided straight away for every
+// team given a bye, then the pairs in order. The first team of a pair hosts the
+// first leg. The legs are scheduled in the weeks after the season's last match.
+func insertCupRound(tx *sql.Tx, seasonID int, twoLegged bool, round int, byes []int, pairs [][2]int) error {
+	position := 0
+	for _, teamID := range byes {
+		position++
+		_, err := tx.Exec(`
+			INSERT INTO cup_ties (season_id, round, position, home_team_id, winner_id, decided_by)
+			VALUES ($1, $2, $3, $4, $4, $5)
+		`, seasonID, round, position, teamID, models.DecidedByBye)
+		if err != nil {
+			return fmt.Errorf("failed to store bye: %v", err)
+		}
+	}
+
+	// Legs are numbered as weeks: a single-leg round is one week, a two-legged round two
+	var firstWeek int
+	err := tx.QueryRow("SELECT COALESCE(MAX(week), 0) + 1 FROM matches WHERE season_id = $1", seasonID).Scan(&firstWeek)
+	if err != nil {
+		return fmt.Errorf("failed to get the next week: %v", err)
+	}
+
+	for _, pair := range pairs {
+		position++
+		home, away := pair[0], pair[1]
+
+		firstLegID, err := insertCupLeg(tx, seasonID, home, away, firstWeek)
+		if err != nil {
+			return err
+		}
+
+		var secondLegID sql.NullInt64
+		if twoLegged {
+			id, err := insertCupLeg(tx, seasonID, away, home, firstWeek+1)
+			if err != nil {
+				return err
+			}
+			secondLegID = sql.NullInt64{Int64: int64(id), Valid: true}
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO cup_ties (season_id, round, position, home_team_id, away_team_id, first_leg_id, second_leg_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, seasonID, round, position, home, away, firstLegID, secondLegID)
+		if err != nil {
+			return fmt.Errorf("failed to store tie: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// insertCupLeg stores an unplayed cup match and returns its ID
+func insertCupLeg(tx *sql.Tx, seasonID, homeID, awayID, week int) (int, error) {
+	var id int
+	err := tx.QueryRow(
+		"INSERT INTO matches (season_id, home_team_id, away_team_id, week, played) VALUES ($1, $2, $3, $4, false) RETURNING id",
+		seasonID, homeID, awayID, week,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to store cup match: %v", err)
+	}
+	return id, nil
+}
+
+// playCupRound plays every undecided tie of the latest drawn round inside
+// the given transaction and returns the round played
+func playCupRound(tx *sql.Tx, season models.Season, competition models.Competition, seed int64, strengths map[int]teamStrength, cfg *config.Config) (int, error) {
+	round, err := currentCupRound(tx, season.ID)
+	if err != nil {
+		return 0, err
+	}
+	if round == 0 {
+		return 0, errCupNotDrawn
+	}
+
+	ties, err := loadCupTies(tx, season.ID, round)
+	if err != nil {
+		return 0, err
+	}
+
+	engine, err := competitionEngine(competition, cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	squads, err := loadSquads(tx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load squads: %v", err)
+	}
+	form, err := loadFormTracker(tx, season.ID, cfg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load team form: %v", err)
+	}
+	availability, err := loadAvailability(tx, season.ID, squads, cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	// Timelines and injuries come from their own streams so they do not change the results
+	rng := rand.New(rand.NewSource(seed))
+	timelineRng := rand.New(rand.NewSource(seed))
+	injuryRng := rand.New(rand.NewSource(seed))
+	var played []eloMatch
+	for _, tie := range ties {
+		if tie.WinnerID.Valid {
+			continue
+		}
+
+		// The legs are played in consecutive weeks
+		var firstWeek int
+		if err := tx.QueryRow("SELECT week FROM matches WHERE id = $1", tie.FirstLegID.Int64).Scan(&firstWeek); err != nil {
+			return 0, fmt.Errorf("failed to get the week of tie %d: %v", tie.ID, err)
+		}
+
+		// Both teams play the whole tie with the absences and form they start it with
+		awayID := int(tie.AwayTeamID.Int64)
+		home := availability.strength(strengthOf(strengths, tie.HomeTeamID), tie.HomeTeamID, firstWeek)
+		away := availability.strength(strengthOf(strengths, awayID), awayID, firstWeek)
+		tieStrengths := map[int]teamStrength{
+			tie.HomeTeamID: form.strength(home, tie.HomeTeamID),
+			awayID:         form.strength(away, awayID),
+		}
+		outcome := playCupTie(rng, engine, tieStrengths, cfg, tie.HomeTeamID, awayID, tie.SecondLegID.Valid, competition.AwayGoalsRule)
+
+		legIDs := []int64{tie.FirstLegID.Int64, tie.SecondLegID.Int64}
+		for i, leg := range outcome.Legs {
+			week := firstWeek + i
+			timeline := simulateTimeline(timelineRng, timelineMatch{
+				HomeTeamID:    leg.HomeTeamID,
+				AwayTeamID:    leg.AwayTeamID,
+				HomeGoals:     leg.HomeScore - leg.HomeExtra,
+				AwayGoals:     leg.AwayScore - leg.AwayExtra,
+				HomeExtra:     leg.HomeExtra,
+				AwayExtra:     leg.AwayExtra,
+				ExtraTime:     leg.ExtraTime,
+				HomePenalties: leg.HomePenalties,
+				AwayPenalties: leg.AwayPenalties,
+			}, availability.matchSquads(week, leg.HomeTeamID, leg.AwayTeamID))
+
+			_, err := tx.Exec(`
+				UPDATE matches SET
+				home_score = $1,
+				away_score = $2,
+				played = true,
+				seed = $3,
+				extra_time = $4,
+				home_penalties = $5,
+				away_penalties = $6,
+				home_yellow_cards = $7,
+				home_red_cards = $8,
+				away_yellow_cards = $9,
+				away_red_cards = $10,
+				engine = $11,
+				played_at = CURRENT_TIMESTAMP
+				WHERE id = $12
+			`, leg.HomeScore, leg.AwayScore, seed, leg.ExtraTime, leg.HomePenalties, leg.AwayPenalties,
+				timeline.HomeYellowCards, timeline.HomeRedCards, timeline.AwayYellowCards, timeline.AwayRedCards, engine.Name(), legIDs[i])
+			if err != nil {
+				return 0, fmt.Errorf("failed to update match %d: %v", legIDs[i], err)
+			}
+
+			if err := storeTimeline(tx, int(legIDs[i]), timeline); err != nil {
+				return 0, err
+			}
+			if err := availability.record(tx, injuryRng, int(legIDs[i]), week, leg.HomeTeamID, leg.AwayTeamID, timeline); err != nil {
+				return 0, err
+			}
+			form.record(leg.HomeTeamID, leg.AwayTeamID, leg.HomeScore, leg.AwayScore)
+			played = append(played, eloMatch{
+				MatchID:    int(legIDs[i]),
+				HomeTeamID: leg.HomeTeamID,
+				AwayTeamID: leg.AwayTeamID,
+				HomeScore:  leg.HomeScore,
+				AwayScore:  leg.AwayScore,
+			})
+		}
+
+		_, err := tx.Exec(
+			"UPDATE cup_ties SET winner_id = $1, decided_by = $2 WHERE id = $3",
+			outcome.WinnerID, outcome.DecidedBy, tie.ID,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update tie %d: %v", tie.ID, err)
+		}
+	}
+
+	if len(played) == 0 {
+		return 0, errCupNotDrawn
+	}
+
+	if err := recordEloRatings(tx, played, cfg); err != nil {
+		return 0, err
+	}
+
+	return round, nil
+}
+
+// loadCupBracket builds the bracket of a cup season with every leg played so far
+func loadCupBracket(q querier, competition models.Competition, season models.Season) (models.CupBracket, error) {
+	bracket := models.CupBracket{Competition: competition, Season: season, Rounds: []models.CupRound{}}
+
+	ties, err := loadCupTies(q, season.ID, 0)
+	if err != nil {
+		return bracket, err
+	}
+
+	teams, err := loadSeasonTeams(q, season.ID)
+	if err != nil {
+		return bracket, err
+	}
+	names := make(map[int]models.Team, len(teams))
+	for _, team := range teams {
+		names[team.ID] = team
+	}
+
+	legs, err := loadCupLegs(q, season.ID)
+	if err != nil {
+		return bracket, err
+	}
+
+	for _, row := range ties {
+		if len(bracket.Rounds) < row.Round {
+			bracket.Rounds = append(bracket.Rounds, models.CupRound{Round: row.Round})
+		}
+
+		tie := models.CupTie{
+			ID:        row.ID,
+			Round:     row.Round,
+			Position:  row.Position,
+			HomeTeam:  names[row.HomeTeamID],
+			Legs:      []models.Match{},
+			DecidedBy: row.DecidedBy.String,
+		}
+		if row.AwayTeamID.Valid {
+			away := names[int(row.AwayTeamID.Int64)]
+			tie.AwayTeam = &away
+		}
+		if row.WinnerID.Valid {
+			winner := names[int(row.WinnerID.Int64)]
+			tie.Winner = &winner
+		}
+
+		for _, legID := range []sql.NullInt64{row.FirstLegID, row.SecondLegID} {
+			leg, ok := legs[int(legID.Int64)]
+			if !legID.Valid || !ok {
+				continue
+			}
+			leg.HomeTeam = names[leg.HomeTeamID]
+			leg.AwayTeam = names[leg.AwayTeamID]
+			tie.Legs = append(tie.Legs, leg)
+
+			if leg.Played {
+				if leg.HomeTeamID == row.HomeTeamID {
+					tie.HomeAggregate += *leg.HomeScore
+					tie.AwayAggregate += *leg.AwayScore
+				} else {
+					tie.HomeAggregate += *leg.AwayScore
+					tie.AwayAggregate += *leg.HomeScore
+				}
+			}
+		}
+
+		current := &bracket.Rounds[row.Round-1]
+		current.Ties = append(current.Ties, tie)
+	}
+
+	for i := range bracket.Rounds {
+		bracket.Rounds[i].Name = cupRoundName(bracket.Rounds[i].Round, len(bracket.Rounds[i].Ties))
+	}
+
+	// The cup is won once a round of a single tie has a winner
+	if n := len(bracket.Rounds); n > 0 {
+		final := bracket.Rounds[n-1]
+		if len(final.Ties) == 1 && final.Ties[0].Winner != nil {
+			bracket.Champion = final.Ties[0].Winner
+		}
+	}
+
+	return bracket, nil
+}
+
+// loadCupLegs returns every match of a cup season keyed by ID
+func loadCupLegs(q querier, seasonID int) (map[int]models.Match, error) {
+	rows, err := q.Query(`
+		SELECT id, season_id, home_team_id, away_team_id, home_score, away_score,
+		       week, played, seed, engine, extra_time, home_penalties, away_penalties
+		FROM matches
+		WHERE season_id = $1
+	`, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	legs := make(map[int]models.Match)
+	for rows.Next() {
+		var match models.Match
+		err := rows.Scan(
+			&match.ID, &match.SeasonID, &match.HomeTeamID, &match.AwayTeamID, &match.HomeScore, &match.AwayScore,
+			&match.Week, &match.Played, &match.Seed, &match.Engine, &match.ExtraTime, &match.HomePenalties, &match.AwayPenalties,
+		)
+		if err != nil {
+			return nil, err
+		}
+		legs[match.ID] = match
+	}
+
+	return legs, rows.Err()
+}
+
+// enterCupTeams enters every team playing in an active division season in a cup season
+func enterCupTeams(tx *sql.Tx, seasonID int) error {
+	_, err := tx.Exec(`
+		INSERT INTO league_table (season_id, team_id)
+		SELECT $1, lt.team_id
+		FROM league_table lt
+		JOIN seasons s ON lt.season_id = s.id
+		JOIN competitions c ON s.competition_id = c.id
+		WHERE s.status = $2 AND c.format = $3
+		ORDER BY lt.team_id
+	`, seasonID, models.SeasonActive, models.FormatLeague)
+	return err
+}
+
+// rollOverCups archives the active season of every cup and tournament and
+// starts the next edition with the teams of the new division seasons, inside
+// the given transaction. Ties still undecided stay that way in the archived edition.
+func rollOverCups(tx *sql.Tx, name string) ([]models.Season, error) {
+	rows, err := tx.Query(`
+		SELECT s.id, s.competition_id
+		FROM seasons s
+		JOIN competitions c ON s.competition_id = c.id
+		WHERE s.status = $1 AND c.format <> $2
+		ORDER BY c.id
+	`, models.SeasonActive, models.FormatLeague)
+	if err != nil {
+		return nil, err
+	}
+
+	var editions [][2]int
+	for rows.Next() {
+		var seasonID, competitionID int
+		if err := rows.Scan(&seasonID, &competitionID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		editions = append(editions, [2]int{seasonID, competitionID})
+	}
+	rows.Close()
+
+	var seasons []models.Season
+	for _, edition := range editions {
+		_, err := tx.Exec(
+			"UPDATE seasons SET status = $1, archived_at = CURRENT_TIMESTAMP WHERE id = $2",
+			models.SeasonArchived, edition[0],
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to archive season %d: %v", edition[0], err)
+		}
+
+		season, err := scanSeason(tx.QueryRow(`
+			INSERT INTO seasons (competition_id, name, status)
+			VALUES ($1, $2, $3)
+			RETURNING id, competition_id, name, status, started_at, archived_at
+		`, edition[1], name, models.SeasonActive))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create season for competition %d: %v", edition[1], err)
+		}
+
+		if err := enterCupTeams(tx, season.ID); err != nil {
+			return nil, fmt.Errorf("failed to enter teams in season %d: %v", season.ID, err)
+		}
+		seasons = append(seasons, season)
+	}
+
+	return seasons, nil
+}
@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"math/rand"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/database"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// GetCupBracket handles the request to view the bracket of a cup's current
+// edition, or of the cup season given in the route
+func GetCupBracket(c *fiber.Ctx) error {
+	competition, season, status, msg := requestCompetitionSeason(c, models.FormatCup)
+	if msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	bracket, err := loadCupBracket(database.DB, competition, season)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get bracket: " + err.Error(),
+		})
+	}
+
+	return c.JSON(bracket)
+}
+
+// DrawCupRound handles the request to draw the next round of a cup. The first
+// round is drawn from every team entered in the edition, later rounds from the
+// winners of the round before, which must have been played. The draw is
+// random unless a seed is given with ?seed=.
+func DrawCupRound(c *fiber.Ctx) error {
+	seed, err := simulationSeed(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid seed: " + err.Error(),
+		})
+	}
+
+	competition, season, status, msg := requestCompetitionSeason(c, models.FormatCup)
+	if msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	if season.Status != models.SeasonActive {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Season " + season.Name + " is archived and cannot be drawn",
+		})
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction: " + err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	// Lock the season so two draws cannot interleave
+	if _, err := tx.Exec("SELECT id FROM seasons WHERE id = $1 FOR UPDATE", season.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to lock season: " + err.Error(),
+		})
+	}
+
+	round, err := drawCupRound(tx, season, competition, rand.New(rand.NewSource(seed)))
+	if err == errCupFinished || err == errCupRoundPending || err == errNotEnoughTeams {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Failed to draw the next round: " + err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to draw the next round: " + err.Error(),
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction: " + err.Error(),
+		})
+	}
+
+	bracket, err := loadCupBracket(database.DB, competition, season)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get bracket after the draw",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Successfully drew round " + strconv.Itoa(round),
+		"seed":    seed,
+		"round":   bracket.Rounds[round-1],
+	})
+}
+
+// SimulateCupRound handles the request to play every tie of the round drawn
+// last. Level ties go to extra time and then penalties, after the away goals
+// rule when the cup uses it.
+func SimulateCupRound(c *fiber.Ctx) error {
+	seed, err := simulationSeed(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid seed: " + err.Error(),
+		})
+	}
+
+	competition, season, status, msg := requestCompetitionSeason(c, models.FormatCup)
+	if msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	if season.Status != models.SeasonActive {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Season " + season.Name + " is archived and cannot be simulated",
+		})
+	}
+
+	strengths, err := loadTeamStrengths()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load team ratings: " + err.Error(),
+		})
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction: " + err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT id FROM seasons WHERE id = $1 FOR UPDATE", season.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to lock season: " + err.Error(),
+		})
+	}
+
+	round, err := playCupRound(tx, season, competition, seed, strengths, config.GetConfig())
+	if err == errCupNotDrawn {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Failed to simulate round: " + err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to simulate round: " + err.Error(),
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction: " + err.Error(),
+		})
+	}
+
+	bracket, err := loadCupBracket(database.DB, competition, season)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get bracket after simulation",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":  "Successfully simulated " + bracket.Rounds[round-1].Name,
+		"seed":     seed,
+		"round":    bracket.Rounds[round-1],
+		"champion": bracket.Champion,
+	})
+}
+
+// requestCompetitionSeason returns the competition and season a cup or
+// tournament endpoint works on: the season named by the :seasonId route
+// parameter, or the active season of the competition named by :id. The
+// competition must have the given format. It returns a status and message
+// when the request cannot be served.
+func requestCompetitionSeason(c *fiber.Ctx, format string) (models.Competition, models.Season, int, string) {
+	var season models.Season
+	var competition models.Competition
+
+	if c.Params("seasonId") != "" {
+		var err error
+		season, err = requestSeason(c)
+		if err != nil {
+			return competition, season, fiber.StatusNotFound, "Season not found"
+		}
+		competition, err = loadCompetition(database.DB, season.CompetitionID)
+		if err != nil {
+			return competition, season, fiber.StatusInternalServerError, "Failed to get competition: " + err.Error()
+		}
+	} else {
+		id, err := strconv.Atoi(c.Params("id"))
+		if err != nil {
+			return competition, season, fiber.StatusBadRequest, "Invalid competition ID"
+		}
+		competition, err = loadCompetition(database.DB, id)
+		if err != nil {
+			return competition, season, fiber.StatusNotFound, "Competition not found"
+		}
+		season, err = loadActiveSeason(database.DB, competition.ID)
+		if err != nil {
+			return competition, season, fiber.StatusNotFound, "Competition has no active season"
+		}
+	}
+
+	if competition.Format != format {
+		return competition, season, fiber.StatusConflict, competition.Name + " is not a " + format
+	}
+
+	return competition, season, 0, ""
+}
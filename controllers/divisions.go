@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strconv"
+
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// division is one tier of the pyramid with its active season and the
+// season's final table
+type division struct {
+	Competition models.Competition
+	Season      models.Season
+	Standings   []models.TeamStats
+}
+
+// loadDivisions returns every league division that has an active season,
+// top tier first, with its ranked table
+func loadDivisions(q querier) ([]division, error) {
+	rows, err := q.Query("SELECT " + competitionColumns + " FROM competitions WHERE tier IS NOT NULL ORDER BY tier")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var competitions []models.Competition
+	for rows.Next() {
+		competition, err := scanCompetition(rows)
+		if err != nil {
+			return nil, err
+		}
+		competitions = append(competitions, competition)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	var divisions []division
+	for _, competition := range competitions {
+		season, err := loadActiveSeason(q, competition.ID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		standings, err := rankedSeasonTable(q, season)
+		if err != nil {
+			return nil, err
+		}
+
+		divisions = append(divisions, division{Competition: competition, Season: season, Standings: standings})
+	}
+
+	return divisions, nil
+}
+
+// promotedCount is the number of teams a division sends up, counting the
+// playoff winner
+func promotedCount(competition models.Competition) int {
+	if competition.PlayoffPlaces > 0 {
+		return competition.PromotionPlaces + 1
+	}
+	return competition.PromotionPlaces
+}
+
+// checkPyramid makes sure every pair of adjacent divisions swaps the same
+// number of teams, so no division grows or shrinks at rollover, and that no
+// team of a middle division can be both promoted and relegated. It returns a
+// message describing the first problem found.
+func checkPyramid(divisions []division) string {
+	for i := 0; i+1 < len(divisions); i++ {
+		upper, lower := divisions[i], divisions[i+1]
+		down := upper.Competition.RelegationPlaces
+		up := promotedCount(lower.Competition)
+
+		if down != up {
+			return upper.Competition.Name + " relegates " + strconv.Itoa(down) + " teams but " +
+				lower.Competition.Name + " promotes " + strconv.Itoa(up)
+		}
+		if down > len(upper.Standings) {
+			return upper.Competition.Name + " has fewer than " + strconv.Itoa(down) + " teams to relegate"
+		}
+		if lower.Competition.PromotionPlaces+lower.Competition.PlayoffPlaces > len(lower.Standings) {
+			return lower.Competition.Name + " has fewer teams than promotion and playoff places"
+		}
+	}
+
+	// A division in the middle sends teams both ways, so its promotion,
+	// playoff and relegation places must not overlap
+	for i := 1; i+1 < len(divisions); i++ {
+		middle := divisions[i].Competition
+		if middle.PromotionPlaces+middle.PlayoffPlaces+middle.RelegationPlaces > len(divisions[i].Standings) {
+			return middle.Name + " has fewer teams than promotion, playoff and relegation places"
+		}
+	}
+	return ""
+}
+
+// planMovements decides which teams change division. The bottom
+// relegation_places teams of a division go down, and the top promotion_places
+// teams of the division below go up together with the winner of its playoff.
+// Divisions at the top and bottom of the pyramid have nowhere to send teams,
+// so their promotion and relegation places are ignored. A playoff is played
+// with the match engine of its division.
+func planMovements(divisions []division, strengths map[int]teamStrength, rng *rand.Rand, cfg *config.Config) ([]models.TeamMovement, []models.PlayoffMatch, error) {
+	var movements []models.TeamMovement
+	var playoffs []models.PlayoffMatch
+
+	for i := 0; i+1 < len(divisions); i++ {
+		upper, lower := divisions[i], divisions[i+1]
+
+		relegated := upper.Standings[len(upper.Standings)-upper.Competition.RelegationPlaces:]
+		for _, stats := range relegated {
+			movements = append(movements, models.TeamMovement{
+				Team:              stats.Team,
+				FromCompetitionID: upper.Competition.ID,
+				ToCompetitionID:   lower.Competition.ID,
+				FromSeasonID:      upper.Season.ID,
+				Movement:          models.MovementRelegated,
+			})
+		}
+
+		promotion := lower.Competition.PromotionPlaces
+		for _, stats := range lower.Standings[:promotion] {
+			movements = append(movements, models.TeamMovement{
+				Team:              stats.Team,
+				FromCompetitionID: lower.Competition.ID,
+				ToCompetitionID:   upper.Competition.ID,
+				FromSeasonID:      lower.Season.ID,
+				Movement:          models.MovementPromoted,
+			})
+		}
+
+		if lower.Competition.PlayoffPlaces > 0 {
+			contenders := lower.Standings[promotion : promotion+lower.Competition.PlayoffPlaces]
+			engine, err := competitionEngine(lower.Competition, cfg)
+			if err != nil {
+				return nil, nil, err
+			}
+			winner, matches := playPlayoff(lower.Competition.ID, contenders, engine, strengths, rng)
+			playoffs = append(playoffs, matches...)
+			movements = append(movements, models.TeamMovement{
+				Team:              winner,
+				FromCompetitionID: lower.Competition.ID,
+				ToCompetitionID:   upper.Competition.ID,
+				FromSeasonID:      lower.Season.ID,
+				Movement:          models.MovementPromoted,
+				ViaPlayoff:        true,
+			})
+		}
+	}
+
+	return movements, playoffs, nil
+}
+
+// playPlayoff plays a single-match knockout between the playoff contenders,
+// given in their final league order, and returns the winner. Each round pairs
+// the best remaining team with the worst; the better-placed team hosts and
+// goes through if the match is drawn. When the field is not a power of two
+// the best-placed teams get a bye in the first round.
+func playPlayoff(competitionID int, contenders []models.TeamStats, engine MatchEngine, strengths map[int]teamStrength, rng *rand.Rand) (models.Team, []models.PlayoffMatch) {
+	field := make([]models.Team, len(contenders))
+	for i, stats := range contenders {
+		field[i] = stats.Team
+	}
+
+	var matches []models.PlayoffMatch
+	for round := 1; len(field) > 1; round++ {
+		size := 1
+		for size < len(field) {
+			size *= 2
+		}
+		byes := size - len(field)
+
+		next := append([]models.Team{}, field[:byes]...)
+		playing := field[byes:]
+		for i := 0; i < len(playing)/2; i++ {
+			home, away := playing[i], playing[len(playing)-1-i]
+			homeScore, awayScore := engine.Score(rng, strengthOf(strengths, home.ID), strengthOf(strengths, away.ID))
+
+			winner := home
+			if awayScore > homeScore {
+				winner = away
+			}
+
+			matches = append(matches, models.PlayoffMatch{
+				CompetitionID: competitionID,
+				Round:         round,
+				HomeTeam:      home,
+				AwayTeam:      away,
+				HomeScore:     homeScore,
+				AwayScore:     awayScore,
+				WinnerID:      winner.ID,
+				Engine:        engine.Name(),
+			})
+			next = append(next, winner)
+		}
+
+		field = next
+	}
+
+	return field[0], matches
+}
+
+// rollOverDivisions archives the active season of every division, starts the
+// next one with the teams that stay or arrive, records the movements and
+// generates the new fixtures, all inside the given transaction
+func rollOverDivisions(tx *sql.Tx, divisions []division, movements []models.TeamMovement, name string) ([]models.Season, error) {
+	// Every team stays in its division unless it moves
+	destination := make(map[int]int)
+	for _, d := range divisions {
+		for _, stats := range d.Standings {
+			destination[stats.Team.ID] = d.Competition.ID
+		}
+	}
+	for _, movement := range movements {
+		destination[movement.Team.ID] = movement.ToCompetitionID
+	}
+
+	newSeasons := make(map[int]int, len(divisions))
+	seasons := make([]models.Season, 0, len(divisions))
+	for _, d := range divisions {
+		_, err := tx.Exec(
+			"UPDATE seasons SET status = $1, archived_at = CURRENT_TIMESTAMP WHERE id = $2",
+			models.SeasonArchived, d.Season.ID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to archive season %d: %v", d.Season.ID, err)
+		}
+
+		season, err := scanSeason(tx.QueryRow(`
+			INSERT INTO seasons (competition_id, name, status)
+			VALUES ($1, $2, $3)
+			RETURNING id, competition_id, name, status, started_at, archived_at
+		`, d.Competition.ID, name, models.SeasonActive))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create season for %s: %v", d.Competition.Name, err)
+		}
+
+		newSeasons[d.Competition.ID] = season.ID
+		seasons = append(seasons, season)
+	}
+
+	// Enter the teams in the new seasons in a fixed order
+	for _, d := range divisions {
+		for _, other := range divisions {
+			for _, stats := range other.Standings {
+				if destination[stats.Team.ID] != d.Competition.ID {
+					continue
+				}
+				_, err := tx.Exec(
+					"INSERT INTO league_table (season_id, team_id) VALUES ($1, $2)",
+					newSeasons[d.Competition.ID], stats.Team.ID,
+				)
+				if err != nil {
+					return nil, fmt.Errorf("failed to enter team %d: %v", stats.Team.ID, err)
+				}
+			}
+		}
+	}
+
+	for i := range movements {
+		movement := &movements[i]
+		movement.ToSeasonID = newSeasons[movement.ToCompetitionID]
+		_, err := tx.Exec(`
+			INSERT INTO team_movements (team_id, from_season_id, to_season_id, movement, via_playoff)
+			VALUES ($1, $2, $3, $4, $5)
+		`, movement.Team.ID, movement.FromSeasonID, movement.ToSeasonID, movement.Movement, movement.ViaPlayoff)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record movement of team %d: %v", movement.Team.ID, err)
+		}
+	}
+
+	for _, season := range seasons {
+		if err := insertFixtures(tx, season.ID); err != nil && err != errNotEnoughTeams {
+			return nil, fmt.Errorf("failed to generate fixtures for season %d: %v", season.ID, err)
+		}
+	}
+
+	return seasons, nil
+}
+
+// loadSeasonMovements returns the teams that left or joined a season at rollover
+func loadSeasonMovements(q querier, seasonID int) ([]models.TeamMovement, error) {
+	rows, err := q.Query(`
+		SELECT t.id, t.name, fs.competition_id, ts.competition_id,
+		       tm.from_season_id, tm.to_season_id, tm.movement, tm.via_playoff
+		FROM team_movements tm
+		JOIN teams t ON tm.team_id = t.id
+		JOIN seasons fs ON tm.from_season_id = fs.id
+		JOIN seasons ts ON tm.to_season_id = ts.id
+		WHERE tm.from_season_id = $1 OR tm.to_season_id = $1
+		ORDER BY tm.id
+	`, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []models.TeamMovement
+	for rows.Next() {
+		var movement models.TeamMovement
+		err := rows.Scan(
+			&movement.Team.ID, &movement.Team.Name, &movement.FromCompetitionID, &movement.ToCompetitionID,
+			&movement.FromSeasonID, &movement.ToSeasonID, &movement.Movement, &movement.ViaPlayoff,
+		)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, movement)
+	}
+
+	return movements, rows.Err()
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// makeDivision builds a division of the given tier whose n teams finished in
+// ID order, team tier*100+1 on top
+func makeDivision(tier, n, promotion, playoff, relegation int) division {
+	d := division{
+		Competition: models.Competition{
+			ID:               tier,
+			Name:             "Tier " + string(rune('0'+tier)),
+			Format:           models.FormatLeague,
+			Tier:             tier,
+			PromotionPlaces:  promotion,
+			PlayoffPlaces:    playoff,
+			RelegationPlaces: relegation,
+		},
+		Season: models.Season{ID: 10 + tier, CompetitionID: tier},
+	}
+	for i := 1; i <= n; i++ {
+		d.Standings = append(d.Standings, models.TeamStats{
+			Position: i,
+			Team:     models.Team{ID: tier*100 + i},
+		})
+	}
+	return d
+}
+
+func TestCheckPyramidThreeTiers(t *testing.T) {
+	valid := []division{
+		makeDivision(1, 4, 0, 0, 2),
+		makeDivision(2, 6, 1, 2, 2),
+		makeDivision(3, 4, 2, 0, 0),
+	}
+	if msg := checkPyramid(valid); msg != "" {
+		t.Fatalf("valid pyramid rejected: %s", msg)
+	}
+
+	// The middle division promotes 2 and relegates 3 of its 4 teams, so its
+	// second-placed team would go both up and down
+	overlapping := []division{
+		makeDivision(1, 4, 0, 0, 2),
+		makeDivision(2, 4, 2, 0, 3),
+		makeDivision(3, 4, 3, 0, 0),
+	}
+	msg := checkPyramid(overlapping)
+	if !strings.Contains(msg, "Tier 2") {
+		t.Fatalf("overlapping middle division accepted, got %q", msg)
+	}
+
+	// The same places fit when the middle division has enough teams
+	overlapping[1] = makeDivision(2, 5, 2, 0, 3)
+	if msg := checkPyramid(overlapping); msg != "" {
+		t.Fatalf("middle division with room for every place rejected: %s", msg)
+	}
+
+	mismatched := []division{
+		makeDivision(1, 4, 0, 0, 2),
+		makeDivision(2, 6, 1, 0, 2),
+		makeDivision(3, 4, 2, 0, 0),
+	}
+	if msg := checkPyramid(mismatched); msg == "" {
+		t.Fatal("pyramid swapping unequal numbers of teams accepted")
+	}
+}
+
+func TestPlanMovementsThreeTiers(t *testing.T) {
+	divisions := []division{
+		makeDivision(1, 4, 0, 0, 2),
+		makeDivision(2, 6, 1, 2, 2),
+		makeDivision(3, 4, 2, 0, 0),
+	}
+	if msg := checkPyramid(divisions); msg != "" {
+		t.Fatalf("pyramid rejected: %s", msg)
+	}
+	cfg := &config.Config{MatchEngine: "poisson", AverageGoals: 1.4, HomeAdvantage: 1.2}
+
+	for seed := int64(1); seed <= 20; seed++ {
+		movements, playoffs, err := planMovements(divisions, nil, rand.New(rand.NewSource(seed)), cfg)
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		if len(movements) != 8 {
+			t.Fatalf("seed %d: got %d movements, want 8", seed, len(movements))
+		}
+		if len(playoffs) != 1 {
+			t.Fatalf("seed %d: got %d playoff matches, want 1", seed, len(playoffs))
+		}
+
+		moved := make(map[int]models.TeamMovement)
+		for _, movement := range movements {
+			if previous, ok := moved[movement.Team.ID]; ok {
+				t.Fatalf("seed %d: team %d %s and %s", seed, movement.Team.ID, previous.Movement, movement.Movement)
+			}
+			moved[movement.Team.ID] = movement
+		}
+
+		want := map[int]string{
+			103: models.MovementRelegated, 104: models.MovementRelegated,
+			201: models.MovementPromoted,
+			205: models.MovementRelegated, 206: models.MovementRelegated,
+			301: models.MovementPromoted, 302: models.MovementPromoted,
+		}
+		for teamID, movement := range want {
+			if moved[teamID].Movement != movement {
+				t.Fatalf("seed %d: team %d got %q, want %q", seed, teamID, moved[teamID].Movement, movement)
+			}
+		}
+
+		winner := playoffs[0].WinnerID
+		if winner != 202 && winner != 203 {
+			t.Fatalf("seed %d: playoff won by team %d, not a contender", seed, winner)
+		}
+		if !moved[winner].ViaPlayoff || moved[winner].ToCompetitionID != 1 {
+			t.Fatalf("seed %d: playoff winner %d not promoted to tier 1", seed, winner)
+		}
+
+		// Every division keeps its size
+		size := make(map[int]int)
+		for _, d := range divisions {
+			for _, stats := range d.Standings {
+				to := d.Competition.ID
+				if movement, ok := moved[stats.Team.ID]; ok {
+					to = movement.ToCompetitionID
+				}
+				size[to]++
+			}
+		}
+		for _, d := range divisions {
+			if size[d.Competition.ID] != len(d.Standings) {
+				t.Fatalf("seed %d: %s ends with %d teams, want %d", seed, d.Competition.Name, size[d.Competition.ID], len(d.Standings))
+			}
+		}
+	}
+}
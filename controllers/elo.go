@@ -0,0 +1,346 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// initialEloRating is the rating of a team before its first match
+const initialEloRating = 1500.0
+
+// eloMatch is a played match as seen by the rating code
+type eloMatch struct {
+	MatchID    int
+	HomeTeamID int
+	AwayTeamID int
+	HomeScore  int
+	AwayScore  int
+	// Recorded is the home side's rating change stored for a match of an
+	// archived season, which is replayed as it stands
+	Recorded *float64
+}
+
+// eloExpected is the share of the points a side rated rating is expected to
+// take against one rated opponent
+func eloExpected(rating, opponent float64) float64 {
+	return 1 / (1 + math.Pow(10, (opponent-rating)/400))
+}
+
+// eloChange returns the rating points the home side gains, and the away side
+// loses, in a match. The home side's rating is raised by the home advantage
+// when the expected result is worked out, and wins by more than one goal move
+// the ratings further, as in the World Football Elo Ratings.
+func eloChange(homeRating, awayRating float64, homeScore, awayScore int, cfg *config.Config) float64 {
+	expected := eloExpected(homeRating+cfg.EloHomeAdvantage, awayRating)
+
+	actual := 0.5
+	if homeScore > awayScore {
+		actual = 1
+	} else if homeScore < awayScore {
+		actual = 0
+	}
+
+	margin := homeScore - awayScore
+	if margin < 0 {
+		margin = -margin
+	}
+	multiplier := 1.0
+	switch {
+	case margin == 2:
+		multiplier = 1.5
+	case margin >= 3:
+		multiplier = (11 + float64(margin)) / 8
+	}
+
+	return cfg.EloKFactor * multiplier * (actual - expected)
+}
+
+// roundRating rounds a rating to the two decimals it is stored with, so
+// ratings carried over in the database and rebuilt in memory agree
+func roundRating(rating float64) float64 {
+	return math.Round(rating*100) / 100
+}
+
+// recordEloRatings updates the ratings of the teams in the given matches,
+// which must have just been played, in the order given, and stores every
+// team's rating after each match
+func recordEloRatings(tx *sql.Tx, matches []eloMatch, cfg *config.Config) error {
+	if len(matches) == 0 {
+		return nil
+	}
+
+	rows, err := tx.Query("SELECT id, elo_rating FROM teams")
+	if err != nil {
+		return fmt.Errorf("failed to load Elo ratings: %v", err)
+	}
+	ratings := make(map[int]float64)
+	for rows.Next() {
+		var teamID int
+		var rating float64
+		if err := rows.Scan(&teamID, &rating); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan Elo rating: %v", err)
+		}
+		ratings[teamID] = rating
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load Elo ratings: %v", err)
+	}
+
+	return applyEloRatings(tx, matches, ratings, cfg)
+}
+
+// eloOrder is the order the ratings are played in, earliest first, as a row
+// of the matches table m; matches played before the clock was recorded come first
+const eloOrder = "(COALESCE(m.played_at, '-infinity'::timestamp), m.season_id, m.week, m.id)"
+
+// eloPosition is the place of a played match in the order the ratings are
+// played in
+type eloPosition struct {
+	PlayedAt sql.NullTime
+	SeasonID int
+	Week     int
+	MatchID  int
+}
+
+// firstEloPosition returns the position of the earliest played match of a
+// season, or of the match with the given ID when matchID is not 0. It returns
+// sql.ErrNoRows when there is no such played match.
+func firstEloPosition(q querier, seasonID, matchID int) (eloPosition, error) {
+	var from eloPosition
+	err := q.QueryRow(`
+		SELECT m.played_at, m.season_id, m.week, m.id
+		FROM matches m
+		WHERE m.played = true AND ($1 = 0 OR m.season_id = $1) AND ($2 = 0 OR m.id = $2)
+		ORDER BY `+eloOrder+`
+		LIMIT 1
+	`, seasonID, matchID).Scan(&from.PlayedAt, &from.SeasonID, &from.Week, &from.MatchID)
+	return from, err
+}
+
+// rebuildEloRatings replays the played matches from the given position on,
+// after a result there was corrected or matches from there were deleted. Every
+// team starts from its rating after its last match before the position, and
+// the history before it is kept. Archived seasons are read-only, so their
+// matches keep their history and the rating changes recorded in it.
+func rebuildEloRatings(tx *sql.Tx, from eloPosition, cfg *config.Config) error {
+	position := "(COALESCE($1::timestamp, '-infinity'::timestamp), $2::integer, $3::integer, $4::integer)"
+	args := []interface{}{from.PlayedAt, from.SeasonID, from.Week, from.MatchID}
+
+	_, err := tx.Exec(`
+		UPDATE teams t SET elo_rating = COALESCE((
+			SELECT h.rating
+			FROM team_ratings_history h
+			JOIN matches m ON h.match_id = m.id
+			WHERE h.team_id = t.id AND `+eloOrder+` < `+position+`
+			ORDER BY m.played_at DESC NULLS LAST, m.season_id DESC, m.week DESC, m.id DESC
+			LIMIT 1
+		), `+fmt.Sprint(initialEloRating)+`)
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to reset Elo ratings: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM team_ratings_history h
+		USING matches m, seasons s
+		WHERE h.match_id = m.id AND m.season_id = s.id
+		  AND s.status = $5 AND `+eloOrder+` >= `+position+`
+	`, append(args, models.SeasonActive)...)
+	if err != nil {
+		return fmt.Errorf("failed to clear rating history: %v", err)
+	}
+
+	rows, err := tx.Query(`
+		SELECT m.id, m.home_team_id, m.away_team_id, m.home_score, m.away_score, s.status <> $5,
+		       (SELECT h.rating - h.rating_before FROM team_ratings_history h
+		        WHERE h.match_id = m.id AND h.team_id = m.home_team_id)
+		FROM matches m
+		JOIN seasons s ON m.season_id = s.id
+		WHERE m.played = true AND `+eloOrder+` >= `+position+`
+		ORDER BY `+eloOrder+`
+	`, append(args, models.SeasonActive)...)
+	if err != nil {
+		return fmt.Errorf("failed to load played matches: %v", err)
+	}
+	var matches []eloMatch
+	for rows.Next() {
+		var match eloMatch
+		var archived bool
+		var recorded sql.NullFloat64
+		err := rows.Scan(
+			&match.MatchID, &match.HomeTeamID, &match.AwayTeamID, &match.HomeScore, &match.AwayScore,
+			&archived, &recorded,
+		)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan played match: %v", err)
+		}
+		if archived && recorded.Valid {
+			match.Recorded = &recorded.Float64
+		}
+		matches = append(matches, match)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load played matches: %v", err)
+	}
+
+	return recordEloRatings(tx, matches, cfg)
+}
+
+// applyEloRatings plays the matches through the ratings, starting teams
+// missing from ratings at the initial rating, and stores the results. A match
+// with a recorded change moves the ratings by it and its history is left as it is.
+func applyEloRatings(tx *sql.Tx, matches []eloMatch, ratings map[int]float64, cfg *config.Config) error {
+	ratingOf := func(teamID int) float64 {
+		if rating, ok := ratings[teamID]; ok {
+			return rating
+		}
+		return initialEloRating
+	}
+
+	changed := make(map[int]bool)
+	for _, match := range matches {
+		homeBefore, awayBefore := ratingOf(match.HomeTeamID), ratingOf(match.AwayTeamID)
+		change := eloChange(homeBefore, awayBefore, match.HomeScore, match.AwayScore, cfg)
+		if match.Recorded != nil {
+			change = *match.Recorded
+		}
+		ratings[match.HomeTeamID] = roundRating(homeBefore + change)
+		ratings[match.AwayTeamID] = roundRating(awayBefore - change)
+		changed[match.HomeTeamID] = true
+		changed[match.AwayTeamID] = true
+		if match.Recorded != nil {
+			continue
+		}
+
+		for _, entry := range []struct {
+			TeamID int
+			Before float64
+		}{{match.HomeTeamID, homeBefore}, {match.AwayTeamID, awayBefore}} {
+			_, err := tx.Exec(`
+				INSERT INTO team_ratings_history (team_id, match_id, rating_before, rating)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (team_id, match_id) DO UPDATE SET
+				rating_before = EXCLUDED.rating_before,
+				rating = EXCLUDED.rating
+			`, entry.TeamID, match.MatchID, entry.Before, ratings[entry.TeamID])
+			if err != nil {
+				return fmt.Errorf("failed to store rating of team %d: %v", entry.TeamID, err)
+			}
+		}
+	}
+
+	for teamID := range changed {
+		if _, err := tx.Exec("UPDATE teams SET elo_rating = $1 WHERE id = $2", ratings[teamID], teamID); err != nil {
+			return fmt.Errorf("failed to update Elo rating of team %d: %v", teamID, err)
+		}
+	}
+
+	return nil
+}
+
+// loadRatingCurve returns a team's rating after every match it played, oldest first
+func loadRatingCurve(q querier, teamID int) ([]models.RatingPoint, error) {
+	rows, err := q.Query(`
+		SELECT m.id, m.season_id, m.week, m.home_team_id = $1,
+		       CASE WHEN m.home_team_id = $1 THEN m.home_score ELSE m.away_score END,
+		       CASE WHEN m.home_team_id = $1 THEN m.away_score ELSE m.home_score END,
+		       o.id, o.name, h.rating_before, h.rating
+		FROM team_ratings_history h
+		JOIN matches m ON h.match_id = m.id
+		JOIN teams o ON o.id = CASE WHEN m.home_team_id = $1 THEN m.away_team_id ELSE m.home_team_id END
+		WHERE h.team_id = $1
+		ORDER BY m.played_at NULLS FIRST, m.season_id, m.week, m.id
+	`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.RatingPoint{}
+	for rows.Next() {
+		var point models.RatingPoint
+		err := rows.Scan(
+			&point.MatchID, &point.SeasonID, &point.Week, &point.Home, &point.GoalsFor, &point.GoalsAgainst,
+			&point.Opponent.ID, &point.Opponent.Name, &point.RatingBefore, &point.Rating,
+		)
+		if err != nil {
+			return nil, err
+		}
+		point.Change = roundRating(point.Rating - point.RatingBefore)
+		history = append(history, point)
+	}
+
+	return history, rows.Err()
+}
+
+// loadSeasonRatings returns the rating of every team of a season at the end of
+// it: after the team's last match of an archived season, or its current rating
+// while the season is active
+func loadSeasonRatings(q querier, season models.Season) (map[int]float64, error) {
+	query := "SELECT t.id, t.elo_rating FROM league_table lt JOIN teams t ON lt.team_id = t.id WHERE lt.season_id = $1"
+	if season.Status != models.SeasonActive {
+		query = `
+			SELECT lt.team_id, COALESCE((
+				SELECT h.rating
+				FROM team_ratings_history h
+				JOIN matches m ON h.match_id = m.id
+				WHERE h.team_id = lt.team_id AND m.season_id = lt.season_id
+				ORDER BY m.played_at DESC NULLS LAST, m.week DESC, m.id DESC
+				LIMIT 1
+			), ` + fmt.Sprint(initialEloRating) + `)
+			FROM league_table lt
+			WHERE lt.season_id = $1
+		`
+	}
+
+	rows, err := q.Query(query, season.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := make(map[int]float64)
+	for rows.Next() {
+		var teamID int
+		var rating float64
+		if err := rows.Scan(&teamID, &rating); err != nil {
+			return nil, err
+		}
+		ratings[teamID] = rating
+	}
+
+	return ratings, rows.Err()
+}
+
+// powerRankings orders a season's ranked table by Elo rating, keeping each
+// team's table position next to its power rank. Teams level on rating keep
+// their table order.
+func powerRankings(table []models.TeamStats, ratings map[int]float64) []models.PowerRanking {
+	rankings := make([]models.PowerRanking, len(table))
+	for i, stats := range table {
+		rankings[i] = models.PowerRanking{
+			Team:          stats.Team,
+			Rating:        ratings[stats.Team.ID],
+			TablePosition: stats.Position,
+			Points:        stats.Points,
+		}
+	}
+
+	sort.SliceStable(rankings, func(i, j int) bool {
+		return rankings[i].Rating > rankings[j].Rating
+	})
+	for i := range rankings {
+		rankings[i].Rank = i + 1
+		rankings[i].PositionDifference = rankings[i].TablePosition - rankings[i].Rank
+	}
+	return rankings
+}
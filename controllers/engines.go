@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// MatchEngine decides the score of a simulated match after 90 minutes from
+// the strengths of the two sides. Extra time and penalty shootouts are always
+// played out with the Poisson model.
+type MatchEngine interface {
+	// Name is the name the engine is registered and recorded under
+	Name() string
+	// Score samples the score of a match between the home and the away side
+	Score(rng *rand.Rand, home, away teamStrength) (int, int)
+}
+
+// registeredEngine is a match engine competitions can pick, with the
+// parameters it takes and how to build it from their values
+type registeredEngine struct {
+	Name        string
+	Description string
+	// Params lists the parameters with their defaults, which may come from the configuration
+	Params func(cfg *config.Config) []models.EngineParam
+	New    func(params map[string]float64) MatchEngine
+}
+
+// matchEngines are the engines competitions can pick from, in the order they are listed
+var matchEngines = []registeredEngine{
+	{
+		Name:        "uniform",
+		Description: "Each side scores a uniformly random number of goals, whatever the team ratings",
+		Params: func(cfg *config.Config) []models.EngineParam {
+			return []models.EngineParam{
+				{Name: "max_goals", Description: "Most goals a side can score", Default: 5, Min: 0, Max: 20},
+			}
+		},
+		New: func(params map[string]float64) MatchEngine {
+			return uniformEngine{maxGoals: int(params["max_goals"])}
+		},
+	},
+	{
+		Name:        "poisson",
+		Description: "Independent Poisson goals from the attack and defence ratings, with home advantage",
+		Params: func(cfg *config.Config) []models.EngineParam {
+			return []models.EngineParam{
+				{Name: "average_goals", Description: "Goals a league-average team scores per match", Default: cfg.AverageGoals, Min: 0, Max: 10},
+				{Name: "home_advantage", Description: "Multiplier applied to the home side's expected goals", Default: cfg.HomeAdvantage, Min: 0.1, Max: 5},
+			}
+		},
+		New: func(params map[string]float64) MatchEngine {
+			return poissonEngine{averageGoals: params["average_goals"], homeAdvantage: params["home_advantage"]}
+		},
+	},
+	{
+		Name:        "dixon-coles",
+		Description: "Poisson goals corrected for how often low scores really happen (Dixon and Coles, 1997)",
+		Params: func(cfg *config.Config) []models.EngineParam {
+			return []models.EngineParam{
+				{Name: "average_goals", Description: "Goals a league-average team scores per match", Default: cfg.AverageGoals, Min: 0, Max: 10},
+				{Name: "home_advantage", Description: "Multiplier applied to the home side's expected goals", Default: cfg.HomeAdvantage, Min: 0.1, Max: 5},
+				{Name: "rho", Description: "Low-score correction; below 0 makes 0-0 and 1-1 more likely", Default: -0.1, Min: -0.5, Max: 0.5},
+			}
+		},
+		New: func(params map[string]float64) MatchEngine {
+			return dixonColesEngine{
+				poisson: poissonEngine{averageGoals: params["average_goals"], homeAdvantage: params["home_advantage"]},
+				rho:     params["rho"],
+			}
+		},
+	},
+	{
+		Name:        "elo",
+		Description: "Poisson goals shared out by the Elo expected result of the match",
+		Params: func(cfg *config.Config) []models.EngineParam {
+			return []models.EngineParam{
+				{Name: "average_goals", Description: "Goals a side scores against an equally rated one", Default: cfg.AverageGoals, Min: 0, Max: 10},
+				{Name: "home_advantage", Description: "Elo points added to the home side's rating", Default: cfg.EloHomeAdvantage, Min: 0, Max: 400},
+			}
+		},
+		New: func(params map[string]float64) MatchEngine {
+			return eloEngine{averageGoals: params["average_goals"], homeAdvantage: params["home_advantage"]}
+		},
+	},
+}
+
+// newMatchEngine builds the registered engine with the given name, or the
+// MATCH_ENGINE one when name is empty. Parameters left out take their
+// defaults; unknown parameters and values out of range are an error.
+func newMatchEngine(name string, params map[string]float64, cfg *config.Config) (MatchEngine, error) {
+	if name == "" {
+		name = cfg.MatchEngine
+	}
+
+	var engine *registeredEngine
+	names := make([]string, len(matchEngines))
+	for i := range matchEngines {
+		names[i] = matchEngines[i].Name
+		if matchEngines[i].Name == name {
+			engine = &matchEngines[i]
+		}
+	}
+	if engine == nil {
+		return nil, fmt.Errorf("unknown match engine %q; engines are %s", name, strings.Join(names, ", "))
+	}
+
+	accepted := engine.Params(cfg)
+	values := make(map[string]float64, len(accepted))
+	for _, param := range accepted {
+		values[param.Name] = param.Default
+	}
+	for key, value := range params {
+		var known *models.EngineParam
+		for i := range accepted {
+			if accepted[i].Name == key {
+				known = &accepted[i]
+			}
+		}
+		if known == nil {
+			return nil, fmt.Errorf("match engine %s has no parameter %q", name, key)
+		}
+		if value < known.Min || value > known.Max || math.IsNaN(value) {
+			return nil, fmt.Errorf("%s of match engine %s must be between %g and %g", key, name, known.Min, known.Max)
+		}
+		values[key] = value
+	}
+
+	return engine.New(values), nil
+}
+
+// competitionEngine builds the match engine a competition plays its matches with
+func competitionEngine(competition models.Competition, cfg *config.Config) (MatchEngine, error) {
+	engine, err := newMatchEngine(competition.MatchEngine, competition.EngineParams, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the match engine of %s: %v", competition.Name, err)
+	}
+	return engine, nil
+}
+
+// seasonEngine builds the match engine of a season's competition
+func seasonEngine(q querier, seasonID int, cfg *config.Config) (MatchEngine, error) {
+	season, err := loadSeason(q, seasonID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get season: %v", err)
+	}
+	competition, err := loadCompetition(q, season.CompetitionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get competition: %v", err)
+	}
+	return competitionEngine(competition, cfg)
+}
+
+// uniformEngine is the original engine: every score from 0 to maxGoals is
+// as likely for either side, so the ratings make no difference
+type uniformEngine struct {
+	maxGoals int
+}
+
+func (e uniformEngine) Name() string { return "uniform" }
+
+func (e uniformEngine) Score(rng *rand.Rand, home, away teamStrength) (int, int) {
+	return rng.Intn(e.maxGoals + 1), rng.Intn(e.maxGoals + 1)
+}
+
+// poissonEngine draws each side's goals from a Poisson distribution whose mean
+// is its attack against the other side's defence
+type poissonEngine struct {
+	averageGoals  float64
+	homeAdvantage float64
+}
+
+func (e poissonEngine) Name() string { return "poisson" }
+
+func (e poissonEngine) Score(rng *rand.Rand, home, away teamStrength) (int, int) {
+	homeLambda, awayLambda := e.expected(home, away)
+	return samplePoisson(rng, homeLambda), samplePoisson(rng, awayLambda)
+}
+
+// expected returns the Poisson means for the home and away side.
+// The home side's mean is multiplied by the home advantage.
+func (e poissonEngine) expected(home, away teamStrength) (float64, float64) {
+	homeLambda := e.averageGoals * home.Attack / away.Defence * e.homeAdvantage
+	awayLambda := e.averageGoals * away.Attack / home.Defence
+	return homeLambda, awayLambda
+}
+
+// dixonColesEngine is the Poisson model with the Dixon-Coles correction of the
+// 0-0, 1-0, 0-1 and 1-1 scores, which independent Poisson goals get wrong
+type dixonColesEngine struct {
+	poisson poissonEngine
+	rho     float64
+}
+
+func (e dixonColesEngine) Name() string { return "dixon-coles" }
+
+// Score samples independent Poisson goals and keeps them with a probability
+// in proportion to the correction of the score, so the scores come out with
+// the corrected distribution
+func (e dixonColesEngine) Score(rng *rand.Rand, home, away teamStrength) (int, int) {
+	homeLambda, awayLambda := e.poisson.expected(home, away)
+
+	highest := 1.0
+	for _, score := range [][2]int{{0, 0}, {0, 1}, {1, 0}, {1, 1}} {
+		highest = math.Max(highest, e.correction(score[0], score[1], homeLambda, awayLambda))
+	}
+
+	for {
+		homeGoals, awayGoals := samplePoisson(rng, homeLambda), samplePoisson(rng, awayLambda)
+		if rng.Float64()*highest < e.correction(homeGoals, awayGoals, homeLambda, awayLambda) {
+			return homeGoals, awayGoals
+		}
+	}
+}
+
+// correction is the Dixon-Coles factor applied to the probability of a score,
+// kept from going below zero for large means
+func (e dixonColesEngine) correction(homeGoals, awayGoals int, homeLambda, awayLambda float64) float64 {
+	var tau float64
+	switch {
+	case homeGoals == 0 && awayGoals == 0:
+		tau = 1 - homeLambda*awayLambda*e.rho
+	case homeGoals == 0 && awayGoals == 1:
+		tau = 1 + homeLambda*e.rho
+	case homeGoals == 1 && awayGoals == 0:
+		tau = 1 + awayLambda*e.rho
+	case homeGoals == 1 && awayGoals == 1:
+		tau = 1 - e.rho
+	default:
+		tau = 1
+	}
+	return math.Max(0, tau)
+}
+
+// eloEngine shares out twice the average goals between the sides by the
+// result the Elo ratings expect, so a side expected to take 70% of the points
+// gets 70% of the goals on average
+type eloEngine struct {
+	averageGoals  float64
+	homeAdvantage float64
+}
+
+func (e eloEngine) Name() string { return "elo" }
+
+func (e eloEngine) Score(rng *rand.Rand, home, away teamStrength) (int, int) {
+	expected := eloExpected(home.Elo+e.homeAdvantage, away.Elo)
+	total := 2 * e.averageGoals
+	return samplePoisson(rng, total*expected), samplePoisson(rng, total*(1-expected))
+}
+
+// matchEngineList describes every registered engine, marking the MATCH_ENGINE one as the default
+func matchEngineList(cfg *config.Config) []models.MatchEngineInfo {
+	engines := make([]models.MatchEngineInfo, len(matchEngines))
+	for i, engine := range matchEngines {
+		engines[i] = models.MatchEngineInfo{
+			Name:        engine.Name,
+			Description: engine.Description,
+			Default:     engine.Name == cfg.MatchEngine,
+			Params:      engine.Params(cfg),
+		}
+	}
+	return engines
+}
@@ -0,0 +1,75 @@
+package controllers
+
+// byeTeamID marks the empty slot added when the number of teams is odd.
+// A team drawn against it sits the week out.
+const byeTeamID = 0
+
+// roundRobinFixtures builds a double round-robin schedule with the circle
+// (Berger) method. Every team meets every other team once at home and once
+// away, nobody plays twice in a week, and the season lasts 2*(n-1) weeks
+// where n is the number of teams rounded up to an even number.
+//
+// The second half of the season repeats the first half with home and away
+// swapped, so each team plays exactly as many home games as away games.
+func roundRobinFixtures(teams []int) []fixture {
+	if len(teams) < 2 {
+		return nil
+	}
+
+	slots := make([]int, len(teams))
+	copy(slots, teams)
+	if len(slots)%2 == 1 {
+		slots = append(slots, byeTeamID)
+	}
+
+	n := len(slots)
+	rounds := n - 1
+	fixed := slots[n-1]
+	var firstHalf []fixture
+
+	for round := 0; round < rounds; round++ {
+		var pairs [][2]int
+
+		// The last slot stays fixed and alternates between home and away
+		if round%2 == 0 {
+			pairs = append(pairs, [2]int{slots[round], fixed})
+		} else {
+			pairs = append(pairs, [2]int{fixed, slots[round]})
+		}
+
+		// The remaining slots sit on a circle and pair up around slots[round];
+		// alternating which side hosts keeps everyone's venues balanced
+		for i := 1; i < n/2; i++ {
+			up := slots[(round+i)%rounds]
+			down := slots[(round-i+rounds)%rounds]
+			if i%2 == 1 {
+				pairs = append(pairs, [2]int{up, down})
+			} else {
+				pairs = append(pairs, [2]int{down, up})
+			}
+		}
+
+		for _, pair := range pairs {
+			if pair[0] == byeTeamID || pair[1] == byeTeamID {
+				continue
+			}
+			firstHalf = append(firstHalf, fixture{
+				HomeTeamID: pair[0],
+				AwayTeamID: pair[1],
+				Week:       round + 1,
+			})
+		}
+	}
+
+	fixtures := make([]fixture, 0, 2*len(firstHalf))
+	fixtures = append(fixtures, firstHalf...)
+	for _, f := range firstHalf {
+		fixtures = append(fixtures, fixture{
+			HomeTeamID: f.AwayTeamID,
+			AwayTeamID: f.HomeTeamID,
+			Week:       f.Week + rounds,
+		})
+	}
+
+	return fixtures
+}
@@ -0,0 +1,133 @@
+package controllers
+
+import "testing"
+
+func makeTeamIDs(n int) []int {
+	teams := make([]int, n)
+	for i := range teams {
+		teams[i] = i + 1
+	}
+	return teams
+}
+
+func TestRoundRobinFixturesInvariants(t *testing.T) {
+	for n := 2; n <= 20; n++ {
+		teams := makeTeamIDs(n)
+		fixtures := roundRobinFixtures(teams)
+
+		slots := n
+		if n%2 == 1 {
+			slots++
+		}
+		halfWeeks := slots - 1
+
+		if want := n * (n - 1); len(fixtures) != want {
+			t.Fatalf("n=%d: got %d fixtures, want %d", n, len(fixtures), want)
+		}
+
+		pairs := make(map[[2]int]int)
+		playedInWeek := make(map[[2]int]bool)
+		home := make(map[int]int)
+		away := make(map[int]int)
+		firstHalfHome := make(map[int]int)
+		firstHalfAway := make(map[int]int)
+		maxWeek := 0
+
+		for _, f := range fixtures {
+			if f.HomeTeamID == f.AwayTeamID {
+				t.Fatalf("n=%d: team %d plays itself in week %d", n, f.HomeTeamID, f.Week)
+			}
+			if f.HomeTeamID == byeTeamID || f.AwayTeamID == byeTeamID {
+				t.Fatalf("n=%d: bye slot scheduled as a match in week %d", n, f.Week)
+			}
+			if f.Week < 1 || f.Week > 2*halfWeeks {
+				t.Fatalf("n=%d: week %d out of range", n, f.Week)
+			}
+			if f.Week > maxWeek {
+				maxWeek = f.Week
+			}
+
+			pairs[[2]int{f.HomeTeamID, f.AwayTeamID}]++
+
+			for _, team := range []int{f.HomeTeamID, f.AwayTeamID} {
+				key := [2]int{team, f.Week}
+				if playedInWeek[key] {
+					t.Fatalf("n=%d: team %d plays twice in week %d", n, team, f.Week)
+				}
+				playedInWeek[key] = true
+			}
+
+			home[f.HomeTeamID]++
+			away[f.AwayTeamID]++
+			if f.Week <= halfWeeks {
+				firstHalfHome[f.HomeTeamID]++
+				firstHalfAway[f.AwayTeamID]++
+			}
+		}
+
+		if maxWeek != 2*halfWeeks {
+			t.Fatalf("n=%d: season lasts %d weeks, want %d", n, maxWeek, 2*halfWeeks)
+		}
+
+		for _, a := range teams {
+			for _, b := range teams {
+				if a != b && pairs[[2]int{a, b}] != 1 {
+					t.Fatalf("n=%d: %d v %d scheduled %d times, want 1", n, a, b, pairs[[2]int{a, b}])
+				}
+			}
+
+			if home[a] != n-1 || away[a] != n-1 {
+				t.Fatalf("n=%d: team %d has %d home and %d away games, want %d each", n, a, home[a], away[a], n-1)
+			}
+
+			diff := firstHalfHome[a] - firstHalfAway[a]
+			if diff < -1 || diff > 1 {
+				t.Fatalf("n=%d: team %d has %d home and %d away games in the first half", n, a, firstHalfHome[a], firstHalfAway[a])
+			}
+		}
+	}
+}
+
+func TestRoundRobinFixturesNoLongHomeOrAwayRuns(t *testing.T) {
+	for n := 2; n <= 20; n++ {
+		fixtures := roundRobinFixtures(makeTeamIDs(n))
+
+		venues := make(map[int]map[int]bool)
+		for _, f := range fixtures {
+			if venues[f.HomeTeamID] == nil {
+				venues[f.HomeTeamID] = make(map[int]bool)
+			}
+			if venues[f.AwayTeamID] == nil {
+				venues[f.AwayTeamID] = make(map[int]bool)
+			}
+			venues[f.HomeTeamID][f.Week] = true
+			venues[f.AwayTeamID][f.Week] = false
+		}
+
+		for team, byWeek := range venues {
+			run, last, weeks := 0, false, 0
+			for week := 1; week <= 2*n; week++ {
+				atHome, ok := byWeek[week]
+				if !ok {
+					continue
+				}
+				weeks++
+				if weeks > 1 && atHome == last {
+					run++
+				} else {
+					run = 1
+				}
+				last = atHome
+				if run > 3 {
+					t.Fatalf("n=%d: team %d plays %d consecutive games at the same venue", n, team, run)
+				}
+			}
+		}
+	}
+}
+
+func TestRoundRobinFixturesTooFewTeams(t *testing.T) {
+	if fixtures := roundRobinFixtures([]int{1}); fixtures != nil {
+		t.Fatalf("expected no fixtures for a single team, got %v", fixtures)
+	}
+}
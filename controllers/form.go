@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"math"
+	"strings"
+
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// Results as they appear in a form string
+const (
+	formWin  = "W"
+	formDraw = "D"
+	formLoss = "L"
+)
+
+// minFatigue is the lowest share of its strength a tired team keeps
+const minFatigue = 0.5
+
+// minForm is the lowest share of its strength a team in bad form keeps, so a
+// large FORM_EFFECT cannot take a losing team's ratings to zero or below
+const minForm = 0.1
+
+// formTracker follows every team's recent results and fixture congestion
+// while matches are played, so each match is sampled with the form the teams
+// are in at that moment
+type formTracker struct {
+	cfg *config.Config
+
+	// recent holds each team's last results, oldest first
+	recent map[int][]string
+	// congestion counts each team's matches in other competitions since its
+	// last match in the competition being played
+	congestion map[int]int
+}
+
+// loadFormTracker reads the last results of every team, in any competition,
+// in the order they were played. Only matches in another competition than the
+// season's count towards congestion.
+func loadFormTracker(q querier, seasonID int, cfg *config.Config) (*formTracker, error) {
+	tracker := &formTracker{
+		cfg:        cfg,
+		recent:     make(map[int][]string),
+		congestion: make(map[int]int),
+	}
+	if cfg.FormMatches <= 0 {
+		return tracker, nil
+	}
+
+	rows, err := q.Query(`
+		SELECT team_id, elsewhere, goals_for, goals_against
+		FROM (
+			SELECT played.team_id, played.goals_for, played.goals_against,
+			       s.competition_id <> (SELECT competition_id FROM seasons WHERE id = $2) AS elsewhere,
+			       ROW_NUMBER() OVER (
+			           PARTITION BY played.team_id
+			           ORDER BY played.played_at DESC NULLS LAST, played.season_id DESC, played.week DESC, played.id DESC
+			       ) AS n
+			FROM (
+				SELECT id, season_id, week, played_at, home_team_id AS team_id, home_score AS goals_for, away_score AS goals_against
+				FROM matches WHERE played = true
+				UNION ALL
+				SELECT id, season_id, week, played_at, away_team_id, away_score, home_score
+				FROM matches WHERE played = true
+			) played
+			JOIN seasons s ON played.season_id = s.id
+		) recent
+		WHERE n <= $1
+		ORDER BY team_id, n DESC
+	`, cfg.FormMatches, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var teamID, goalsFor, goalsAgainst int
+		var elsewhere bool
+		if err := rows.Scan(&teamID, &elsewhere, &goalsFor, &goalsAgainst); err != nil {
+			return nil, err
+		}
+		tracker.recent[teamID] = append(tracker.recent[teamID], formResult(goalsFor, goalsAgainst))
+		// Earlier seasons of the same competition rest a team as much as this one
+		if elsewhere {
+			tracker.congestion[teamID]++
+		} else {
+			tracker.congestion[teamID] = 0
+		}
+	}
+
+	return tracker, rows.Err()
+}
+
+// strength adjusts a team's base strength for its form and fatigue. Form runs
+// from -1 when every recent match was lost to 1 when every one was won and
+// raises or lowers attack and defence by up to FORM_EFFECT, keeping at least
+// minForm of them; every match in another competition since the team last
+// played in this one costs FATIGUE_EFFECT of both.
+func (t *formTracker) strength(base teamStrength, teamID int) teamStrength {
+	factor := math.Max(minForm, 1+t.cfg.FormEffect*t.form(teamID))
+	factor *= math.Max(minFatigue, 1-t.cfg.FatigueEffect*float64(t.congestion[teamID]))
+	return base.scaled(factor)
+}
+
+// form scores a team's recent results from -1 to 1; a team without any is in neutral form
+func (t *formTracker) form(teamID int) float64 {
+	recent := t.recent[teamID]
+	if len(recent) == 0 {
+		return 0
+	}
+
+	score := 0.0
+	for _, result := range recent {
+		switch result {
+		case formWin:
+			score++
+		case formLoss:
+			score--
+		}
+	}
+	return score / float64(len(recent))
+}
+
+// record adds a match of the season being played to both teams' form. Both
+// teams are rested as far as this season is concerned.
+func (t *formTracker) record(homeTeamID, awayTeamID, homeScore, awayScore int) {
+	t.add(homeTeamID, formResult(homeScore, awayScore))
+	t.add(awayTeamID, formResult(awayScore, homeScore))
+	t.congestion[homeTeamID] = 0
+	t.congestion[awayTeamID] = 0
+}
+
+// add appends a result to a team's form, dropping the oldest beyond the form length
+func (t *formTracker) add(teamID int, result string) {
+	if t.cfg.FormMatches <= 0 {
+		return
+	}
+	recent := append(t.recent[teamID], result)
+	if len(recent) > t.cfg.FormMatches {
+		recent = recent[len(recent)-t.cfg.FormMatches:]
+	}
+	t.recent[teamID] = recent
+}
+
+// formResult returns the form letter of a match from one team's point of view
+func formResult(goalsFor, goalsAgainst int) string {
+	switch {
+	case goalsFor > goalsAgainst:
+		return formWin
+	case goalsFor < goalsAgainst:
+		return formLoss
+	}
+	return formDraw
+}
+
+// setTableForm fills in the form of every team of a league table from the
+// season's results, which must be in the order they were played: the last
+// results up to the given length, oldest first
+func setTableForm(standings []models.TeamStats, results []matchResult, length int) {
+	if length <= 0 {
+		return
+	}
+
+	recent := make(map[int][]string)
+	for _, result := range results {
+		recent[result.HomeTeamID] = append(recent[result.HomeTeamID], formResult(result.HomeScore, result.AwayScore))
+		recent[result.AwayTeamID] = append(recent[result.AwayTeamID], formResult(result.AwayScore, result.HomeScore))
+	}
+
+	for i := range standings {
+		form := recent[standings[i].Team.ID]
+		if len(form) > length {
+			form = form[len(form)-length:]
+		}
+		standings[i].Form = strings.Join(form, "")
+	}
+}
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sametyildirim314/insider_case/config"
+)
+
+// However large FORM_EFFECT is, a team on a losing run keeps a positive
+// strength and a finite Elo rating
+func TestFormStrengthFloor(t *testing.T) {
+	for _, effect := range []float64{0.05, 1, 3} {
+		tracker := &formTracker{
+			cfg:        &config.Config{FormMatches: 5, FormEffect: effect, FatigueEffect: 0.5},
+			recent:     map[int][]string{1: {formLoss, formLoss, formLoss, formLoss, formLoss}},
+			congestion: map[int]int{1: 4},
+		}
+
+		strength := tracker.strength(defaultStrength, 1)
+		if strength.Attack <= 0 || strength.Defence <= 0 || math.IsNaN(strength.Elo) || math.IsInf(strength.Elo, 0) {
+			t.Fatalf("FORM_EFFECT %g: got strength %+v", effect, strength)
+		}
+
+		want := math.Max(minForm, 1-effect) * minFatigue
+		if math.Abs(strength.Attack-want) > 1e-9 {
+			t.Fatalf("FORM_EFFECT %g: got attack %g, want %g", effect, strength.Attack, want)
+		}
+	}
+}
@@ -4,23 +4,47 @@ import (
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/config"
 	"github.com/sametyildirim314/insider_case/database"
 	"github.com/sametyildirim314/insider_case/models"
 )
 
-
+// GetLeagueTable returns the league table of a season. Teams that are level on
+// points are ordered with the competition's tie-break chain. Every team comes
+// with its outlook: the best and worst position it can still finish in, its
+// title clinch and elimination flags and its magic number, and with ?top=N the
+// same for a top-N finish.
 func GetLeagueTable(c *fiber.Ctx) error {
-
+	top, err := outlookTop(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid top, it must be a positive number",
+		})
+	}
+	
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+	if status, msg := checkLeagueSeason(database.DB, season); msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	
 	query := `
 		SELECT lt.points, lt.played, lt.wins, lt.draws, lt.losses, 
 		       lt.goals_for, lt.goals_against, lt.goal_difference,
 		       t.id, t.name
 		FROM league_table lt
 		JOIN teams t ON lt.team_id = t.id
+		WHERE lt.season_id = $1
 		ORDER BY lt.points DESC, lt.goal_difference DESC, lt.goals_for DESC
 	`
 	
-	rows, err := database.DB.Query(query)
+	rows, err := database.DB.Query(query, season.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to get league table: " + err.Error(),
@@ -44,65 +68,110 @@ func GetLeagueTable(c *fiber.Ctx) error {
 		
 		teamStats = append(teamStats, stats)
 	}
+	rows.Close()
+	
+	// Separate teams that are level on points with the competition's tie-break chain
+	competition, err := loadCompetition(database.DB, season.CompetitionID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get competition: " + err.Error(),
+		})
+	}
+	
+	results, err := loadAllResults(database.DB, season.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get matches: " + err.Error(),
+		})
+	}
+	
+	rankStandings(teamStats, results, competition.TieBreakers, competition.LotsSeed)
+	setTableForm(teamStats, results, config.GetConfig().FormMatches)
+	
+	// What every team can still achieve with the matches left
+	remaining, err := loadUnplayedFixtures(season.ID, 0)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get remaining matches: " + err.Error(),
+		})
+	}
+	outlooks := solveOutlooks(teamStats, remaining, top)
+	for i := range teamStats {
+		teamStats[i].Outlook = outlooks[teamStats[i].Team.ID]
+	}
 	
 	return c.JSON(teamStats)
 }
 
+// GetLeagueTableForWeek rebuilds the standings from the matches played up to and
+// including the requested week, with each team's movement since the week before
 func GetLeagueTableForWeek(c *fiber.Ctx) error {
 	week, err := strconv.Atoi(c.Params("week"))
-	if err != nil {
+	if err != nil || week < 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid week number",
 		})
 	}
 	
-	var matchesPlayed int
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM matches WHERE week <= $1 AND played = true", week).Scan(&matchesPlayed)
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+	if status, msg := checkLeagueSeason(database.DB, season); msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	
+	teams, err := loadSeasonTeams(database.DB, season.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to check matches: " + err.Error(),
+			"error": "Failed to get teams: " + err.Error(),
 		})
 	}
 	
-	if matchesPlayed == 0 {
-		rows, err := database.DB.Query("SELECT id, name FROM teams")
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to get teams: " + err.Error(),
-			})
-		}
-		defer rows.Close()
-		
-		var teams []models.Team
-		for rows.Next() {
-			var team models.Team
-			err := rows.Scan(&team.ID, &team.Name)
-			if err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-					"error": "Failed to scan team: " + err.Error(),
-				})
+	results, err := loadResults(database.DB, season.ID, week)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get matches: " + err.Error(),
+		})
+	}
+	
+	competition, err := loadCompetition(database.DB, season.CompetitionID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get competition: " + err.Error(),
+		})
+	}
+	
+	teamStats := computeStandings(teams, results)
+	rankStandings(teamStats, results, competition.TieBreakers, competition.LotsSeed)
+	setTableForm(teamStats, results, config.GetConfig().FormMatches)
+	
+	// Compare with the table after the previous week
+	if week > 1 {
+		var previousResults []matchResult
+		for _, result := range results {
+			if result.Week < week {
+				previousResults = append(previousResults, result)
 			}
-			teams = append(teams, team)
 		}
 		
-		var teamStats []models.TeamStats
-		for _, team := range teams {
-			stats := models.TeamStats{
-				Team:          team,
-				Points:        0,
-				Played:        0,
-				Wins:          0,
-				Draws:         0,
-				Losses:        0,
-				GoalsFor:      0,
-				GoalsAgainst:  0,
-				GoalDifference: 0,
-			}
-			teamStats = append(teamStats, stats)
+		previousStats := computeStandings(teams, previousResults)
+		rankStandings(previousStats, previousResults, competition.TieBreakers, competition.LotsSeed)
+		
+		previousPositions := make(map[int]int)
+		for _, stats := range previousStats {
+			previousPositions[stats.Team.ID] = stats.Position
 		}
 		
-		return c.JSON(teamStats)
+		for i := range teamStats {
+			change := previousPositions[teamStats[i].Team.ID] - teamStats[i].Position
+			teamStats[i].PositionChange = &change
+		}
 	}
 	
-	return GetLeagueTable(c)
-} 
\ No newline at end of file
+	return c.JSON(teamStats)
+}
@@ -0,0 +1,384 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sametyildirim314/insider_case/database"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// liveRetention is how long a finished live week stays available, so late
+// watchers can still replay it
+const liveRetention = 10 * time.Minute
+
+// liveBuffer is the number of updates a watcher may fall behind before it is
+// dropped; a dropped watcher can reconnect and replay the stream
+const liveBuffer = 64
+
+// errLiveStarted is returned when a week is already being played live
+var errLiveStarted = errors.New("the week is already being played live")
+
+// liveKey names a live week
+type liveKey struct {
+	SeasonID int
+	Week     int
+}
+
+// liveFrame is an update of a live week with the match minute it is sent at,
+// already encoded as a server-sent event
+type liveFrame struct {
+	Minute int
+	Data   []byte
+}
+
+// liveBroadcast is the stream of one live week. Every update is kept, so a
+// watcher who joins late replays what was sent before and then follows the
+// same stream as everyone else.
+type liveBroadcast struct {
+	started  bool
+	finished bool
+	history  [][]byte
+	watchers map[chan []byte]bool
+}
+
+// liveHub holds the live weeks being played or waited for
+type liveHub struct {
+	mu         sync.Mutex
+	broadcasts map[liveKey]*liveBroadcast
+}
+
+// liveWeeks is the hub shared by the simulation and the stream endpoints
+var liveWeeks = &liveHub{broadcasts: make(map[liveKey]*liveBroadcast)}
+
+// has reports whether a week is being played live, waited for or kept for replay
+func (h *liveHub) has(key liveKey) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.broadcasts[key]
+	return ok
+}
+
+// subscribe adds a watcher to a live week, waiting for kick-off when the week
+// has not started yet. It returns the updates sent so far and a channel with
+// the ones that follow, which is closed at the end of the stream.
+func (h *liveHub) subscribe(key liveKey) ([][]byte, chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	broadcast, ok := h.broadcasts[key]
+	if !ok {
+		broadcast = &liveBroadcast{watchers: make(map[chan []byte]bool)}
+		h.broadcasts[key] = broadcast
+	}
+
+	updates := make(chan []byte, liveBuffer)
+	history := append([][]byte{}, broadcast.history...)
+	if broadcast.finished {
+		close(updates)
+	} else {
+		broadcast.watchers[updates] = true
+	}
+	return history, updates
+}
+
+// unsubscribe removes a watcher. A week nobody waits for any more is forgotten
+// when it has not started.
+func (h *liveHub) unsubscribe(key liveKey, updates chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	broadcast, ok := h.broadcasts[key]
+	if !ok {
+		return
+	}
+	if broadcast.watchers[updates] {
+		delete(broadcast.watchers, updates)
+		close(updates)
+	}
+	if !broadcast.started && len(broadcast.watchers) == 0 {
+		delete(h.broadcasts, key)
+	}
+}
+
+// playing returns a week of a season that is being played live and has not
+// reached full time yet
+func (h *liveHub) playing(seasonID int) (int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for key, broadcast := range h.broadcasts {
+		if key.SeasonID == seasonID && broadcast.started && !broadcast.finished {
+			return key.Week, true
+		}
+	}
+	return 0, false
+}
+
+// start plays the frames of a live week in the background, sending each one
+// when the live clock reaches its minute. fullTime is called when the clock
+// reaches the last frame, before it is sent.
+func (h *liveHub) start(key liveKey, frames []liveFrame, minute time.Duration, fullTime func()) error {
+	h.mu.Lock()
+	broadcast, ok := h.broadcasts[key]
+	if !ok {
+		broadcast = &liveBroadcast{watchers: make(map[chan []byte]bool)}
+		h.broadcasts[key] = broadcast
+	}
+	if broadcast.started {
+		h.mu.Unlock()
+		return errLiveStarted
+	}
+	broadcast.started = true
+	h.mu.Unlock()
+
+	go func() {
+		kickOff := time.Now()
+		for i, frame := range frames {
+			time.Sleep(time.Until(kickOff.Add(time.Duration(frame.Minute) * minute)))
+			if i == len(frames)-1 {
+				fullTime()
+			}
+			h.publish(broadcast, frame.Data)
+		}
+		h.finish(key, broadcast)
+	}()
+	return nil
+}
+
+// publish sends an update to every watcher of a broadcast. Watchers too far
+// behind are dropped rather than holding everyone else up.
+func (h *liveHub) publish(broadcast *liveBroadcast, data []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	broadcast.history = append(broadcast.history, data)
+	for updates := range broadcast.watchers {
+		select {
+		case updates <- data:
+		default:
+			delete(broadcast.watchers, updates)
+			close(updates)
+		}
+	}
+}
+
+// finish ends a broadcast and forgets it once the retention time is over
+func (h *liveHub) finish(key liveKey, broadcast *liveBroadcast) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	broadcast.finished = true
+	for updates := range broadcast.watchers {
+		delete(broadcast.watchers, updates)
+		close(updates)
+	}
+
+	time.AfterFunc(liveRetention, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.broadcasts[key] == broadcast {
+			delete(h.broadcasts, key)
+		}
+	})
+}
+
+// playLiveWeek simulates a week and replays its timelines on the live clock,
+// pushing the scores and the provisional league table to its watchers. The
+// week is played in a transaction that is rolled back once the stream is
+// built, so it stays unplayed everywhere else until the stream reaches full
+// time; the same results are stored then.
+func playLiveWeek(season models.Season, week int, fixtures []fixture, seed int64, minute time.Duration) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	decided, err := playMatchesTx(tx, season.ID, fixtures, seed, nil)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	frames, err := liveWeekFrames(tx, season, week)
+	tx.Rollback()
+	if err != nil {
+		return err
+	}
+
+	fullTime := func() {
+		if err := storeLiveWeek(season.ID, week, decided, seed); err != nil {
+			fmt.Printf("Failed to store live week %d: %v\n", week, err)
+		}
+	}
+	return liveWeeks.start(liveKey{SeasonID: season.ID, Week: week}, frames, minute, fullTime)
+}
+
+// liveWeekFrames builds the stream of a played week from its matches and
+// their timelines
+func liveWeekFrames(q querier, season models.Season, week int) ([]liveFrame, error) {
+	matches, err := loadWeekMatches(q, season.ID, week)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(map[int][]models.MatchEvent, len(matches))
+	for _, match := range matches {
+		events[match.ID], err = loadMatchEvents(q, match.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get events of match %d: %v", match.ID, err)
+		}
+	}
+
+	teams, err := loadSeasonTeams(q, season.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get teams: %v", err)
+	}
+	previous, err := loadResults(q, season.ID, week-1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get matches: %v", err)
+	}
+	competition, err := loadCompetition(q, season.CompetitionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get competition: %v", err)
+	}
+
+	return buildLiveFrames(matches, events, teams, previous, competition)
+}
+
+// storeLiveWeek stores the results a live week was played with once it
+// reaches full time and brings the predictions up to date. Matches that were
+// played or removed in the meantime are left as they are.
+func storeLiveWeek(seasonID, week int, decided map[int]decidedMatch, seed int64) error {
+	unplayed, err := loadUnplayedFixtures(seasonID, week)
+	if err != nil {
+		return fmt.Errorf("failed to get matches: %v", err)
+	}
+	var fixtures []fixture
+	for _, f := range unplayed {
+		if _, ok := decided[f.ID]; ok {
+			fixtures = append(fixtures, f)
+		}
+	}
+	if len(fixtures) == 0 {
+		return nil
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := playMatchesTx(tx, seasonID, fixtures, seed, decided); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	// From week 4 on the predictions follow every simulated week
+	if week >= 4 {
+		if _, err := generatePredictions(seasonID, seed); err != nil {
+			return fmt.Errorf("failed to generate predictions: %v", err)
+		}
+	}
+	return nil
+}
+
+// buildLiveFrames merges the timelines of a week's matches into one stream
+// ordered by minute. Every frame carries the scores at that moment; the
+// kick-off, every goal and the end also carry the league table as it would
+// stand if the matches finished with the current scores.
+func buildLiveFrames(matches []models.Match, events map[int][]models.MatchEvent, teams []models.Team, previous []matchResult, competition models.Competition) ([]liveFrame, error) {
+	scores := make([]models.LiveScore, len(matches))
+	for i, match := range matches {
+		scores[i] = models.LiveScore{MatchID: match.ID, HomeTeam: match.HomeTeam, AwayTeam: match.AwayTeam}
+	}
+
+	type timedEvent struct {
+		Match int
+		Event models.MatchEvent
+	}
+	var timeline []timedEvent
+	for i, match := range matches {
+		for _, event := range events[match.ID] {
+			timeline = append(timeline, timedEvent{Match: i, Event: event})
+		}
+	}
+
+	// Each match's events are already in order, so a stable sort keeps them so
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].Event.Minute < timeline[j].Event.Minute
+	})
+
+	table := func() []models.TeamStats {
+		results := append([]matchResult{}, previous...)
+		for i, match := range matches {
+			results = append(results, matchResult{
+				HomeTeamID: match.HomeTeamID,
+				AwayTeamID: match.AwayTeamID,
+				HomeScore:  scores[i].HomeScore,
+				AwayScore:  scores[i].AwayScore,
+				Week:       match.Week,
+			})
+		}
+		standings := computeStandings(teams, results)
+		rankStandings(standings, results, competition.TieBreakers, competition.LotsSeed)
+		return standings
+	}
+
+	var frames []liveFrame
+	add := func(update models.LiveUpdate) error {
+		update.Scores = append([]models.LiveScore{}, scores...)
+		data, err := json.Marshal(update)
+		if err != nil {
+			return fmt.Errorf("failed to encode live update: %v", err)
+		}
+		frames = append(frames, liveFrame{
+			Minute: update.Minute,
+			Data:   []byte("event: " + update.Type + "\ndata: " + string(data) + "\n\n"),
+		})
+		return nil
+	}
+
+	if err := add(models.LiveUpdate{Type: models.LiveKickOff, Table: table()}); err != nil {
+		return nil, err
+	}
+
+	lastMinute := 0
+	for _, timed := range timeline {
+		event := timed.Event
+		score := &scores[timed.Match]
+		switch event.Type {
+		case models.EventGoal:
+			score.HomeScore, score.AwayScore = event.HomeScore, event.AwayScore
+		case models.EventFullTime, models.EventExtraTime:
+			score.Finished = true
+		}
+
+		update := models.LiveUpdate{Type: models.LiveEvent, Minute: event.Minute, Event: &event}
+		if event.Type == models.EventGoal {
+			update.Table = table()
+		}
+		if err := add(update); err != nil {
+			return nil, err
+		}
+		lastMinute = event.Minute
+	}
+
+	// Matches without a timeline are settled with their stored result at the end
+	for i, match := range matches {
+		if match.HomeScore != nil && match.AwayScore != nil {
+			scores[i].HomeScore, scores[i].AwayScore = *match.HomeScore, *match.AwayScore
+		}
+		scores[i].Finished = true
+	}
+	if err := add(models.LiveUpdate{Type: models.LiveFinished, Minute: lastMinute, Table: table()}); err != nil {
+		return nil, err
+	}
+
+	return frames, nil
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"bufio"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/database"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// liveKeepAlive is how often a comment is sent to watchers waiting for
+// something to happen, so idle connections are not closed along the way
+const liveKeepAlive = 15 * time.Second
+
+// WatchLiveWeek handles the request to follow a week of the current season,
+// or of the season given in the route, as it is played live. The response is
+// a stream of server-sent events: kick_off, one event per goal, card,
+// substitution or break in play, and finished. A watcher who connects before
+// the week is simulated with ?live=true waits for kick-off; one who connects
+// later first receives everything sent so far, so every watcher sees the same
+// stream.
+func WatchLiveWeek(c *fiber.Ctx) error {
+	week, err := strconv.Atoi(c.Params("week"))
+	if err != nil || week < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid week number",
+		})
+	}
+
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+	if status, msg := checkLeagueSeason(database.DB, season); msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	key := liveKey{SeasonID: season.ID, Week: week}
+	if !liveWeeks.has(key) {
+		// A week that was played without being watched cannot be shown live any more
+		var total, unplayed int
+		err := database.DB.QueryRow(
+			"SELECT COUNT(*), COUNT(*) FILTER (WHERE played = false) FROM matches WHERE season_id = $1 AND week = $2",
+			season.ID, week,
+		).Scan(&total, &unplayed)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check week: " + err.Error(),
+			})
+		}
+		// A week without matches would be watched for a kick-off that never comes
+		if total == 0 {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Week " + strconv.Itoa(week) + " has no matches",
+			})
+		}
+		if season.Status != models.SeasonActive || unplayed == 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Week " + strconv.Itoa(week) + " has already been played and is no longer live",
+			})
+		}
+	}
+
+	history, updates := liveWeeks.subscribe(key)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer liveWeeks.unsubscribe(key, updates)
+
+		for _, data := range history {
+			w.Write(data)
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		keepAlive := time.NewTicker(liveKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case data, ok := <-updates:
+				if !ok {
+					return
+				}
+				w.Write(data)
+			case <-keepAlive.C:
+				w.WriteString(": keep-alive\n\n")
+			}
+			// A failed flush means the watcher has gone
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	})
+
+	return nil
+}
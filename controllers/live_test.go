@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+// The week is stored at full time before watchers are told it has finished,
+// and until then the season counts as playing live
+func TestLiveHubFullTime(t *testing.T) {
+	hub := &liveHub{broadcasts: make(map[liveKey]*liveBroadcast)}
+	key := liveKey{SeasonID: 7, Week: 3}
+	_, updates := hub.subscribe(key)
+
+	stored := make(chan bool, 1)
+	frames := []liveFrame{
+		{Minute: 0, Data: []byte("kick_off")},
+		{Minute: 1, Data: []byte("goal")},
+		{Minute: 2, Data: []byte("finished")},
+	}
+	release := make(chan bool)
+	err := hub.start(key, frames, time.Millisecond, func() {
+		<-release
+		stored <- true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.start(key, frames, time.Millisecond, func() {}); err != errLiveStarted {
+		t.Fatalf("second start: got %v, want errLiveStarted", err)
+	}
+
+	for _, want := range []string{"kick_off", "goal"} {
+		if got := string(<-updates); got != want {
+			t.Fatalf("got update %q, want %q", got, want)
+		}
+	}
+	if week, ok := hub.playing(key.SeasonID); !ok || week != key.Week {
+		t.Fatalf("before full time: playing() = %d, %v, want %d, true", week, ok, key.Week)
+	}
+
+	close(release)
+	if got := string(<-updates); got != "finished" {
+		t.Fatalf("got update %q, want finished", got)
+	}
+	select {
+	case <-stored:
+	default:
+		t.Fatal("finished sent before the week was stored")
+	}
+
+	if _, ok := <-updates; ok {
+		t.Fatal("stream still open after full time")
+	}
+	if _, ok := hub.playing(key.SeasonID); ok {
+		t.Fatal("season still playing live after full time")
+	}
+}
@@ -9,24 +9,31 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/config"
 	"github.com/sametyildirim314/insider_case/database"
 	"github.com/sametyildirim314/insider_case/models"
 )
 
-// GetAllMatches handles the request to get all matches
+// GetAllMatches handles the request to get all matches of a season
 func GetAllMatches(c *fiber.Ctx) error {
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+	
 	// Query all matches directly from database
 	query := `
-		SELECT m.id, m.home_team_id, m.away_team_id, m.home_score, m.away_score, 
-		       m.week, m.played, m.created_at,
-		       ht.id, ht.name, at.id, at.name
+		SELECT ` + matchColumns + `
 		FROM matches m
 		JOIN teams ht ON m.home_team_id = ht.id
 		JOIN teams at ON m.away_team_id = at.id
+		WHERE m.season_id = $1
 		ORDER BY m.week, m.id
 	`
 	
-	rows, err := database.DB.Query(query)
+	rows, err := database.DB.Query(query, season.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to get matches: " + err.Error(),
@@ -36,31 +43,20 @@ func GetAllMatches(c *fiber.Ctx) error {
 	
 	var matches []models.Match
 	for rows.Next() {
-		var match models.Match
-		var createdAt sql.NullTime
-		
-		err := rows.Scan(
-			&match.ID, &match.HomeTeamID, &match.AwayTeamID, &match.HomeScore, &match.AwayScore,
-			&match.Week, &match.Played, &createdAt,
-			&match.HomeTeam.ID, &match.HomeTeam.Name, &match.AwayTeam.ID, &match.AwayTeam.Name,
-		)
+		match, err := scanMatch(rows)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to scan match: " + err.Error(),
 			})
 		}
 		
-		if createdAt.Valid {
-			match.CreatedAt = createdAt.Time
-		}
-		
 		matches = append(matches, match)
 	}
 	
 	return c.JSON(matches)
 }
 
-// GetMatchesByWeek handles the request to get matches for a specific week
+// GetMatchesByWeek handles the request to get matches for a specific week of a season
 func GetMatchesByWeek(c *fiber.Ctx) error {
 	week, err := strconv.Atoi(c.Params("week"))
 	if err != nil {
@@ -69,19 +65,24 @@ func GetMatchesByWeek(c *fiber.Ctx) error {
 		})
 	}
 	
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+	
 	// Query matches for the specific week directly from database
 	query := `
-		SELECT m.id, m.home_team_id, m.away_team_id, m.home_score, m.away_score, 
-		       m.week, m.played, m.created_at,
-		       ht.id, ht.name, at.id, at.name
+		SELECT ` + matchColumns + `
 		FROM matches m
 		JOIN teams ht ON m.home_team_id = ht.id
 		JOIN teams at ON m.away_team_id = at.id
-		WHERE m.week = $1
+		WHERE m.season_id = $1 AND m.week = $2
 		ORDER BY m.id
 	`
 	
-	rows, err := database.DB.Query(query, week)
+	rows, err := database.DB.Query(query, season.ID, week)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to get matches for week " + strconv.Itoa(week) + ": " + err.Error(),
@@ -91,31 +92,24 @@ func GetMatchesByWeek(c *fiber.Ctx) error {
 	
 	var matches []models.Match
 	for rows.Next() {
-		var match models.Match
-		var createdAt sql.NullTime
-		
-		err := rows.Scan(
-			&match.ID, &match.HomeTeamID, &match.AwayTeamID, &match.HomeScore, &match.AwayScore,
-			&match.Week, &match.Played, &createdAt,
-			&match.HomeTeam.ID, &match.HomeTeam.Name, &match.AwayTeam.ID, &match.AwayTeam.Name,
-		)
+		match, err := scanMatch(rows)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to scan match: " + err.Error(),
 			})
 		}
 		
-		if createdAt.Valid {
-			match.CreatedAt = createdAt.Time
-		}
-		
 		matches = append(matches, match)
 	}
 	
 	return c.JSON(matches)
 }
 
-// SimulateWeek handles the request to simulate matches for a specific week
+// SimulateWeek handles the request to simulate matches for a specific week of
+// the current season, or of the active season given in the route. With
+// ?live=true the results are revealed through the week's live stream, which
+// replays the match timelines on a compressed clock, and are only stored when
+// the stream reaches full time.
 func SimulateWeek(c *fiber.Ctx) error {
 	week, err := strconv.Atoi(c.Params("week"))
 	if err != nil {
@@ -124,9 +118,40 @@ func SimulateWeek(c *fiber.Ctx) error {
 		})
 	}
 	
+	seed, err := simulationSeed(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid seed: " + err.Error(),
+		})
+	}
+	
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+	if season.Status != models.SeasonActive {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Season " + season.Name + " is archived and cannot be simulated",
+		})
+	}
+	if status, msg := checkLeagueSeason(database.DB, season); msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	
+	// A live week is stored at full time, and nothing can be played before then
+	if liveWeek, ok := liveWeeks.playing(season.ID); ok {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Week " + strconv.Itoa(liveWeek) + " is being played live; wait for full time",
+		})
+	}
+	
 	// Check if fixtures exist
 	var count int
-	err = database.DB.QueryRow("SELECT COUNT(*) FROM matches").Scan(&count)
+	err = database.DB.QueryRow("SELECT COUNT(*) FROM matches WHERE season_id = $1", season.ID).Scan(&count)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to check fixtures: " + err.Error(),
@@ -135,7 +160,7 @@ func SimulateWeek(c *fiber.Ctx) error {
 	
 	// If no fixtures exist, generate them
 	if count == 0 {
-		if err := generateFixtures(); err != nil {
+		if err := generateFixtures(season.ID); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to generate fixtures: " + err.Error(),
 			})
@@ -146,8 +171,8 @@ func SimulateWeek(c *fiber.Ctx) error {
 	if week > 1 {
 		var previousWeekCount int
 		err = database.DB.QueryRow(
-			"SELECT COUNT(*) FROM matches WHERE week < $1 AND played = false", 
-			week,
+			"SELECT COUNT(*) FROM matches WHERE season_id = $1 AND week < $2 AND played = false", 
+			season.ID, week,
 		).Scan(&previousWeekCount)
 		
 		if err != nil {
@@ -163,395 +188,112 @@ func SimulateWeek(c *fiber.Ctx) error {
 		}
 	}
 	
-	// Seed random number generator
-	rand.Seed(time.Now().UnixNano())
-	
 	// Get matches for the week
-	rows, err := database.DB.Query(
-		"SELECT id, home_team_id, away_team_id FROM matches WHERE week = $1 AND played = false",
-		week,
-	)
+	fixtures, err := loadUnplayedFixtures(season.ID, week)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to get matches for week " + strconv.Itoa(week) + ": " + err.Error(),
 		})
 	}
-	defer rows.Close()
 	
-	// For each match, simulate the result
-	for rows.Next() {
-		var matchID, homeTeamID, awayTeamID int
-		err := rows.Scan(&matchID, &homeTeamID, &awayTeamID)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to scan match: " + err.Error(),
-			})
-		}
-		
-		// Generate random scores (simple simulation)
-		homeScore := rand.Intn(6) // 0-5 goals
-		awayScore := rand.Intn(6) // 0-5 goals
-		
-		// Start a transaction
-		tx, err := database.DB.Begin()
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to start transaction: " + err.Error(),
-			})
-		}
-		
-		// Update match with scores and mark as played
-		_, err = tx.Exec(
-			"UPDATE matches SET home_score = $1, away_score = $2, played = true WHERE id = $3",
-			homeScore, awayScore, matchID,
-		)
-		if err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update match: " + err.Error(),
-			})
-		}
-		
-		// Update league table for home team
-		var homePoints int
-		var homeWins, homeDraws, homeLosses int
-		if homeScore > awayScore {
-			// Home win
-			homePoints = 3
-			homeWins = 1
-		} else if homeScore == awayScore {
-			// Draw
-			homePoints = 1
-			homeDraws = 1
-		} else {
-			// Home loss
-			homeLosses = 1
-		}
-		
-		_, err = tx.Exec(`
-			UPDATE league_table SET 
-			points = points + $1,
-			played = played + 1,
-			wins = wins + $2,
-			draws = draws + $3,
-			losses = losses + $4,
-			goals_for = goals_for + $5,
-			goals_against = goals_against + $6,
-			goal_difference = goal_difference + $7
-			WHERE team_id = $8
-		`,
-			homePoints, homeWins, homeDraws, homeLosses, 
-			homeScore, awayScore, homeScore-awayScore,
-			homeTeamID,
-		)
-		if err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update home team stats: " + err.Error(),
-			})
-		}
-		
-		// Update league table for away team
-		var awayPoints int
-		var awayWins, awayDraws, awayLosses int
-		if awayScore > homeScore {
-			// Away win
-			awayPoints = 3
-			awayWins = 1
-		} else if awayScore == homeScore {
-			// Draw
-			awayPoints = 1
-			awayDraws = 1
-		} else {
-			// Away loss
-			awayLosses = 1
-		}
-		
-		_, err = tx.Exec(`
-			UPDATE league_table SET 
-			points = points + $1,
-			played = played + 1,
-			wins = wins + $2,
-			draws = draws + $3,
-			losses = losses + $4,
-			goals_for = goals_for + $5,
-			goals_against = goals_against + $6,
-			goal_difference = goal_difference + $7
-			WHERE team_id = $8
-		`,
-			awayPoints, awayWins, awayDraws, awayLosses, 
-			awayScore, homeScore, awayScore-homeScore,
-			awayTeamID,
-		)
-		if err != nil {
-			tx.Rollback()
+	live := c.QueryBool("live")
+	if live && len(fixtures) == 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Week " + strconv.Itoa(week) + " has no matches left to play live",
+		})
+	}
+	
+	// A live week is simulated now but stored, with its predictions, at full time
+	if live {
+		minute := time.Duration(config.GetConfig().LiveMinuteMillis) * time.Millisecond
+		if err := playLiveWeek(season, week, fixtures, seed, minute); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update away team stats: " + err.Error(),
+				"error": "Failed to start live week " + strconv.Itoa(week) + ": " + err.Error(),
 			})
 		}
 		
-		// Commit transaction
-		err = tx.Commit()
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to commit transaction: " + err.Error(),
-			})
-		}
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"message": "Week " + strconv.Itoa(week) + " is being played live; its results are stored at full time",
+			"seed": seed,
+			"stream": "/api/seasons/" + strconv.Itoa(season.ID) + "/live/" + strconv.Itoa(week),
+		})
+	}
+	
+	// Simulate the results and rebuild the league table
+	if err := playMatches(season.ID, fixtures, seed); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to simulate week " + strconv.Itoa(week) + ": " + err.Error(),
+		})
 	}
 	
 	// Get updated matches for the week
-	updatedMatches, err := getMatchesByWeek(week)
+	updatedMatches, err := getMatchesByWeek(season.ID, week)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to get matches after simulation",
 		})
 	}
 	
-	// After week 4, automatically generate championship predictions
-	if week == 4 {
-		// Get current league standings from database
-		standingsQuery := `
-			SELECT lt.points, lt.played, lt.wins, lt.draws, lt.losses, 
-				lt.goals_for, lt.goals_against, lt.goal_difference,
-				t.id, t.name
-			FROM league_table lt
-			JOIN teams t ON lt.team_id = t.id
-			ORDER BY lt.points DESC, lt.goal_difference DESC, lt.goals_for DESC
-		`
-		
-		rows, err := database.DB.Query(standingsQuery)
-		if err != nil {
-			// Just log the error but don't fail the whole request
-			fmt.Printf("Failed to get league standings for predictions: %v\n", err)
-		} else {
-			var standings []models.TeamStats
-			for rows.Next() {
-				var stats models.TeamStats
-				err := rows.Scan(
-					&stats.Points, &stats.Played, &stats.Wins, &stats.Draws, &stats.Losses,
-					&stats.GoalsFor, &stats.GoalsAgainst, &stats.GoalDifference,
-					&stats.Team.ID, &stats.Team.Name,
-				)
-				if err != nil {
-					fmt.Printf("Failed to scan team stats: %v\n", err)
-					continue
-				}
-				
-				standings = append(standings, stats)
-			}
-			rows.Close()
-			
-			// Clear existing predictions
-			_, err = database.DB.Exec("DELETE FROM predictions")
-			if err != nil {
-				fmt.Printf("Failed to clear existing predictions: %v\n", err)
-			} else {
-				// Seed random number generator
-				rand.Seed(time.Now().UnixNano())
-				
-				// Generate predictions based on current standings
-				tx, err := database.DB.Begin()
-				if err != nil {
-					fmt.Printf("Failed to start transaction for predictions: %v\n", err)
-				} else {
-					// Assign probabilities based loosely on current standings
-					totalProbability := 100.0
-					var generatedPredictions []models.Prediction
-					
-					for i, stats := range standings {
-						// Calculate remaining matches
-						remainingMatches := 6 - stats.Played // Total of 6 matches per team
-						maxPossiblePoints := stats.Points + (remainingMatches * 3)
-						
-						var probability float64
-						if i == 0 {
-							// Top team gets highest probability
-							probability = 40.0 + (rand.Float64() * 10.0) // 40-50%
-						} else if i == 1 {
-							// Second team
-							probability = 25.0 + (rand.Float64() * 10.0) // 25-35%
-						} else if i == 2 {
-							// Third team
-							probability = 10.0 + (rand.Float64() * 10.0) // 10-20%
-						} else {
-							// Last team gets remaining probability
-							probability = totalProbability
-							if len(generatedPredictions) >= 3 {
-								probability = totalProbability - (generatedPredictions[0].PredictionPercentage + 
-														generatedPredictions[1].PredictionPercentage + 
-														generatedPredictions[2].PredictionPercentage)
-							}
-						}
-						
-						// Ensure we don't go below 0 or have rounding issues
-						if probability < 0 {
-							probability = 0.1
-						}
-						
-						prediction := models.Prediction{
-							TeamID:             stats.Team.ID,
-							Team:               stats.Team,
-							PredictedPosition:  i + 1, // Current position
-							PredictedPoints:    maxPossiblePoints,
-							PredictionPercentage: probability,
-						}
-						
-						generatedPredictions = append(generatedPredictions, prediction)
-						
-						// Insert prediction
-						_, err := tx.Exec(
-							"INSERT INTO predictions (team_id, predicted_position, predicted_points, prediction_percentage) VALUES ($1, $2, $3, $4)",
-							prediction.TeamID, prediction.PredictedPosition, prediction.PredictedPoints, prediction.PredictionPercentage,
-						)
-						if err != nil {
-							tx.Rollback()
-							fmt.Printf("Failed to insert prediction: %v\n", err)
-							break
-						}
-					}
-					
-					err = tx.Commit()
-					if err != nil {
-						fmt.Printf("Failed to commit transaction for predictions: %v\n", err)
-					} else {
-						return c.JSON(fiber.Map{
-							"message": "Successfully simulated week " + strconv.Itoa(week) + " and generated championship predictions",
-							"matches": updatedMatches,
-							"predictions": generatedPredictions,
-						})
-					}
-				}
-			}
-		}
-	} else if week >= 4 {
-		// Week 4 and above should update predictions too
-		// Get current league standings from database
-		standingsQuery := `
-			SELECT lt.points, lt.played, lt.wins, lt.draws, lt.losses, 
-				lt.goals_for, lt.goals_against, lt.goal_difference,
-				t.id, t.name
-			FROM league_table lt
-			JOIN teams t ON lt.team_id = t.id
-			ORDER BY lt.points DESC, lt.goal_difference DESC, lt.goals_for DESC
-		`
-		
-		rows, err := database.DB.Query(standingsQuery)
+	// From week 4 on, automatically regenerate championship predictions
+	if week >= 4 {
+		predictions, err := generatePredictions(season.ID, seed)
 		if err != nil {
 			// Just log the error but don't fail the whole request
-			fmt.Printf("Failed to get league standings for predictions: %v\n", err)
+			fmt.Printf("Failed to generate predictions: %v\n", err)
 		} else {
-			var standings []models.TeamStats
-			for rows.Next() {
-				var stats models.TeamStats
-				err := rows.Scan(
-					&stats.Points, &stats.Played, &stats.Wins, &stats.Draws, &stats.Losses,
-					&stats.GoalsFor, &stats.GoalsAgainst, &stats.GoalDifference,
-					&stats.Team.ID, &stats.Team.Name,
-				)
-				if err != nil {
-					fmt.Printf("Failed to scan team stats: %v\n", err)
-					continue
-				}
-				
-				standings = append(standings, stats)
-			}
-			rows.Close()
-			
-			// Clear existing predictions
-			_, err = database.DB.Exec("DELETE FROM predictions")
-			if err != nil {
-				fmt.Printf("Failed to clear existing predictions: %v\n", err)
-			} else {
-				// Seed random number generator
-				rand.Seed(time.Now().UnixNano())
-				
-				// Generate predictions based on current standings
-				tx, err := database.DB.Begin()
-				if err != nil {
-					fmt.Printf("Failed to start transaction for predictions: %v\n", err)
-				} else {
-					// Assign probabilities based loosely on current standings
-					totalProbability := 100.0
-					var generatedPredictions []models.Prediction
-					
-					for i, stats := range standings {
-						// Calculate remaining matches
-						remainingMatches := 6 - stats.Played // Total of 6 matches per team
-						maxPossiblePoints := stats.Points + (remainingMatches * 3)
-						
-						var probability float64
-						if i == 0 {
-							// Top team gets highest probability
-							probability = 40.0 + (rand.Float64() * 10.0) // 40-50%
-						} else if i == 1 {
-							// Second team
-							probability = 25.0 + (rand.Float64() * 10.0) // 25-35%
-						} else if i == 2 {
-							// Third team
-							probability = 10.0 + (rand.Float64() * 10.0) // 10-20%
-						} else {
-							// Last team gets remaining probability
-							probability = totalProbability
-							if len(generatedPredictions) >= 3 {
-								probability = totalProbability - (generatedPredictions[0].PredictionPercentage + 
-														generatedPredictions[1].PredictionPercentage + 
-														generatedPredictions[2].PredictionPercentage)
-							}
-						}
-						
-						// Ensure we don't go below 0 or have rounding issues
-						if probability < 0 {
-							probability = 0.1
-						}
-						
-						prediction := models.Prediction{
-							TeamID:             stats.Team.ID,
-							Team:               stats.Team,
-							PredictedPosition:  i + 1, // Current position
-							PredictedPoints:    maxPossiblePoints,
-							PredictionPercentage: probability,
-						}
-						
-						generatedPredictions = append(generatedPredictions, prediction)
-						
-						// Insert prediction
-						_, err := tx.Exec(
-							"INSERT INTO predictions (team_id, predicted_position, predicted_points, prediction_percentage) VALUES ($1, $2, $3, $4)",
-							prediction.TeamID, prediction.PredictedPosition, prediction.PredictedPoints, prediction.PredictionPercentage,
-						)
-						if err != nil {
-							tx.Rollback()
-							fmt.Printf("Failed to insert prediction: %v\n", err)
-							break
-						}
-					}
-					
-					err = tx.Commit()
-					if err != nil {
-						fmt.Printf("Failed to commit transaction for predictions: %v\n", err)
-					} else {
-						return c.JSON(fiber.Map{
-							"message": "Successfully simulated week " + strconv.Itoa(week) + " and generated championship predictions",
-							"matches": updatedMatches,
-							"predictions": generatedPredictions,
-						})
-					}
-				}
-			}
+			return c.JSON(fiber.Map{
+				"message": "Successfully simulated week " + strconv.Itoa(week) + " and generated championship predictions",
+				"seed": seed,
+				"matches": updatedMatches,
+				"predictions": predictions,
+			})
 		}
 	}
 	
 	return c.JSON(fiber.Map{
 		"message": "Successfully simulated week " + strconv.Itoa(week),
+		"seed": seed,
 		"matches": updatedMatches,
 	})
 }
 
-// SimulateAllRemainingMatches handles the request to simulate all remaining matches
+// SimulateAllRemainingMatches handles the request to simulate all remaining
+// matches of the current season, or of the active season given in the route
 func SimulateAllRemainingMatches(c *fiber.Ctx) error {
+	seed, err := simulationSeed(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid seed: " + err.Error(),
+		})
+	}
+	
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+	if season.Status != models.SeasonActive {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Season " + season.Name + " is archived and cannot be simulated",
+		})
+	}
+	if status, msg := checkLeagueSeason(database.DB, season); msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	
+	// A live week is stored at full time, and nothing can be played before then
+	if liveWeek, ok := liveWeeks.playing(season.ID); ok {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Week " + strconv.Itoa(liveWeek) + " is being played live; wait for full time",
+		})
+	}
+	
 	// Check if fixtures exist
 	var count int
-	err := database.DB.QueryRow("SELECT COUNT(*) FROM matches").Scan(&count)
+	err = database.DB.QueryRow("SELECT COUNT(*) FROM matches WHERE season_id = $1", season.ID).Scan(&count)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to check fixtures: " + err.Error(),
@@ -560,7 +302,7 @@ func SimulateAllRemainingMatches(c *fiber.Ctx) error {
 	
 	// If no fixtures exist, generate them
 	if count == 0 {
-		if err := generateFixtures(); err != nil {
+		if err := generateFixtures(season.ID); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to generate fixtures: " + err.Error(),
 			})
@@ -568,142 +310,22 @@ func SimulateAllRemainingMatches(c *fiber.Ctx) error {
 	}
 	
 	// Get all unplayed matches ordered by week
-	rows, err := database.DB.Query(
-		"SELECT id, home_team_id, away_team_id, week FROM matches WHERE played = false ORDER BY week, id",
-	)
+	fixtures, err := loadUnplayedFixtures(season.ID, 0)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to get unplayed matches: " + err.Error(),
 		})
 	}
-	defer rows.Close()
-	
-	// Seed random number generator
-	rand.Seed(time.Now().UnixNano())
 	
-	// For each match, simulate the result
-	for rows.Next() {
-		var matchID, homeTeamID, awayTeamID, week int
-		err := rows.Scan(&matchID, &homeTeamID, &awayTeamID, &week)
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to scan match: " + err.Error(),
-			})
-		}
-		
-		// Generate random scores (simple simulation)
-		homeScore := rand.Intn(6) // 0-5 goals
-		awayScore := rand.Intn(6) // 0-5 goals
-		
-		// Start a transaction
-		tx, err := database.DB.Begin()
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to start transaction: " + err.Error(),
-			})
-		}
-		
-		// Update match with scores and mark as played
-		_, err = tx.Exec(
-			"UPDATE matches SET home_score = $1, away_score = $2, played = true WHERE id = $3",
-			homeScore, awayScore, matchID,
-		)
-		if err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update match: " + err.Error(),
-			})
-		}
-		
-		// Update league table for home team
-		var homePoints int
-		var homeWins, homeDraws, homeLosses int
-		if homeScore > awayScore {
-			// Home win
-			homePoints = 3
-			homeWins = 1
-		} else if homeScore == awayScore {
-			// Draw
-			homePoints = 1
-			homeDraws = 1
-		} else {
-			// Home loss
-			homeLosses = 1
-		}
-		
-		_, err = tx.Exec(`
-			UPDATE league_table SET 
-			points = points + $1,
-			played = played + 1,
-			wins = wins + $2,
-			draws = draws + $3,
-			losses = losses + $4,
-			goals_for = goals_for + $5,
-			goals_against = goals_against + $6,
-			goal_difference = goal_difference + $7
-			WHERE team_id = $8
-		`,
-			homePoints, homeWins, homeDraws, homeLosses, 
-			homeScore, awayScore, homeScore-awayScore,
-			homeTeamID,
-		)
-		if err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update home team stats: " + err.Error(),
-			})
-		}
-		
-		// Update league table for away team
-		var awayPoints int
-		var awayWins, awayDraws, awayLosses int
-		if awayScore > homeScore {
-			// Away win
-			awayPoints = 3
-			awayWins = 1
-		} else if awayScore == homeScore {
-			// Draw
-			awayPoints = 1
-			awayDraws = 1
-		} else {
-			// Away loss
-			awayLosses = 1
-		}
-		
-		_, err = tx.Exec(`
-			UPDATE league_table SET 
-			points = points + $1,
-			played = played + 1,
-			wins = wins + $2,
-			draws = draws + $3,
-			losses = losses + $4,
-			goals_for = goals_for + $5,
-			goals_against = goals_against + $6,
-			goal_difference = goal_difference + $7
-			WHERE team_id = $8
-		`,
-			awayPoints, awayWins, awayDraws, awayLosses, 
-			awayScore, homeScore, awayScore-homeScore,
-			awayTeamID,
-		)
-		if err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to update away team stats: " + err.Error(),
-			})
-		}
-		
-		// Commit transaction
-		err = tx.Commit()
-		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to commit transaction: " + err.Error(),
-			})
-		}
+	// Simulate the results and rebuild the league table
+	if err := playMatches(season.ID, fixtures, seed); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to simulate remaining matches: " + err.Error(),
+		})
 	}
 	
 	// Get all matches
-	allMatches, err := getAllMatches()
+	allMatches, err := getAllMatches(season.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to get matches after simulation",
@@ -711,279 +333,508 @@ func SimulateAllRemainingMatches(c *fiber.Ctx) error {
 	}
 
 	// Generate championship predictions after all matches are simulated
-	// Get current league standings from database
-	standingsQuery := `
-		SELECT lt.points, lt.played, lt.wins, lt.draws, lt.losses, 
-			lt.goals_for, lt.goals_against, lt.goal_difference,
-			t.id, t.name
-		FROM league_table lt
-		JOIN teams t ON lt.team_id = t.id
-		ORDER BY lt.points DESC, lt.goal_difference DESC, lt.goals_for DESC
-	`
-	
-	rows, err = database.DB.Query(standingsQuery)
+	predictions, err := generatePredictions(season.ID, seed)
 	if err != nil {
 		// Just log the error but don't fail the whole request
-		fmt.Printf("Failed to get league standings for predictions: %v\n", err)
+		fmt.Printf("Failed to generate predictions: %v\n", err)
 		return c.JSON(fiber.Map{
 			"message": "Successfully simulated all remaining matches",
+			"seed": seed,
 			"matches": allMatches,
 		})
 	}
 	
-	var standings []models.TeamStats
-	for rows.Next() {
-		var stats models.TeamStats
-		err := rows.Scan(
-			&stats.Points, &stats.Played, &stats.Wins, &stats.Draws, &stats.Losses,
-			&stats.GoalsFor, &stats.GoalsAgainst, &stats.GoalDifference,
-			&stats.Team.ID, &stats.Team.Name,
-		)
-		if err != nil {
-			fmt.Printf("Failed to scan team stats: %v\n", err)
-			continue
-		}
-		
-		standings = append(standings, stats)
+	return c.JSON(fiber.Map{
+		"message": "Successfully simulated all remaining matches and generated championship predictions",
+		"seed": seed,
+		"matches": allMatches,
+		"predictions": predictions,
+	})
+}
+
+// UpdateMatchResult handles the request to enter or correct the score of a match.
+// The league table is rebuilt from the matches afterwards, so a corrected
+// result replaces the old one, and the predictions are regenerated. The goals
+// can be credited to their scorers with the optional goals list; without it
+// the match keeps no scorers. A result entered by hand has no event timeline.
+func UpdateMatchResult(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid match ID",
+		})
 	}
-	rows.Close()
 	
-	// Clear existing predictions
-	_, err = database.DB.Exec("DELETE FROM predictions")
-	if err != nil {
-		fmt.Printf("Failed to clear existing predictions: %v\n", err)
-		return c.JSON(fiber.Map{
-			"message": "Successfully simulated all remaining matches",
-			"matches": allMatches,
+	var req struct {
+		HomeScore *int `json:"home_score"`
+		AwayScore *int `json:"away_score"`
+		
+		// Cards are optional and keep their current value when left out
+		HomeYellowCards *int `json:"home_yellow_cards"`
+		HomeRedCards    *int `json:"home_red_cards"`
+		AwayYellowCards *int `json:"away_yellow_cards"`
+		AwayRedCards    *int `json:"away_red_cards"`
+		
+		Goals []struct {
+			ScorerID int  `json:"scorer_id"`
+			AssistID *int `json:"assist_id"`
+		} `json:"goals"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to parse request body: " + err.Error(),
 		})
 	}
 	
-	// Seed random number generator
-	rand.Seed(time.Now().UnixNano())
+	if req.HomeScore == nil || req.AwayScore == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Both home_score and away_score are required",
+		})
+	}
+	if *req.HomeScore < 0 || *req.AwayScore < 0 || *req.HomeScore > 99 || *req.AwayScore > 99 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Scores must be between 0 and 99",
+		})
+	}
+	for _, cards := range []*int{req.HomeYellowCards, req.HomeRedCards, req.AwayYellowCards, req.AwayRedCards} {
+		if cards != nil && (*cards < 0 || *cards > 11) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Card counts must be between 0 and 11",
+			})
+		}
+	}
 	
-	// Generate predictions based on current standings
+	// Start a transaction
 	tx, err := database.DB.Begin()
 	if err != nil {
-		fmt.Printf("Failed to start transaction for predictions: %v\n", err)
-		return c.JSON(fiber.Map{
-			"message": "Successfully simulated all remaining matches",
-			"matches": allMatches,
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction: " + err.Error(),
+		})
+	}
+	defer tx.Rollback()
+	
+	// Lock the match so two corrections cannot interleave
+	var week, seasonID, homeTeamID, awayTeamID int
+	var status, format string
+	err = tx.QueryRow(`
+		SELECT m.week, m.season_id, m.home_team_id, m.away_team_id, s.status, c.format
+		FROM matches m
+		JOIN seasons s ON m.season_id = s.id
+		JOIN competitions c ON s.competition_id = c.id
+		WHERE m.id = $1
+		FOR UPDATE OF m
+	`, id).Scan(&week, &seasonID, &homeTeamID, &awayTeamID, &status, &format)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Match not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get match: " + err.Error(),
 		})
 	}
 	
-	// Assign probabilities based loosely on current standings
-	totalProbability := 100.0
-	var generatedPredictions []models.Prediction
+	// Archived seasons are read-only
+	if status != models.SeasonActive {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Match belongs to an archived season and cannot be changed",
+		})
+	}
 	
-	for i, stats := range standings {
-		// Calculate remaining matches
-		remainingMatches := 6 - stats.Played // Total of 6 matches per team
-		maxPossiblePoints := stats.Points + (remainingMatches * 3)
-		
-		var probability float64
-		if i == 0 {
-			// Top team gets highest probability
-			probability = 40.0 + (rand.Float64() * 10.0) // 40-50%
-		} else if i == 1 {
-			// Second team
-			probability = 25.0 + (rand.Float64() * 10.0) // 25-35%
-		} else if i == 2 {
-			// Third team
-			probability = 10.0 + (rand.Float64() * 10.0) // 10-20%
-		} else {
-			// Last team gets remaining probability
-			probability = totalProbability
-			if len(generatedPredictions) >= 3 {
-				probability = totalProbability - (generatedPredictions[0].PredictionPercentage + 
-								generatedPredictions[1].PredictionPercentage + 
-								generatedPredictions[2].PredictionPercentage)
-			}
-		}
-		
-		// Ensure we don't go below 0 or have rounding issues
-		if probability < 0 {
-			probability = 0.1
+	// Cup results decide who goes through, so they are only played by the cup draw
+	if format != models.FormatLeague {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Match belongs to a cup and cannot be changed",
+		})
+	}
+	
+	// A manually entered result has no simulation seed or engine
+	_, err = tx.Exec(`
+		UPDATE matches SET
+		home_score = $1,
+		away_score = $2,
+		played = true,
+		seed = NULL,
+		engine = NULL,
+		played_at = COALESCE(played_at, CURRENT_TIMESTAMP),
+		home_yellow_cards = COALESCE($3, home_yellow_cards),
+		home_red_cards = COALESCE($4, home_red_cards),
+		away_yellow_cards = COALESCE($5, away_yellow_cards),
+		away_red_cards = COALESCE($6, away_red_cards)
+		WHERE id = $7
+	`,
+		*req.HomeScore, *req.AwayScore,
+		req.HomeYellowCards, req.HomeRedCards, req.AwayYellowCards, req.AwayRedCards,
+		id,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update match: " + err.Error(),
+		})
+	}
+	
+	// Credit the goals sent with the result, if any
+	var credits []goalCredit
+	if len(req.Goals) > 0 {
+		squads, err := loadSquads(tx)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load squads: " + err.Error(),
+			})
 		}
 		
-		prediction := models.Prediction{
-			TeamID:             stats.Team.ID,
-			Team:               stats.Team,
-			PredictedPosition:  i + 1, // Current position
-			PredictedPoints:    maxPossiblePoints,
-			PredictionPercentage: probability,
+		for _, goal := range req.Goals {
+			credits = append(credits, goalCredit{ScorerID: goal.ScorerID, AssistID: goal.AssistID})
 		}
-		
-		generatedPredictions = append(generatedPredictions, prediction)
-		
-		// Insert prediction
-		_, err := tx.Exec(
-			"INSERT INTO predictions (team_id, predicted_position, predicted_points, prediction_percentage) VALUES ($1, $2, $3, $4)",
-			prediction.TeamID, prediction.PredictedPosition, prediction.PredictedPoints, prediction.PredictionPercentage,
-		)
-		if err != nil {
-			tx.Rollback()
-			fmt.Printf("Failed to insert prediction: %v\n", err)
-			return c.JSON(fiber.Map{
-				"message": "Successfully simulated all remaining matches",
-				"matches": allMatches,
+		if msg := validateGoalCredits(credits, squads, homeTeamID, awayTeamID, *req.HomeScore, *req.AwayScore); msg != "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": msg,
 			})
 		}
 	}
+	if err := storeGoals(tx, id, credits); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to store goals: " + err.Error(),
+		})
+	}
+	
+	// A simulated timeline would tell the story of the old result
+	if err := clearTimeline(tx, id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to clear match events: " + err.Error(),
+		})
+	}
+	
+	// Nor would the injuries and suspensions it caused
+	if _, err := tx.Exec("DELETE FROM absences WHERE match_id = $1", id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to clear absences: " + err.Error(),
+		})
+	}
+	
+	// Every rating from this match on depends on its result, so replay them
+	from, err := firstEloPosition(tx, seasonID, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to find match in rating order: " + err.Error(),
+		})
+	}
+	if err := rebuildEloRatings(tx, from, config.GetConfig()); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update Elo ratings: " + err.Error(),
+		})
+	}
+	
+	// The table is rebuilt from the match log, so the old result simply drops out
+	if err := rebuildLeagueTable(tx, seasonID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to rebuild league table: " + err.Error(),
+		})
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction: " + err.Error(),
+		})
+	}
+	
+	updatedMatches, err := getMatchesByWeek(seasonID, week)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get matches after update",
+		})
+	}
+	
+	var match models.Match
+	for _, m := range updatedMatches {
+		if m.ID == id {
+			match = m
+		}
+	}
 	
-	err = tx.Commit()
+	// The old predictions no longer match the table
+	predictions, err := generatePredictions(seasonID, time.Now().UnixNano())
 	if err != nil {
-		fmt.Printf("Failed to commit transaction for predictions: %v\n", err)
+		// Just log the error but don't fail the whole request
+		fmt.Printf("Failed to generate predictions: %v\n", err)
 		return c.JSON(fiber.Map{
-			"message": "Successfully simulated all remaining matches",
-			"matches": allMatches,
+			"message": "Match result updated successfully",
+			"match": match,
 		})
 	}
 	
 	return c.JSON(fiber.Map{
-		"message": "Successfully simulated all remaining matches and generated championship predictions",
-		"matches": allMatches,
-		"predictions": generatedPredictions,
+		"message": "Match result updated successfully and championship predictions regenerated",
+		"match": match,
+		"predictions": predictions,
 	})
 }
 
-// generateFixtures generates fixtures for the league
-func generateFixtures() error {
-	// Get all teams
-	rows, err := database.DB.Query("SELECT id FROM teams")
+// GetMatchEvents handles the request to get the timeline of a match: its goals,
+// cards, substitutions and breaks in play in the order they happened, so the
+// match can be replayed
+func GetMatchEvents(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
-		return err
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid match ID",
+		})
+	}
+	
+	match, err := getMatch(id)
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Match not found",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get match: " + err.Error(),
+		})
+	}
+	
+	events, err := loadMatchEvents(database.DB, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get match events: " + err.Error(),
+		})
+	}
+	
+	return c.JSON(fiber.Map{
+		"match":  match,
+		"events": events,
+	})
+}
+
+// loadUnplayedFixtures returns the unplayed matches of one week, or of the
+// whole season when week is 0, in the order they are simulated
+func loadUnplayedFixtures(seasonID, week int) ([]fixture, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, home_team_id, away_team_id, week
+		FROM matches
+		WHERE season_id = $1 AND played = false AND ($2 = 0 OR week = $2)
+		ORDER BY week, id
+	`, seasonID, week)
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 	
-	var teams []int
+	var fixtures []fixture
 	for rows.Next() {
-		var teamID int
-		err := rows.Scan(&teamID)
-		if err != nil {
-			return err
+		var f fixture
+		if err := rows.Scan(&f.ID, &f.HomeTeamID, &f.AwayTeamID, &f.Week); err != nil {
+			return nil, err
 		}
-		teams = append(teams, teamID)
+		fixtures = append(fixtures, f)
 	}
 	
-	// Check if we have enough teams
-	if len(teams) < 2 {
-		return errors.New("not enough teams to generate fixtures")
-	}
-	
-	// Start a transaction
+	return fixtures, rows.Err()
+}
+
+// playMatches simulates the given matches with the match engine and rebuilds
+// the league table in a single transaction, so either every result is stored
+// or none is
+func playMatches(seasonID int, fixtures []fixture, seed int64) error {
 	tx, err := database.DB.Begin()
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to start transaction: %v", err)
 	}
 	defer tx.Rollback()
 	
-	// For our specific case with 4 teams, we'll manually create a balanced fixture
-	// that spreads across 6 weeks
-	if len(teams) == 4 {
-		// First 3 weeks - each team plays against others once
-		fixtures := []struct {
-			home int
-			away int
-			week int
-		}{
-			// Week 1
-			{teams[0], teams[1], 1},
-			{teams[2], teams[3], 1},
-			
-			// Week 2
-			{teams[0], teams[2], 2},
-			{teams[1], teams[3], 2},
-			
-			// Week 3
-			{teams[0], teams[3], 3},
-			{teams[1], teams[2], 3},
-			
-			// Week 4 - reverse fixtures
-			{teams[1], teams[0], 4},
-			{teams[3], teams[2], 4},
-			
-			// Week 5
-			{teams[2], teams[0], 5},
-			{teams[3], teams[1], 5},
+	if _, err := playMatchesTx(tx, seasonID, fixtures, seed, nil); err != nil {
+		return err
+	}
+	
+	return tx.Commit()
+}
+
+// decidedMatch is the result of a simulated match with its timeline
+type decidedMatch struct {
+	HomeScore int
+	AwayScore int
+	Timeline  matchTimeline
+}
+
+// playMatchesTx plays the given matches inside the given transaction and
+// returns their results by match ID. Matches found in decided keep the result
+// and timeline given there instead of being simulated again.
+func playMatchesTx(tx *sql.Tx, seasonID int, fixtures []fixture, seed int64, decided map[int]decidedMatch) (map[int]decidedMatch, error) {
+	// Load team ratings for the match engine
+	strengths, err := loadTeamStrengths()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load team ratings: %v", err)
+	}
+	squads, err := loadSquads(database.DB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load squads: %v", err)
+	}
+	cfg := config.GetConfig()
+	engine, err := seasonEngine(database.DB, seasonID, cfg)
+	if err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(seed))
+	
+	// Timelines and injuries come from streams of their own so the scores of a seed stay the same
+	timelineRng := rand.New(rand.NewSource(seed))
+	injuryRng := rand.New(rand.NewSource(seed))
+	
+	// Form and fatigue follow the matches as they are played
+	form, err := loadFormTracker(tx, seasonID, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load team form: %v", err)
+	}
+	availability, err := loadAvailability(tx, seasonID, squads, cfg)
+	if err != nil {
+		return nil, err
+	}
+	
+	var played []eloMatch
+	results := make(map[int]decidedMatch, len(fixtures))
+	for _, f := range fixtures {
+		result, ok := decided[f.ID]
+		if !ok {
+			// Sample the score from the team ratings, adjusted for absences, form and fatigue
+			home := availability.strength(strengthOf(strengths, f.HomeTeamID), f.HomeTeamID, f.Week)
+			away := availability.strength(strengthOf(strengths, f.AwayTeamID), f.AwayTeamID, f.Week)
+			result.HomeScore, result.AwayScore = engine.Score(rng, form.strength(home, f.HomeTeamID), form.strength(away, f.AwayTeamID))
 			
-			// Week 6
-			{teams[3], teams[0], 6},
-			{teams[2], teams[1], 6},
+			// Play out the goals, cards and substitutions minute by minute
+			result.Timeline = simulateTimeline(timelineRng, timelineMatch{
+				HomeTeamID: f.HomeTeamID,
+				AwayTeamID: f.AwayTeamID,
+				HomeGoals:  result.HomeScore,
+				AwayGoals:  result.AwayScore,
+			}, availability.matchSquads(f.Week, f.HomeTeamID, f.AwayTeamID))
+		}
+		results[f.ID] = result
+		homeScore, awayScore, timeline := result.HomeScore, result.AwayScore, result.Timeline
+		form.record(f.HomeTeamID, f.AwayTeamID, homeScore, awayScore)
+		
+		// Update match with scores and cards and mark as played
+		_, err := tx.Exec(`
+			UPDATE matches SET
+			home_score = $1,
+			away_score = $2,
+			played = true,
+			seed = $3,
+			home_yellow_cards = $4,
+			home_red_cards = $5,
+			away_yellow_cards = $6,
+			away_red_cards = $7,
+			engine = $8,
+			played_at = CURRENT_TIMESTAMP
+			WHERE id = $9
+		`, homeScore, awayScore, seed, timeline.HomeYellowCards, timeline.HomeRedCards, timeline.AwayYellowCards, timeline.AwayRedCards, engine.Name(), f.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update match %d: %v", f.ID, err)
 		}
 		
-		for _, fixture := range fixtures {
-			_, err = tx.Exec(
-				"INSERT INTO matches (home_team_id, away_team_id, week, played) VALUES ($1, $2, $3, false)",
-				fixture.home, fixture.away, fixture.week,
-			)
-			if err != nil {
-				return err
-			}
+		if err := storeTimeline(tx, f.ID, timeline); err != nil {
+			return nil, err
 		}
 		
-		return tx.Commit()
+		// Players hurt or sent off miss the matches that follow
+		if err := availability.record(tx, injuryRng, f.ID, f.Week, f.HomeTeamID, f.AwayTeamID, timeline); err != nil {
+			return nil, err
+		}
+		played = append(played, eloMatch{MatchID: f.ID, HomeTeamID: f.HomeTeamID, AwayTeamID: f.AwayTeamID, HomeScore: homeScore, AwayScore: awayScore})
+	}
+	
+	if err := recordEloRatings(tx, played, cfg); err != nil {
+		return nil, err
 	}
 	
-	// For other number of teams, use the algorithm
-	totalWeeks := 6 // Fix to 6 weeks as shown in the example
+	if err := rebuildLeagueTable(tx, seasonID); err != nil {
+		return nil, fmt.Errorf("failed to rebuild league table: %v", err)
+	}
 	
-	// Create a list of all possible matchups
-	var matchups []struct {
-		homeTeam int
-		awayTeam int
+	return results, nil
+}
+
+// simulationSeed returns the seed given in the ?seed= query parameter, or a
+// time-based seed when none is given, so every simulation can be replayed
+func simulationSeed(c *fiber.Ctx) (int64, error) {
+	value := c.Query("seed")
+	if value == "" {
+		return time.Now().UnixNano(), nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// errNotEnoughTeams is returned when a season has fewer than two teams to schedule
+var errNotEnoughTeams = errors.New("not enough teams to generate fixtures")
+
+// generateFixtures generates fixtures for the teams entered in a season
+func generateFixtures(seasonID int) error {
+	// Start a transaction
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 	
-	for i := 0; i < len(teams); i++ {
-		for j := 0; j < len(teams); j++ {
-			if i != j { // Teams don't play against themselves
-				matchups = append(matchups, struct {
-					homeTeam int
-					awayTeam int
-				}{
-					homeTeam: teams[i],
-					awayTeam: teams[j],
-				})
-			}
+	if err := insertFixtures(tx, seasonID); err != nil {
+		return err
+	}
+	
+	return tx.Commit()
+}
+
+// insertFixtures stores a double round-robin for a season's teams inside the given transaction
+func insertFixtures(tx *sql.Tx, seasonID int) error {
+	// Get the season's teams
+	rows, err := tx.Query("SELECT team_id FROM league_table WHERE season_id = $1 ORDER BY team_id", seasonID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	
+	var teams []int
+	for rows.Next() {
+		var teamID int
+		err := rows.Scan(&teamID)
+		if err != nil {
+			return err
 		}
+		teams = append(teams, teamID)
 	}
+	rows.Close()
 	
-	// Shuffle matchups to randomize the fixture
-	rand.Seed(time.Now().UnixNano())
-	rand.Shuffle(len(matchups), func(i, j int) {
-		matchups[i], matchups[j] = matchups[j], matchups[i]
-	})
+	// Check if we have enough teams
+	if len(teams) < 2 {
+		return errNotEnoughTeams
+	}
 	
-	// Distribute matchups across weeks
-	for i, matchup := range matchups {
-		week := (i % totalWeeks) + 1 // Weeks are 1-indexed
-		
-		// Insert fixture
+	// Build a double round-robin where each team plays once per week
+	for _, fixture := range roundRobinFixtures(teams) {
 		_, err = tx.Exec(
-			"INSERT INTO matches (home_team_id, away_team_id, week, played) VALUES ($1, $2, $3, false)",
-			matchup.homeTeam, matchup.awayTeam, week,
+			"INSERT INTO matches (season_id, home_team_id, away_team_id, week, played) VALUES ($1, $2, $3, $4, false)",
+			seasonID, fixture.HomeTeamID, fixture.AwayTeamID, fixture.Week,
 		)
 		if err != nil {
 			return err
 		}
 	}
 	
-	return tx.Commit()
+	return nil
 }
 
-// getAllMatches returns all matches from the database
-func getAllMatches() ([]models.Match, error) {
+// getAllMatches returns all matches of a season
+func getAllMatches(seasonID int) ([]models.Match, error) {
 	// Query all matches directly from database
 	query := `
-		SELECT m.id, m.home_team_id, m.away_team_id, m.home_score, m.away_score, 
-		       m.week, m.played, m.created_at,
-		       ht.id, ht.name, at.id, at.name
+		SELECT ` + matchColumns + `
 		FROM matches m
 		JOIN teams ht ON m.home_team_id = ht.id
 		JOIN teams at ON m.away_team_id = at.id
+		WHERE m.season_id = $1
 		ORDER BY m.week, m.id
 	`
 	
-	rows, err := database.DB.Query(query)
+	rows, err := database.DB.Query(query, seasonID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get matches: %v", err)
 	}
@@ -991,43 +842,35 @@ func getAllMatches() ([]models.Match, error) {
 	
 	var matches []models.Match
 	for rows.Next() {
-		var match models.Match
-		var createdAt sql.NullTime
-		
-		err := rows.Scan(
-			&match.ID, &match.HomeTeamID, &match.AwayTeamID, &match.HomeScore, &match.AwayScore,
-			&match.Week, &match.Played, &createdAt,
-			&match.HomeTeam.ID, &match.HomeTeam.Name, &match.AwayTeam.ID, &match.AwayTeam.Name,
-		)
+		match, err := scanMatch(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan match: %v", err)
 		}
 		
-		if createdAt.Valid {
-			match.CreatedAt = createdAt.Time
-		}
-		
 		matches = append(matches, match)
 	}
 	
 	return matches, nil
 }
 
-// getMatchesByWeek returns matches for a specific week
-func getMatchesByWeek(week int) ([]models.Match, error) {
+// getMatchesByWeek returns the matches of a season for a specific week
+func getMatchesByWeek(seasonID, week int) ([]models.Match, error) {
+	return loadWeekMatches(database.DB, seasonID, week)
+}
+
+// loadWeekMatches returns the matches of a season for a specific week through the given querier
+func loadWeekMatches(q querier, seasonID, week int) ([]models.Match, error) {
 	// Query matches for the specific week directly from database
 	query := `
-		SELECT m.id, m.home_team_id, m.away_team_id, m.home_score, m.away_score, 
-		       m.week, m.played, m.created_at,
-		       ht.id, ht.name, at.id, at.name
+		SELECT ` + matchColumns + `
 		FROM matches m
 		JOIN teams ht ON m.home_team_id = ht.id
 		JOIN teams at ON m.away_team_id = at.id
-		WHERE m.week = $1
+		WHERE m.season_id = $1 AND m.week = $2
 		ORDER BY m.id
 	`
 	
-	rows, err := database.DB.Query(query, week)
+	rows, err := q.Query(query, seasonID, week)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get matches for week %d: %v", week, err)
 	}
@@ -1035,24 +878,51 @@ func getMatchesByWeek(week int) ([]models.Match, error) {
 	
 	var matches []models.Match
 	for rows.Next() {
-		var match models.Match
-		var createdAt sql.NullTime
-		
-		err := rows.Scan(
-			&match.ID, &match.HomeTeamID, &match.AwayTeamID, &match.HomeScore, &match.AwayScore,
-			&match.Week, &match.Played, &createdAt,
-			&match.HomeTeam.ID, &match.HomeTeam.Name, &match.AwayTeam.ID, &match.AwayTeam.Name,
-		)
+		match, err := scanMatch(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan match: %v", err)
 		}
 		
-		if createdAt.Valid {
-			match.CreatedAt = createdAt.Time
-		}
-		
 		matches = append(matches, match)
 	}
 	
 	return matches, nil
-} 
\ No newline at end of file
+} 
+
+// getMatch returns a single match with its teams
+func getMatch(id int) (models.Match, error) {
+	return scanMatch(database.DB.QueryRow(`
+		SELECT `+matchColumns+`
+		FROM matches m
+		JOIN teams ht ON m.home_team_id = ht.id
+		JOIN teams at ON m.away_team_id = at.id
+		WHERE m.id = $1
+	`, id))
+}
+
+// matchColumns lists the columns read by scanMatch, in order, from matches m
+// joined with its home team ht and away team at
+const matchColumns = "m.id, m.season_id, m.home_team_id, m.away_team_id, m.home_score, m.away_score, " +
+	"m.week, m.played, m.seed, m.engine, m.extra_time, m.home_penalties, m.away_penalties, m.created_at, " +
+	"ht.id, ht.name, at.id, at.name"
+
+// scanMatch reads a match row selected with matchColumns
+func scanMatch(row rowScanner) (models.Match, error) {
+	var match models.Match
+	var createdAt sql.NullTime
+	
+	err := row.Scan(
+		&match.ID, &match.SeasonID, &match.HomeTeamID, &match.AwayTeamID, &match.HomeScore, &match.AwayScore,
+		&match.Week, &match.Played, &match.Seed, &match.Engine, &match.ExtraTime, &match.HomePenalties, &match.AwayPenalties, &createdAt,
+		&match.HomeTeam.ID, &match.HomeTeam.Name, &match.AwayTeam.ID, &match.AwayTeam.Name,
+	)
+	if err != nil {
+		return match, err
+	}
+	
+	if createdAt.Valid {
+		match.CreatedAt = createdAt.Time
+	}
+	
+	return match, nil
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/database"
+)
+
+// teamStrength holds the ratings the match engines use for one team.
+// An attack or defence rating of 1.0 is league average; a higher attack
+// rating scores more and a higher defence rating concedes less. Elo is the
+// team's Elo rating, for the engines that go by it.
+type teamStrength struct {
+	Attack  float64
+	Defence float64
+	Elo     float64
+}
+
+// defaultStrength is used for teams that have no ratings stored
+var defaultStrength = teamStrength{Attack: 1.0, Defence: 1.0, Elo: initialEloRating}
+
+// scaled returns the strength multiplied by a factor, as absences, form and
+// fatigue change it. The Elo rating moves by the gap that changes the odds
+// between two sides by the same factor.
+func (s teamStrength) scaled(factor float64) teamStrength {
+	return teamStrength{
+		Attack:  s.Attack * factor,
+		Defence: s.Defence * factor,
+		Elo:     s.Elo + 400*math.Log10(factor),
+	}
+}
+
+// loadTeamStrengths reads the attack, defence and Elo ratings of every team
+func loadTeamStrengths() (map[int]teamStrength, error) {
+	rows, err := database.DB.Query("SELECT id, attack_rating, defence_rating, elo_rating FROM teams")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	strengths := make(map[int]teamStrength)
+	for rows.Next() {
+		var teamID int
+		var strength teamStrength
+		if err := rows.Scan(&teamID, &strength.Attack, &strength.Defence, &strength.Elo); err != nil {
+			return nil, err
+		}
+		strengths[teamID] = strength
+	}
+
+	return strengths, rows.Err()
+}
+
+// strengthOf returns the strength of a team, falling back to league average
+func strengthOf(strengths map[int]teamStrength, teamID int) teamStrength {
+	if strength, ok := strengths[teamID]; ok {
+		return strength
+	}
+	return defaultStrength
+}
+
+// expectedGoals returns the Poisson means for the home and away side with
+// the configured average goals and home advantage
+func expectedGoals(home, away teamStrength, cfg *config.Config) (float64, float64) {
+	return poissonEngine{averageGoals: cfg.AverageGoals, homeAdvantage: cfg.HomeAdvantage}.expected(home, away)
+}
+
+// samplePoisson draws a value from a Poisson distribution with the given mean
+// using Knuth's multiplication method, which is fast for football-sized means
+func samplePoisson(rng *rand.Rand, lambda float64) int {
+	if lambda <= 0 {
+		return 0
+	}
+
+	limit := math.Exp(-lambda)
+	goals := 0
+	product := rng.Float64()
+	for product > limit {
+		goals++
+		product *= rng.Float64()
+	}
+
+	return goals
+}
+
+// extraTimeShare is the length of extra time as a share of normal time
+const extraTimeShare = 1.0 / 3.0
+
+// simulateExtraTime samples the goals scored in extra time. Both sides keep
+// their expected goals per minute, so the means are a third of a full match.
+func simulateExtraTime(rng *rand.Rand, home, away teamStrength, cfg *config.Config) (int, int) {
+	homeLambda, awayLambda := expectedGoals(home, away, cfg)
+	return samplePoisson(rng, homeLambda*extraTimeShare), samplePoisson(rng, awayLambda*extraTimeShare)
+}
+
+// penaltyChance is the probability that a penalty taken by one team against
+// the other is scored. Better attacks score more often and better defences
+// save more, around the configured league-wide conversion rate.
+func penaltyChance(taker, keeper teamStrength, cfg *config.Config) float64 {
+	chance := cfg.PenaltyConversion * math.Sqrt(taker.Attack/keeper.Defence)
+	return math.Max(0.05, math.Min(0.98, chance))
+}
+
+// simulateShootout plays a penalty shootout: five kicks each, stopping as soon
+// as one side cannot be caught, then sudden death until one side leads.
+// It returns the penalties scored by the home and away side.
+func simulateShootout(rng *rand.Rand, home, away teamStrength, cfg *config.Config) (int, int) {
+	homeChance := penaltyChance(home, away, cfg)
+	awayChance := penaltyChance(away, home, cfg)
+
+	homeGoals, awayGoals := 0, 0
+	for kick := 1; kick <= 5; kick++ {
+		if rng.Float64() < homeChance {
+			homeGoals++
+		}
+		if homeGoals > awayGoals+6-kick || awayGoals > homeGoals+5-kick {
+			return homeGoals, awayGoals
+		}
+
+		if rng.Float64() < awayChance {
+			awayGoals++
+		}
+		if homeGoals > awayGoals+5-kick || awayGoals > homeGoals+5-kick {
+			return homeGoals, awayGoals
+		}
+	}
+
+	for homeGoals == awayGoals {
+		if rng.Float64() < homeChance {
+			homeGoals++
+		}
+		if rng.Float64() < awayChance {
+			awayGoals++
+		}
+	}
+
+	return homeGoals, awayGoals
+}
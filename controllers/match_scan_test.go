@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeRow scans a row of the given columns, failing like database/sql does
+// when the number of destinations differs
+type fakeRow struct {
+	columns []string
+}
+
+func (r fakeRow) Scan(dest ...interface{}) error {
+	if len(dest) != len(r.columns) {
+		return fmt.Errorf("sql: expected %d destination arguments in Scan, not %d", len(r.columns), len(dest))
+	}
+	for i, d := range dest {
+		if id, ok := d.(*int); ok {
+			*id = i + 1
+		}
+	}
+	return nil
+}
+
+// Every match query selects matchColumns and reads it with scanMatch, so the
+// two must stay in step
+func TestScanMatchReadsEveryColumn(t *testing.T) {
+	columns := strings.Split(matchColumns, ",")
+	match, err := scanMatch(fakeRow{columns: columns})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// m.id is the first column
+	if match.ID != 1 {
+		t.Fatalf("match ID read from column %d, want 1", match.ID)
+	}
+}
@@ -0,0 +1,367 @@
+package controllers
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/database"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// Limits for the player fields
+const (
+	minShirtNumber = 1
+	maxShirtNumber = 99
+
+	// defaultTopPlayers is the length of the top scorer and top assist lists
+	// when no ?limit= is given, maxTopPlayers the longest list served
+	defaultTopPlayers = 10
+	maxTopPlayers     = 100
+)
+
+// GetTeamPlayers handles the request to view a team's squad in shirt number order
+func GetTeamPlayers(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+
+	if _, err := getTeam(database.DB, id); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Team not found",
+		})
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT id, team_id, name, position, rating, shirt_number FROM players WHERE team_id = $1 ORDER BY shirt_number", id,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get players: " + err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	players := []models.Player{}
+	for rows.Next() {
+		var player models.Player
+		err := rows.Scan(&player.ID, &player.TeamID, &player.Name, &player.Position, &player.Rating, &player.ShirtNumber)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to scan player: " + err.Error(),
+			})
+		}
+		players = append(players, player)
+	}
+
+	return c.JSON(players)
+}
+
+// playerRequest is the body accepted by the create and update player
+// endpoints. Fields left out of an update keep their current value.
+type playerRequest struct {
+	Name        *string  `json:"name"`
+	Position    *string  `json:"position"`
+	Rating      *float64 `json:"rating"`
+	ShirtNumber *int     `json:"shirt_number"`
+}
+
+// CreatePlayer handles the request to add a player to a team's squad. The
+// player is credited with goals from the next simulated match on.
+func CreatePlayer(c *fiber.Ctx) error {
+	teamID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+
+	var req playerRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to parse request body: " + err.Error(),
+		})
+	}
+
+	if req.Name == nil || req.Position == nil || req.ShirtNumber == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Name, position and shirt_number are required",
+		})
+	}
+
+	player := models.Player{TeamID: teamID, Rating: 1.0}
+	if msg := applyPlayerRequest(&player, req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction: " + err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	if _, err := getTeam(tx, teamID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Team not found",
+		})
+	}
+
+	if status, msg := checkShirtNumberFree(tx, player); msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	err = tx.QueryRow(
+		"INSERT INTO players (team_id, name, position, rating, shirt_number) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		player.TeamID, player.Name, player.Position, player.Rating, player.ShirtNumber,
+	).Scan(&player.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create player: " + err.Error(),
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Player created successfully",
+		"player":  player,
+	})
+}
+
+// UpdatePlayer handles the request to change a player's name, position,
+// rating or shirt number. Goals already credited to the player are kept.
+func UpdatePlayer(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid player ID",
+		})
+	}
+
+	var req playerRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to parse request body: " + err.Error(),
+		})
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction: " + err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	player, err := getPlayer(tx, id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Player not found",
+		})
+	}
+
+	if msg := applyPlayerRequest(&player, req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if status, msg := checkShirtNumberFree(tx, player); msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	_, err = tx.Exec(
+		"UPDATE players SET name = $1, position = $2, rating = $3, shirt_number = $4 WHERE id = $5",
+		player.Name, player.Position, player.Rating, player.ShirtNumber, player.ID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update player: " + err.Error(),
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Player updated successfully",
+		"player":  player,
+	})
+}
+
+// DeletePlayer handles the request to remove a player from a squad. Players
+// credited with a goal or an assist are kept so the scorer lists stay intact.
+func DeletePlayer(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid player ID",
+		})
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction: " + err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	player, err := getPlayer(tx, id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Player not found",
+		})
+	}
+
+	var credited int
+	err = tx.QueryRow("SELECT COUNT(*) FROM match_goals WHERE scorer_id = $1 OR assist_id = $1", id).Scan(&credited)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check player goals: " + err.Error(),
+		})
+	}
+	if credited > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Player " + player.Name + " has been credited with " + strconv.Itoa(credited) + " goals or assists and cannot be deleted",
+		})
+	}
+
+	if _, err := tx.Exec("DELETE FROM players WHERE id = $1", id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete player: " + err.Error(),
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Player " + player.Name + " deleted successfully",
+	})
+}
+
+// GetTopScorers handles the request to view the players with the most goals
+// in the current season, or in the season given in the route
+func GetTopScorers(c *fiber.Ctx) error {
+	return getTopPlayers(c, statGoals)
+}
+
+// GetTopAssists handles the request to view the players with the most
+// assists in the current season, or in the season given in the route
+func GetTopAssists(c *fiber.Ctx) error {
+	return getTopPlayers(c, statAssists)
+}
+
+// getTopPlayers serves a player ranking by the given statistic, as long as
+// the ?limit= query parameter asks for
+func getTopPlayers(c *fiber.Ctx, stat string) error {
+	limit := c.QueryInt("limit", defaultTopPlayers)
+	if limit < 1 || limit > maxTopPlayers {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Limit must be between 1 and " + strconv.Itoa(maxTopPlayers),
+		})
+	}
+
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+
+	totals, err := loadTopPlayers(database.DB, season.ID, stat, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get top " + stat + ": " + err.Error(),
+		})
+	}
+	if totals == nil {
+		totals = []models.PlayerTotal{}
+	}
+
+	return c.JSON(fiber.Map{
+		"season":  season,
+		"players": totals,
+	})
+}
+
+// getPlayer loads a single player
+func getPlayer(q querier, id int) (models.Player, error) {
+	var player models.Player
+	err := q.QueryRow(
+		"SELECT id, team_id, name, position, rating, shirt_number FROM players WHERE id = $1", id,
+	).Scan(&player.ID, &player.TeamID, &player.Name, &player.Position, &player.Rating, &player.ShirtNumber)
+	return player, err
+}
+
+// applyPlayerRequest copies the fields present in req onto player and
+// validates the result. It returns a message describing the first problem found.
+func applyPlayerRequest(player *models.Player, req playerRequest) string {
+	if req.Name != nil {
+		player.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Position != nil {
+		player.Position = strings.ToUpper(strings.TrimSpace(*req.Position))
+	}
+	if req.Rating != nil {
+		player.Rating = *req.Rating
+	}
+	if req.ShirtNumber != nil {
+		player.ShirtNumber = *req.ShirtNumber
+	}
+
+	if player.Name == "" || len(player.Name) > 100 {
+		return "Player name must be between 1 and 100 characters"
+	}
+	if _, ok := scoringWeights[player.Position]; !ok {
+		return "Position must be one of GK, DEF, MID and FWD"
+	}
+	if player.Rating < minTeamRating || player.Rating > maxTeamRating {
+		return "Rating must be between 0.1 and 5.0"
+	}
+	if player.ShirtNumber < minShirtNumber || player.ShirtNumber > maxShirtNumber {
+		return "Shirt number must be between 1 and 99"
+	}
+
+	return ""
+}
+
+// checkShirtNumberFree makes sure no teammate already wears the player's shirt number
+func checkShirtNumberFree(tx *sql.Tx, player models.Player) (int, string) {
+	var count int
+	err := tx.QueryRow(
+		"SELECT COUNT(*) FROM players WHERE team_id = $1 AND shirt_number = $2 AND id <> $3",
+		player.TeamID, player.ShirtNumber, player.ID,
+	).Scan(&count)
+	if err != nil {
+		return fiber.StatusInternalServerError, "Failed to check shirt number: " + err.Error()
+	}
+	if count > 0 {
+		return fiber.StatusConflict, "Shirt number " + strconv.Itoa(player.ShirtNumber) + " is already taken in this squad"
+	}
+
+	return 0, ""
+}
@@ -2,26 +2,42 @@ package controllers
 
 import (
 	"database/sql"
-	"math/rand"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/sametyildirim314/insider_case/database"
 	"github.com/sametyildirim314/insider_case/models"
 )
 
-// GetPredictions handles the request to get all predictions
+// GetPredictions handles the request to get all predictions of a season, each
+// with the team's exact outlook next to the sampled figures
 func GetPredictions(c *fiber.Ctx) error {
+	top, err := outlookTop(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid top, it must be a positive number",
+		})
+	}
+	
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+	
 	// Query predictions directly from the database
 	query := `
-		SELECT p.id, p.team_id, p.predicted_position, p.predicted_points, 
-		       p.prediction_percentage, p.created_at, t.id, t.name
+		SELECT p.id, p.season_id, p.run_id, p.team_id, p.predicted_position, p.predicted_points, 
+		       p.prediction_percentage, COALESCE(p.expected_points, 0),
+		       COALESCE(p.points_lower, 0), COALESCE(p.points_upper, 0),
+		       p.created_at, t.id, t.name
 		FROM predictions p
 		JOIN teams t ON p.team_id = t.id
+		WHERE p.season_id = $1 AND p.run_id = (SELECT MAX(id) FROM prediction_runs WHERE season_id = $1)
 		ORDER BY p.predicted_position
 	`
 	
-	rows, err := database.DB.Query(query)
+	rows, err := database.DB.Query(query, season.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to get predictions: " + err.Error(),
@@ -35,8 +51,9 @@ func GetPredictions(c *fiber.Ctx) error {
 		var createdAt sql.NullTime
 		
 		err := rows.Scan(
-			&prediction.ID, &prediction.TeamID, &prediction.PredictedPosition,
-			&prediction.PredictedPoints, &prediction.PredictionPercentage, &createdAt,
+			&prediction.ID, &prediction.SeasonID, &prediction.RunID, &prediction.TeamID, &prediction.PredictedPosition,
+			&prediction.PredictedPoints, &prediction.PredictionPercentage, &prediction.ExpectedPoints,
+			&prediction.PointsLower, &prediction.PointsUpper, &createdAt,
 			&prediction.Team.ID, &prediction.Team.Name,
 		)
 		if err != nil {
@@ -52,165 +69,186 @@ func GetPredictions(c *fiber.Ctx) error {
 		predictions = append(predictions, prediction)
 	}
 	
+	// The outlook is exact, worked out from the current table rather than sampled
+	outlooks, err := seasonOutlooks(season, top)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to work out outlooks: " + err.Error(),
+		})
+	}
+	for i := range predictions {
+		predictions[i].Outlook = outlooks[predictions[i].TeamID]
+	}
+	
 	return c.JSON(predictions)
 }
 
-// SubmitPrediction handles the request to submit a new prediction
-func SubmitPrediction(c *fiber.Ctx) error {
-	var prediction models.Prediction
-	
-	if err := c.BodyParser(&prediction); err != nil {
+// GetPositionProbabilities handles the request to get every team's probability
+// of finishing in each league position, together with its expected points
+func GetPositionProbabilities(c *fiber.Ctx) error {
+	top, err := outlookTop(c)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Failed to parse request body: " + err.Error(),
+			"error": "Invalid top, it must be a positive number",
 		})
 	}
 	
-	// Insert prediction directly into database
-	query := `
-		INSERT INTO predictions (team_id, predicted_position, predicted_points, prediction_percentage)
-		VALUES ($1, $2, $3, $4)
-	`
-	
-	_, err := database.DB.Exec(
-		query, 
-		prediction.TeamID, 
-		prediction.PredictedPosition, 
-		prediction.PredictedPoints, 
-		prediction.PredictionPercentage,
-	)
-	
+	season, err := requestSeason(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to submit prediction: " + err.Error(),
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
 		})
 	}
 	
-	return c.JSON(fiber.Map{
-		"message": "Prediction submitted successfully",
-		"prediction": prediction,
-	})
-}
-
-// GenerateChampionshipProbabilities handles the request to generate prediction percentages
-func GenerateChampionshipProbabilities(c *fiber.Ctx) error {
-	// Get current league standings from database
-	standingsQuery := `
-		SELECT lt.points, lt.played, lt.wins, lt.draws, lt.losses, 
-		       lt.goals_for, lt.goals_against, lt.goal_difference,
-		       t.id, t.name
-		FROM league_table lt
-		JOIN teams t ON lt.team_id = t.id
-		ORDER BY lt.points DESC, lt.goal_difference DESC, lt.goals_for DESC
+	query := `
+		SELECT p.id, p.season_id, p.run_id, p.team_id, p.predicted_position, p.predicted_points,
+		       p.prediction_percentage, COALESCE(p.expected_points, 0),
+		       COALESCE(p.points_lower, 0), COALESCE(p.points_upper, 0),
+		       p.created_at, t.id, t.name
+		FROM predictions p
+		JOIN teams t ON p.team_id = t.id
+		WHERE p.season_id = $1 AND p.run_id = (SELECT MAX(id) FROM prediction_runs WHERE season_id = $1)
+		ORDER BY p.predicted_position
 	`
 	
-	rows, err := database.DB.Query(standingsQuery)
+	rows, err := database.DB.Query(query, season.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to get league standings: " + err.Error(),
+			"error": "Failed to get predictions: " + err.Error(),
 		})
 	}
+	defer rows.Close()
 	
-	var standings []models.TeamStats
+	var predictions []models.Prediction
+	index := make(map[int]int)
 	for rows.Next() {
-		var stats models.TeamStats
+		var prediction models.Prediction
+		var createdAt sql.NullTime
+		
 		err := rows.Scan(
-			&stats.Points, &stats.Played, &stats.Wins, &stats.Draws, &stats.Losses,
-			&stats.GoalsFor, &stats.GoalsAgainst, &stats.GoalDifference,
-			&stats.Team.ID, &stats.Team.Name,
+			&prediction.ID, &prediction.SeasonID, &prediction.RunID, &prediction.TeamID, &prediction.PredictedPosition,
+			&prediction.PredictedPoints, &prediction.PredictionPercentage, &prediction.ExpectedPoints,
+			&prediction.PointsLower, &prediction.PointsUpper, &createdAt,
+			&prediction.Team.ID, &prediction.Team.Name,
 		)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to scan team stats: " + err.Error(),
+				"error": "Failed to scan prediction: " + err.Error(),
 			})
 		}
 		
-		standings = append(standings, stats)
+		if createdAt.Valid {
+			prediction.CreatedAt = createdAt.Time
+		}
+		
+		index[prediction.ID] = len(predictions)
+		predictions = append(predictions, prediction)
 	}
 	rows.Close()
 	
-	// Clear existing predictions
-	_, err = database.DB.Exec("DELETE FROM predictions")
+	// Attach the position distribution of every prediction
+	positionRows, err := database.DB.Query(`
+		SELECT pp.prediction_id, pp.position, pp.probability
+		FROM prediction_positions pp
+		JOIN predictions p ON pp.prediction_id = p.id
+		WHERE p.season_id = $1 AND p.run_id = (SELECT MAX(id) FROM prediction_runs WHERE season_id = $1)
+		ORDER BY pp.prediction_id, pp.position
+	`, season.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get position probabilities: " + err.Error(),
+		})
+	}
+	defer positionRows.Close()
+	
+	for positionRows.Next() {
+		var predictionID int
+		var position models.PositionProbability
+		if err := positionRows.Scan(&predictionID, &position.Position, &position.Probability); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to scan position probability: " + err.Error(),
+			})
+		}
+		
+		if i, ok := index[predictionID]; ok {
+			predictions[i].Positions = append(predictions[i].Positions, position)
+		}
+	}
+	
+	// The outlook is exact, worked out from the current table rather than sampled
+	outlooks, err := seasonOutlooks(season, top)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to clear existing predictions: " + err.Error(),
+			"error": "Failed to work out outlooks: " + err.Error(),
 		})
 	}
+	for i := range predictions {
+		predictions[i].Outlook = outlooks[predictions[i].TeamID]
+	}
 	
-	// Seed random number generator
-	rand.Seed(time.Now().UnixNano())
+	return c.JSON(predictions)
+}
+
+// GetPredictionHistory handles the request to get every prediction run of the
+// current season, or of the season given in the route, with each team's title
+// probability week by week for charting how the race changed
+func GetPredictionHistory(c *fiber.Ctx) error {
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
 	
-	// Generate predictions based on current standings
-	tx, err := database.DB.Begin()
+	history, err := loadPredictionHistory(database.DB, season.ID)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to start transaction: " + err.Error(),
+			"error": "Failed to get prediction history: " + err.Error(),
 		})
 	}
 	
-	// Assign probabilities based loosely on current standings
-	totalProbability := 100.0
-	var predictions []models.Prediction
+	return c.JSON(history)
+}
+
+// GenerateChampionshipProbabilities handles the request to generate prediction percentages
+// by simulating the remaining fixtures of the current season, or of the
+// active season given in the route
+func GenerateChampionshipProbabilities(c *fiber.Ctx) error {
+	seed, err := simulationSeed(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid seed: " + err.Error(),
+		})
+	}
 	
-	for i, stats := range standings {
-		// Calculate remaining matches
-		remainingMatches := 6 - stats.Played // Total of 6 matches per team
-		maxPossiblePoints := stats.Points + (remainingMatches * 3)
-		
-		var probability float64
-		if i == 0 {
-			// Top team gets highest probability
-			probability = 40.0 + (rand.Float64() * 10.0) // 40-50%
-		} else if i == 1 {
-			// Second team
-			probability = 25.0 + (rand.Float64() * 10.0) // 25-35%
-		} else if i == 2 {
-			// Third team
-			probability = 10.0 + (rand.Float64() * 10.0) // 10-20%
-		} else {
-			// Last team gets remaining probability
-			probability = totalProbability - (predictions[0].PredictionPercentage + 
-											predictions[1].PredictionPercentage + 
-											predictions[2].PredictionPercentage)
-		}
-		
-		// Ensure we don't go below 0 or have rounding issues
-		if probability < 0 {
-			probability = 0.1
-		}
-		
-		prediction := models.Prediction{
-			TeamID:             stats.Team.ID,
-			Team:               stats.Team,
-			PredictedPosition:  i + 1, // Current position
-			PredictedPoints:    maxPossiblePoints,
-			PredictionPercentage: probability,
-		}
-		
-		predictions = append(predictions, prediction)
-		
-		// Insert prediction
-		_, err := tx.Exec(
-			"INSERT INTO predictions (team_id, predicted_position, predicted_points, prediction_percentage) VALUES ($1, $2, $3, $4)",
-			prediction.TeamID, prediction.PredictedPosition, prediction.PredictedPoints, prediction.PredictionPercentage,
-		)
-		if err != nil {
-			tx.Rollback()
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to insert prediction: " + err.Error(),
-			})
-		}
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+	if season.Status != models.SeasonActive {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Season " + season.Name + " is archived and its predictions cannot change",
+		})
+	}
+	if status, msg := checkLeagueSeason(database.DB, season); msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
 	}
 	
-	err = tx.Commit()
+	predictions, err := generatePredictions(season.ID, seed)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to commit transaction: " + err.Error(),
+			"error": "Failed to generate predictions: " + err.Error(),
 		})
 	}
 	
 	// Return the newly generated predictions
 	return c.JSON(fiber.Map{
 		"message": "Championship probabilities generated successfully",
+		"seed": seed,
 		"predictions": predictions,
 	})
-} 
\ No newline at end of file
+}
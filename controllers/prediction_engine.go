@@ -0,0 +1,354 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/database"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// seasonState is everything the prediction engine needs to know about a season:
+// the teams, the results played so far and the fixtures still to come
+type seasonState struct {
+	Teams     []models.Team
+	Strengths map[int]teamStrength
+	Results   []matchResult
+	Remaining []fixture
+
+	// Absences are the injuries and suspensions known so far, which weaken a
+	// team in the remaining fixtures of the weeks they cover
+	Absences []models.Absence
+	// Form holds every team's current form and fatigue, which are applied to
+	// its next fixture only; nil leaves them out
+	Form *formTracker
+
+	TieBreakers []string
+	// Engine is the match engine of the season's competition
+	Engine MatchEngine
+}
+
+// simulationSummary holds the outcome of a Monte Carlo run
+type simulationSummary struct {
+	Simulations int
+	TitleCounts map[int]int
+	PointsTotal map[int]int
+	PositionSum map[int]int
+
+	// PositionCounts[teamID][p] is how often the team finished in position p+1
+	PositionCounts map[int][]int
+	// PointsSamples holds the final points total of every simulated season
+	PointsSamples map[int][]int
+}
+
+// pointsIntervalLow and pointsIntervalHigh are the percentiles of the
+// simulated points totals reported as the confidence interval
+const (
+	pointsIntervalLow  = 0.05
+	pointsIntervalHigh = 0.95
+)
+
+// loadSeasonState reads a season's teams, ratings and matches from the database
+func loadSeasonState(seasonID int) (*seasonState, error) {
+	state := &seasonState{}
+
+	season, err := loadSeason(database.DB, seasonID)
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := loadSeasonTeams(database.DB, season.ID)
+	if err != nil {
+		return nil, err
+	}
+	state.Teams = teams
+
+	state.Strengths, err = loadTeamStrengths()
+	if err != nil {
+		return nil, err
+	}
+
+	competition, err := loadCompetition(database.DB, season.CompetitionID)
+	if err != nil {
+		return nil, err
+	}
+	state.TieBreakers = competition.TieBreakers
+	state.Engine, err = competitionEngine(competition, config.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	state.Results, err = loadAllResults(database.DB, season.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	state.Remaining, err = loadUnplayedFixtures(season.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	state.Absences, err = loadAbsences(database.DB, season.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load absences: %v", err)
+	}
+	state.Form, err = loadFormTracker(database.DB, season.ID, config.GetConfig())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load form: %v", err)
+	}
+
+	return state, nil
+}
+
+// runMonteCarlo plays the remaining fixtures of a season many times and counts
+// how often each team finishes in each position. Every simulated table is
+// ranked with the competition's tie-break chain.
+func runMonteCarlo(state *seasonState, simulations int, rng *rand.Rand) *simulationSummary {
+	summary := &simulationSummary{
+		Simulations: simulations,
+		TitleCounts: make(map[int]int),
+		PointsTotal: make(map[int]int),
+		PositionSum: make(map[int]int),
+
+		PositionCounts: make(map[int][]int),
+		PointsSamples:  make(map[int][]int),
+	}
+
+	base := computeStandings(state.Teams, state.Results)
+	teamCount := len(base)
+	if teamCount == 0 {
+		return summary
+	}
+
+	index := make(map[int]int, teamCount)
+	for i, stats := range base {
+		index[stats.Team.ID] = i
+		summary.PositionCounts[stats.Team.ID] = make([]int, teamCount)
+		summary.PointsSamples[stats.Team.ID] = make([]int, 0, simulations)
+	}
+
+	strengths := state.matchStrengths()
+	standings := make([]models.TeamStats, teamCount)
+	results := make([]matchResult, len(state.Results), len(state.Results)+len(state.Remaining))
+	copy(results, state.Results)
+
+	for sim := 0; sim < simulations; sim++ {
+		copy(standings, base)
+		results = results[:len(state.Results)]
+
+		for i, match := range state.Remaining {
+			home, okHome := index[match.HomeTeamID]
+			away, okAway := index[match.AwayTeamID]
+			if !okHome || !okAway {
+				continue
+			}
+
+			homeScore, awayScore := state.Engine.Score(rng, strengths[i][0], strengths[i][1])
+
+			addResult(&standings[home], homeScore, awayScore)
+			addResult(&standings[away], awayScore, homeScore)
+			results = append(results, matchResult{
+				HomeTeamID: match.HomeTeamID,
+				AwayTeamID: match.AwayTeamID,
+				HomeScore:  homeScore,
+				AwayScore:  awayScore,
+				Week:       match.Week,
+			})
+		}
+
+		// Rank with the competition's tie-break chain; lots are drawn afresh every season
+		rankStandingsWith(standings, results, state.TieBreakers, rng, nil)
+
+		for position, stats := range standings {
+			teamID := stats.Team.ID
+			if position == 0 {
+				summary.TitleCounts[teamID]++
+			}
+			summary.PointsTotal[teamID] += stats.Points
+			summary.PositionSum[teamID] += position + 1
+			summary.PositionCounts[teamID][position]++
+			summary.PointsSamples[teamID] = append(summary.PointsSamples[teamID], stats.Points)
+		}
+	}
+
+	return summary
+}
+
+// matchStrengths returns the strengths the home and away teams of every
+// remaining fixture are simulated with: their ratings less the absences known
+// for the fixture's week and, in each team's next fixture, adjusted for its
+// current form and fatigue. Later fixtures are played in neutral form and
+// rested, as the state the teams will be in then is not known.
+func (state *seasonState) matchStrengths() [][2]teamStrength {
+	next := make(map[int]int)
+	for _, match := range state.Remaining {
+		for _, teamID := range []int{match.HomeTeamID, match.AwayTeamID} {
+			if week, ok := next[teamID]; !ok || match.Week < week {
+				next[teamID] = match.Week
+			}
+		}
+	}
+
+	availability := &availabilityTracker{absences: state.Absences}
+	strength := func(teamID, week int) teamStrength {
+		s := availability.strength(strengthOf(state.Strengths, teamID), teamID, week)
+		if state.Form != nil && week == next[teamID] {
+			s = state.Form.strength(s, teamID)
+		}
+		return s
+	}
+
+	strengths := make([][2]teamStrength, len(state.Remaining))
+	for i, match := range state.Remaining {
+		strengths[i] = [2]teamStrength{
+			strength(match.HomeTeamID, match.Week),
+			strength(match.AwayTeamID, match.Week),
+		}
+	}
+	return strengths
+}
+
+// buildPredictions turns a Monte Carlo summary into one prediction per team,
+// ordered by expected finishing position, including the probability of
+// finishing in every position and a confidence interval for the points total
+func buildPredictions(state *seasonState, summary *simulationSummary) []models.Prediction {
+	predictions := make([]models.Prediction, 0, len(state.Teams))
+	averagePosition := make(map[int]float64, len(state.Teams))
+
+	for _, team := range state.Teams {
+		var probability, points float64
+		if summary.Simulations > 0 {
+			probability = 100 * float64(summary.TitleCounts[team.ID]) / float64(summary.Simulations)
+			points = float64(summary.PointsTotal[team.ID]) / float64(summary.Simulations)
+			averagePosition[team.ID] = float64(summary.PositionSum[team.ID]) / float64(summary.Simulations)
+		}
+
+		lower, upper := pointsInterval(summary.PointsSamples[team.ID])
+		positions := make([]models.PositionProbability, len(state.Teams))
+		for p := range positions {
+			positions[p].Position = p + 1
+			if summary.Simulations > 0 && p < len(summary.PositionCounts[team.ID]) {
+				share := 100 * float64(summary.PositionCounts[team.ID][p]) / float64(summary.Simulations)
+				positions[p].Probability = math.Round(share*100) / 100
+			}
+		}
+
+		predictions = append(predictions, models.Prediction{
+			TeamID:               team.ID,
+			Team:                 team,
+			PredictedPoints:      int(math.Round(points)),
+			PredictionPercentage: math.Round(probability*100) / 100,
+			ExpectedPoints:       math.Round(points*100) / 100,
+			PointsLower:          lower,
+			PointsUpper:          upper,
+			Positions:            positions,
+		})
+	}
+
+	sort.SliceStable(predictions, func(i, j int) bool {
+		return averagePosition[predictions[i].TeamID] < averagePosition[predictions[j].TeamID]
+	})
+	for i := range predictions {
+		predictions[i].PredictedPosition = i + 1
+	}
+
+	return predictions
+}
+
+// pointsInterval returns the 5th and 95th percentiles of the simulated points totals
+func pointsInterval(samples []int) (int, int) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+
+	sorted := make([]int, len(samples))
+	copy(sorted, samples)
+	sort.Ints(sorted)
+
+	low := sorted[int(pointsIntervalLow*float64(len(sorted)-1))]
+	high := sorted[int(math.Ceil(pointsIntervalHigh*float64(len(sorted)-1)))]
+	return low, high
+}
+
+// predictionModelVersion names the prediction model stored with every run, so
+// forecasts made by different models can be told apart in the history. Bump it
+// when the way predictions are made changes.
+const predictionModelVersion = "monte-carlo/2"
+
+// modelVersion is the version of the prediction model together with the
+// match engine it plays the remaining matches with
+func modelVersion(engine MatchEngine) string {
+	return predictionModelVersion + "+" + engine.Name()
+}
+
+// generatePredictions runs the Monte Carlo engine on a season and stores the
+// result as a new prediction run made after the last week played, keeping
+// the earlier runs as history. The same seed and season state always give the
+// same predictions.
+func generatePredictions(seasonID int, seed int64) ([]models.Prediction, error) {
+	cfg := config.GetConfig()
+
+	state, err := loadSeasonState(seasonID)
+	if err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	summary := runMonteCarlo(state, cfg.PredictionSimulations, rng)
+	predictions := buildPredictions(state, summary)
+	for i := range predictions {
+		predictions[i].SeasonID = seasonID
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var runID int
+	err = tx.QueryRow(`
+		INSERT INTO prediction_runs (season_id, week, model_version, simulations, seed)
+		VALUES ($1, (SELECT COALESCE(MAX(week), 0) FROM matches WHERE season_id = $1 AND played = true), $2, $3, $4)
+		RETURNING id
+	`, seasonID, modelVersion(state.Engine), cfg.PredictionSimulations, seed).Scan(&runID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range predictions {
+		prediction := &predictions[i]
+		prediction.RunID = runID
+		err := tx.QueryRow(`
+			INSERT INTO predictions (season_id, run_id, team_id, predicted_position, predicted_points, prediction_percentage,
+			                         expected_points, points_lower, points_upper)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id, created_at
+		`,
+			seasonID, runID, prediction.TeamID, prediction.PredictedPosition, prediction.PredictedPoints, prediction.PredictionPercentage,
+			prediction.ExpectedPoints, prediction.PointsLower, prediction.PointsUpper,
+		).Scan(&prediction.ID, &prediction.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, position := range prediction.Positions {
+			_, err := tx.Exec(
+				"INSERT INTO prediction_positions (prediction_id, position, probability) VALUES ($1, $2, $3)",
+				prediction.ID, position.Position, position.Probability,
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return predictions, nil
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"math"
+	"testing"
+
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// An absence weakens a team only in the weeks it covers, and the team's form
+// only in its next fixture
+func TestMatchStrengths(t *testing.T) {
+	state := &seasonState{
+		Strengths: map[int]teamStrength{1: defaultStrength, 2: defaultStrength},
+		Remaining: []fixture{
+			{ID: 1, HomeTeamID: 1, AwayTeamID: 2, Week: 5},
+			{ID: 2, HomeTeamID: 2, AwayTeamID: 1, Week: 6},
+			{ID: 3, HomeTeamID: 1, AwayTeamID: 2, Week: 7},
+		},
+		Absences: []models.Absence{
+			{TeamID: 1, FromWeek: 5, UntilWeek: 6, StrengthLoss: 0.2},
+		},
+		Form: &formTracker{
+			cfg:        &config.Config{FormMatches: 5, FormEffect: 0.1},
+			recent:     map[int][]string{2: {formWin, formWin}},
+			congestion: map[int]int{},
+		},
+	}
+
+	strengths := state.matchStrengths()
+	want := [][2]float64{
+		{0.8, 1.1},
+		{1, 0.8},
+		{1, 1},
+	}
+	for i, match := range state.Remaining {
+		for side := range want[i] {
+			if got := strengths[i][side].Attack; math.Abs(got-want[i][side]) > 1e-9 {
+				t.Fatalf("week %d, side %d: got attack %g, want %g", match.Week, side, got, want[i][side])
+			}
+		}
+	}
+
+	// Without absences or form the ratings are used as they are
+	state.Absences, state.Form = nil, nil
+	for i, pair := range state.matchStrengths() {
+		if pair[0] != defaultStrength || pair[1] != defaultStrength {
+			t.Fatalf("fixture %d: got %+v, want the base ratings", i, pair)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"sort"
+
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// loadPredictionHistory returns every prediction run of a season and each
+// team's title probability week by week. When a week was predicted more than
+// once, after a result was corrected or predictions were regenerated, the
+// last run made after it counts.
+func loadPredictionHistory(q querier, seasonID int) (models.PredictionHistory, error) {
+	history := models.PredictionHistory{
+		SeasonID: seasonID,
+		Runs:     []models.PredictionRun{},
+		Teams:    []models.TeamProbabilityHistory{},
+	}
+
+	rows, err := q.Query(`
+		SELECT id, season_id, week, model_version, simulations, seed, created_at
+		FROM prediction_runs
+		WHERE season_id = $1
+		ORDER BY id
+	`, seasonID)
+	if err != nil {
+		return history, err
+	}
+	for rows.Next() {
+		var run models.PredictionRun
+		err := rows.Scan(&run.ID, &run.SeasonID, &run.Week, &run.ModelVersion, &run.Simulations, &run.Seed, &run.CreatedAt)
+		if err != nil {
+			rows.Close()
+			return history, err
+		}
+		history.Runs = append(history.Runs, run)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return history, err
+	}
+
+	// The last run of each week, in week order
+	latest := make(map[int]models.PredictionRun)
+	var weeks []int
+	for _, run := range history.Runs {
+		if _, ok := latest[run.Week]; !ok {
+			weeks = append(weeks, run.Week)
+		}
+		latest[run.Week] = run
+	}
+	counted := make(map[int]bool, len(latest))
+	for _, run := range latest {
+		counted[run.ID] = true
+	}
+	sort.Ints(weeks)
+
+	probabilityRows, err := q.Query(`
+		SELECT p.run_id, p.prediction_percentage, t.id, t.name
+		FROM predictions p
+		JOIN teams t ON p.team_id = t.id
+		WHERE p.season_id = $1 AND p.run_id IS NOT NULL
+		ORDER BY t.name
+	`, seasonID)
+	if err != nil {
+		return history, err
+	}
+	defer probabilityRows.Close()
+
+	// probabilities[teamID][runID] is the team's title probability in a run
+	probabilities := make(map[int]map[int]float64)
+	index := make(map[int]int)
+	for probabilityRows.Next() {
+		var runID int
+		var probability float64
+		var team models.Team
+		if err := probabilityRows.Scan(&runID, &probability, &team.ID, &team.Name); err != nil {
+			return history, err
+		}
+		if !counted[runID] {
+			continue
+		}
+		if _, ok := index[team.ID]; !ok {
+			index[team.ID] = len(history.Teams)
+			history.Teams = append(history.Teams, models.TeamProbabilityHistory{Team: team})
+			probabilities[team.ID] = make(map[int]float64)
+		}
+		probabilities[team.ID][runID] = probability
+	}
+	if err := probabilityRows.Err(); err != nil {
+		return history, err
+	}
+
+	for i := range history.Teams {
+		team := &history.Teams[i]
+		team.Timeline = []models.WeekProbability{}
+		for _, week := range weeks {
+			run := latest[week]
+			probability, ok := probabilities[team.Team.ID][run.ID]
+			if !ok {
+				continue
+			}
+			team.Timeline = append(team.Timeline, models.WeekProbability{
+				Week:        week,
+				RunID:       run.ID,
+				Probability: probability,
+			})
+		}
+	}
+
+	return history, nil
+}
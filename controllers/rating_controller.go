@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/database"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// GetTeamRatings handles the request to view a team's Elo rating curve: its
+// current rating and its rating after every match it played, oldest first
+func GetTeamRatings(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+
+	team, err := getTeam(database.DB, id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Team not found",
+		})
+	}
+
+	history, err := loadRatingCurve(database.DB, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get rating history: " + err.Error(),
+		})
+	}
+
+	return c.JSON(models.TeamRatingCurve{
+		Team:    team,
+		Rating:  team.EloRating,
+		History: history,
+	})
+}
+
+// GetPowerRankings handles the request to rank the teams of the current
+// season, or of the season given in the route, by Elo rating. Each team's
+// league table position is listed next to its power rank, so teams whose
+// points flatter them, or do not do them justice, stand out.
+func GetPowerRankings(c *fiber.Ctx) error {
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+	if status, msg := checkLeagueSeason(database.DB, season); msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	table, err := rankedSeasonTable(database.DB, season)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get league table: " + err.Error(),
+		})
+	}
+
+	ratings, err := loadSeasonRatings(database.DB, season)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get Elo ratings: " + err.Error(),
+		})
+	}
+
+	return c.JSON(powerRankings(table, ratings))
+}
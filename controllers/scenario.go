@@ -0,0 +1,291 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strconv"
+
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/database"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// scenarioMatch is a match of a season as the scenario code sees it: its
+// result when played, or its fixture when not
+type scenarioMatch struct {
+	ID       int
+	Played   bool
+	HomeTeam models.Team
+	AwayTeam models.Team
+	Result   matchResult
+}
+
+// scenarioResultRequest fixes the result of one match, named by its ID or by
+// its week and teams
+type scenarioResultRequest struct {
+	MatchID    int  `json:"match_id"`
+	Week       int  `json:"week"`
+	HomeTeamID int  `json:"home_team_id"`
+	AwayTeamID int  `json:"away_team_id"`
+	HomeScore  *int `json:"home_score"`
+	AwayScore  *int `json:"away_score"`
+}
+
+// loadScenarioMatches returns every match of a season in the order it is
+// played, with its cards for the fair play tie-breaker
+func loadScenarioMatches(q querier, seasonID int) ([]scenarioMatch, error) {
+	rows, err := q.Query(`
+		SELECT m.id, m.played, m.week, COALESCE(m.home_score, 0), COALESCE(m.away_score, 0),
+		       m.home_yellow_cards, m.home_red_cards, m.away_yellow_cards, m.away_red_cards,
+		       ht.id, ht.name, at.id, at.name
+		FROM matches m
+		JOIN teams ht ON m.home_team_id = ht.id
+		JOIN teams at ON m.away_team_id = at.id
+		WHERE m.season_id = $1
+		ORDER BY m.week, m.id
+	`, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []scenarioMatch
+	for rows.Next() {
+		var match scenarioMatch
+		result := &match.Result
+		err := rows.Scan(
+			&match.ID, &match.Played, &result.Week, &result.HomeScore, &result.AwayScore,
+			&result.HomeYellowCards, &result.HomeRedCards, &result.AwayYellowCards, &result.AwayRedCards,
+			&match.HomeTeam.ID, &match.HomeTeam.Name, &match.AwayTeam.ID, &match.AwayTeam.Name,
+		)
+		if err != nil {
+			return nil, err
+		}
+		result.HomeTeamID = match.HomeTeam.ID
+		result.AwayTeamID = match.AwayTeam.ID
+		matches = append(matches, match)
+	}
+
+	return matches, rows.Err()
+}
+
+// resolveScenarioResults finds the match each requested result is for. It
+// returns a message saying what is wrong with the request, or an empty string.
+func resolveScenarioResults(matches []scenarioMatch, requests []scenarioResultRequest) ([]models.ScenarioResult, string) {
+	if len(requests) == 0 {
+		return nil, "A scenario needs at least one result"
+	}
+
+	var results []models.ScenarioResult
+	seen := make(map[int]bool)
+	for i, req := range requests {
+		entry := "Result " + strconv.Itoa(i+1)
+		if req.HomeScore == nil || req.AwayScore == nil {
+			return nil, entry + " needs both home_score and away_score"
+		}
+		if *req.HomeScore < 0 || *req.AwayScore < 0 || *req.HomeScore > 99 || *req.AwayScore > 99 {
+			return nil, entry + ": scores must be between 0 and 99"
+		}
+
+		var found *scenarioMatch
+		for j := range matches {
+			match := &matches[j]
+			if req.MatchID != 0 {
+				if match.ID == req.MatchID {
+					found = match
+					break
+				}
+				continue
+			}
+			if match.Result.Week == req.Week && match.HomeTeam.ID == req.HomeTeamID && match.AwayTeam.ID == req.AwayTeamID {
+				found = match
+				break
+			}
+		}
+		if found == nil {
+			if req.MatchID != 0 {
+				return nil, entry + ": match " + strconv.Itoa(req.MatchID) + " is not in the season"
+			}
+			return nil, entry + ": no match in week " + strconv.Itoa(req.Week) + " between home team " +
+				strconv.Itoa(req.HomeTeamID) + " and away team " + strconv.Itoa(req.AwayTeamID)
+		}
+		if seen[found.ID] {
+			return nil, entry + ": match " + strconv.Itoa(found.ID) + " is given more than once"
+		}
+		seen[found.ID] = true
+
+		results = append(results, models.ScenarioResult{
+			MatchID:   found.ID,
+			Week:      found.Result.Week,
+			HomeTeam:  found.HomeTeam,
+			AwayTeam:  found.AwayTeam,
+			HomeScore: *req.HomeScore,
+			AwayScore: *req.AwayScore,
+			Played:    found.Played,
+		})
+	}
+
+	return results, ""
+}
+
+// projectScenario lays a scenario's results over its season as it stands now,
+// in memory only, and works out the table and predictions that follow. The
+// hypothetical results replace real ones or take matches out of the ones
+// left to simulate; cards of replaced results are kept. The same scenario,
+// seed and season state always give the same projection.
+func projectScenario(season models.Season, scenario models.Scenario) (models.ScenarioProjection, error) {
+	cfg := config.GetConfig()
+	projection := models.ScenarioProjection{Scenario: scenario}
+
+	state, err := loadSeasonState(season.ID)
+	if err != nil {
+		return projection, fmt.Errorf("failed to load season: %v", err)
+	}
+	competition, err := loadCompetition(database.DB, season.CompetitionID)
+	if err != nil {
+		return projection, fmt.Errorf("failed to get competition: %v", err)
+	}
+	matches, err := loadScenarioMatches(database.DB, season.ID)
+	if err != nil {
+		return projection, fmt.Errorf("failed to get matches: %v", err)
+	}
+
+	fixed := make(map[int]models.ScenarioResult, len(scenario.Results))
+	for i, result := range scenario.Results {
+		fixed[result.MatchID] = result
+		for _, match := range matches {
+			if match.ID == result.MatchID {
+				scenario.Results[i].Played = match.Played
+			}
+		}
+	}
+
+	state.Results, state.Remaining = nil, nil
+	for _, match := range matches {
+		result := match.Result
+		if hypothetical, ok := fixed[match.ID]; ok {
+			result.HomeScore, result.AwayScore = hypothetical.HomeScore, hypothetical.AwayScore
+			state.Results = append(state.Results, result)
+			continue
+		}
+		if match.Played {
+			state.Results = append(state.Results, result)
+			continue
+		}
+		state.Remaining = append(state.Remaining, fixture{
+			ID:         match.ID,
+			HomeTeamID: match.HomeTeam.ID,
+			AwayTeamID: match.AwayTeam.ID,
+			Week:       result.Week,
+		})
+	}
+
+	table := computeStandings(state.Teams, state.Results)
+	rankStandings(table, state.Results, state.TieBreakers, competition.LotsSeed)
+	outlooks := solveOutlooks(table, state.Remaining, 0)
+	for i := range table {
+		table[i].Outlook = outlooks[table[i].Team.ID]
+	}
+
+	rng := rand.New(rand.NewSource(scenario.Seed))
+	summary := runMonteCarlo(state, cfg.PredictionSimulations, rng)
+	predictions := buildPredictions(state, summary)
+	for i := range predictions {
+		predictions[i].SeasonID = season.ID
+		predictions[i].Outlook = outlooks[predictions[i].TeamID]
+	}
+
+	projection.Scenario = scenario
+	projection.Table = table
+	projection.Predictions = predictions
+	return projection, nil
+}
+
+// storeScenario saves a scenario and its results, filling in its ID and
+// creation time
+func storeScenario(tx *sql.Tx, scenario *models.Scenario) error {
+	err := tx.QueryRow(
+		"INSERT INTO scenarios (season_id, name, seed) VALUES ($1, $2, $3) RETURNING id, created_at",
+		scenario.SeasonID, scenario.Name, scenario.Seed,
+	).Scan(&scenario.ID, &scenario.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store scenario: %v", err)
+	}
+
+	for _, result := range scenario.Results {
+		_, err := tx.Exec(
+			"INSERT INTO scenario_results (scenario_id, match_id, home_score, away_score) VALUES ($1, $2, $3, $4)",
+			scenario.ID, result.MatchID, result.HomeScore, result.AwayScore,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store result of match %d: %v", result.MatchID, err)
+		}
+	}
+
+	return nil
+}
+
+// loadScenarios returns the scenarios of a season, or the one with the given
+// ID when id is not 0, oldest first, with their results in match order
+func loadScenarios(q querier, seasonID, id int) ([]models.Scenario, error) {
+	rows, err := q.Query(`
+		SELECT id, season_id, name, seed, created_at
+		FROM scenarios
+		WHERE ($1 = 0 OR season_id = $1) AND ($2 = 0 OR id = $2)
+		ORDER BY id
+	`, seasonID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	scenarios := []models.Scenario{}
+	index := make(map[int]int)
+	for rows.Next() {
+		var scenario models.Scenario
+		if err := rows.Scan(&scenario.ID, &scenario.SeasonID, &scenario.Name, &scenario.Seed, &scenario.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		scenario.Results = []models.ScenarioResult{}
+		index[scenario.ID] = len(scenarios)
+		scenarios = append(scenarios, scenario)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	resultRows, err := q.Query(`
+		SELECT r.scenario_id, m.id, m.week, m.played, r.home_score, r.away_score,
+		       ht.id, ht.name, at.id, at.name
+		FROM scenario_results r
+		JOIN scenarios s ON r.scenario_id = s.id
+		JOIN matches m ON r.match_id = m.id
+		JOIN teams ht ON m.home_team_id = ht.id
+		JOIN teams at ON m.away_team_id = at.id
+		WHERE ($1 = 0 OR s.season_id = $1) AND ($2 = 0 OR s.id = $2)
+		ORDER BY m.week, m.id
+	`, seasonID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer resultRows.Close()
+
+	for resultRows.Next() {
+		var scenarioID int
+		var result models.ScenarioResult
+		err := resultRows.Scan(
+			&scenarioID, &result.MatchID, &result.Week, &result.Played, &result.HomeScore, &result.AwayScore,
+			&result.HomeTeam.ID, &result.HomeTeam.Name, &result.AwayTeam.ID, &result.AwayTeam.Name,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[scenarioID]; ok {
+			scenarios[i].Results = append(scenarios[i].Results, result)
+		}
+	}
+
+	return scenarios, resultRows.Err()
+}
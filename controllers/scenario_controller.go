@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/database"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// maxScenarioName is the longest name a scenario can be given
+const maxScenarioName = 100
+
+// CreateScenario handles the request to save a what-if scenario on the
+// current season, or on the season given in the route, and project it. The
+// body names the hypothetical results; nothing real is changed. The
+// predictions are simulated with ?seed= when given, and the seed is kept so
+// the scenario can be projected again the same way.
+func CreateScenario(c *fiber.Ctx) error {
+	var req struct {
+		Name    string                  `json:"name"`
+		Results []scenarioResultRequest `json:"results"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to parse request body: " + err.Error(),
+		})
+	}
+	if len(req.Name) > maxScenarioName {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Scenario name must be at most " + strconv.Itoa(maxScenarioName) + " characters",
+		})
+	}
+
+	seed, err := simulationSeed(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid seed, it must be an integer",
+		})
+	}
+
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+	if status, msg := checkLeagueSeason(database.DB, season); status != 0 {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	matches, err := loadScenarioMatches(database.DB, season.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get matches: " + err.Error(),
+		})
+	}
+	results, msg := resolveScenarioResults(matches, req.Results)
+	if msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction: " + err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	scenario := models.Scenario{
+		SeasonID: season.ID,
+		Name:     req.Name,
+		Seed:     seed,
+		Results:  results,
+	}
+	if scenario.Name == "" {
+		var count int
+		err := tx.QueryRow("SELECT COUNT(*) FROM scenarios WHERE season_id = $1", season.ID).Scan(&count)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to count scenarios: " + err.Error(),
+			})
+		}
+		scenario.Name = "Scenario " + strconv.Itoa(count+1)
+	}
+
+	if err := storeScenario(tx, &scenario); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to save scenario: " + err.Error(),
+		})
+	}
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction: " + err.Error(),
+		})
+	}
+
+	projection, err := projectScenario(season, scenario)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to project scenario: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(projection)
+}
+
+// GetScenarios handles the request to list the saved scenarios of the current
+// season, or of the season given in the route
+func GetScenarios(c *fiber.Ctx) error {
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+
+	scenarios, err := loadScenarios(database.DB, season.ID, 0)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get scenarios: " + err.Error(),
+		})
+	}
+
+	return c.JSON(scenarios)
+}
+
+// GetScenario handles the request to get a saved scenario projected on its
+// season as it stands now. Results played since it was saved count as they
+// really ended, except for the ones the scenario fixes itself.
+func GetScenario(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid scenario ID",
+		})
+	}
+
+	scenarios, err := loadScenarios(database.DB, 0, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get scenario: " + err.Error(),
+		})
+	}
+	if len(scenarios) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Scenario not found",
+		})
+	}
+	scenario := scenarios[0]
+
+	season, err := loadSeason(database.DB, scenario.SeasonID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get season: " + err.Error(),
+		})
+	}
+
+	projection, err := projectScenario(season, scenario)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to project scenario: " + err.Error(),
+		})
+	}
+
+	return c.JSON(projection)
+}
+
+// DeleteScenario handles the request to delete a saved scenario
+func DeleteScenario(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid scenario ID",
+		})
+	}
+
+	result, err := database.DB.Exec("DELETE FROM scenarios WHERE id = $1", id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete scenario: " + err.Error(),
+		})
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Scenario not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Scenario deleted successfully",
+	})
+}
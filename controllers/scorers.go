@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strconv"
+
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// assistChance is the share of goals that have an assist
+const assistChance = 0.75
+
+// How much more often a player in each position scores or assists than the
+// others, before the player's rating is applied
+var (
+	scoringWeights = map[string]float64{
+		models.PositionGoalkeeper: 0.02,
+		models.PositionDefender:   0.4,
+		models.PositionMidfielder: 1.0,
+		models.PositionForward:    2.2,
+	}
+	assistWeights = map[string]float64{
+		models.PositionGoalkeeper: 0.1,
+		models.PositionDefender:   0.7,
+		models.PositionMidfielder: 1.6,
+		models.PositionForward:    1.2,
+	}
+)
+
+// goalCredit is a goal credited to a scorer and, when there was one, the
+// player who assisted it
+type goalCredit struct {
+	TeamID   int
+	ScorerID int
+	AssistID *int
+}
+
+// creditGoals picks a scorer for each goal a team scored, weighted by position
+// and rating, and for most goals a different player who assisted it. A team
+// without a squad gets no credits.
+func creditGoals(rng *rand.Rand, teamID int, squad []models.Player, goals int) []goalCredit {
+	if len(squad) == 0 {
+		return nil
+	}
+
+	credits := make([]goalCredit, 0, goals)
+	for i := 0; i < goals; i++ {
+		scorer, _ := pickPlayer(rng, squad, scoringWeights, 0)
+		credit := goalCredit{TeamID: teamID, ScorerID: scorer.ID}
+
+		if rng.Float64() < assistChance {
+			if assister, ok := pickPlayer(rng, squad, assistWeights, scorer.ID); ok {
+				credit.AssistID = &assister.ID
+			}
+		}
+		credits = append(credits, credit)
+	}
+
+	return credits
+}
+
+// pickPlayer draws a player from the squad with probability proportional to
+// the weight of their position times their rating, skipping the player with
+// ID exclude. It reports false when nobody can be picked.
+func pickPlayer(rng *rand.Rand, squad []models.Player, weights map[string]float64, exclude int) (models.Player, bool) {
+	total := 0.0
+	for _, player := range squad {
+		if player.ID != exclude {
+			total += weights[player.Position] * player.Rating
+		}
+	}
+	if total <= 0 {
+		return models.Player{}, false
+	}
+
+	target := rng.Float64() * total
+	var last models.Player
+	for _, player := range squad {
+		if player.ID == exclude {
+			continue
+		}
+		target -= weights[player.Position] * player.Rating
+		last = player
+		if target < 0 {
+			return player, true
+		}
+	}
+
+	// Rounding can leave a sliver of the total unassigned
+	return last, true
+}
+
+// loadSquads returns the players of every team keyed by team ID, in shirt number order
+func loadSquads(q querier) (map[int][]models.Player, error) {
+	rows, err := q.Query("SELECT id, team_id, name, position, rating, shirt_number FROM players ORDER BY team_id, shirt_number")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	squads := make(map[int][]models.Player)
+	for rows.Next() {
+		var player models.Player
+		err := rows.Scan(&player.ID, &player.TeamID, &player.Name, &player.Position, &player.Rating, &player.ShirtNumber)
+		if err != nil {
+			return nil, err
+		}
+		squads[player.TeamID] = append(squads[player.TeamID], player)
+	}
+
+	return squads, rows.Err()
+}
+
+// storeGoals replaces the credited goals of a match
+func storeGoals(tx *sql.Tx, matchID int, credits []goalCredit) error {
+	if _, err := tx.Exec("DELETE FROM match_goals WHERE match_id = $1", matchID); err != nil {
+		return fmt.Errorf("failed to clear goals of match %d: %v", matchID, err)
+	}
+
+	for _, credit := range credits {
+		_, err := tx.Exec(
+			"INSERT INTO match_goals (match_id, team_id, scorer_id, assist_id) VALUES ($1, $2, $3, $4)",
+			matchID, credit.TeamID, credit.ScorerID, credit.AssistID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store goal of match %d: %v", matchID, err)
+		}
+	}
+
+	return nil
+}
+
+// Statistics the player rankings can be ordered by
+const (
+	statGoals   = "goals"
+	statAssists = "assists"
+)
+
+// loadTopPlayers ranks the players by their goals or assists in a season's
+// played matches, breaking ties with the other statistic and then by name.
+// Players level on both share a rank.
+func loadTopPlayers(q querier, seasonID int, stat string, limit int) ([]models.PlayerTotal, error) {
+	column, order := "scorer_id", "goals DESC, assists DESC"
+	if stat == statAssists {
+		column, order = "assist_id", "assists DESC, goals DESC"
+	}
+
+	rows, err := q.Query(`
+		SELECT p.id, p.team_id, p.name, p.position, p.rating, p.shirt_number, t.id, t.name,
+		       COUNT(*) FILTER (WHERE mg.scorer_id = p.id) AS goals,
+		       COUNT(*) FILTER (WHERE mg.assist_id = p.id) AS assists
+		FROM match_goals mg
+		JOIN matches m ON mg.match_id = m.id
+		JOIN players p ON p.id = mg.scorer_id OR p.id = mg.assist_id
+		JOIN teams t ON p.team_id = t.id
+		WHERE m.season_id = $1 AND m.played = true
+		GROUP BY p.id, t.id
+		HAVING COUNT(*) FILTER (WHERE mg.`+column+` = p.id) > 0
+		ORDER BY `+order+`, p.name
+		LIMIT $2
+	`, seasonID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []models.PlayerTotal
+	for rows.Next() {
+		var total models.PlayerTotal
+		err := rows.Scan(
+			&total.Player.ID, &total.Player.TeamID, &total.Player.Name, &total.Player.Position,
+			&total.Player.Rating, &total.Player.ShirtNumber, &total.Team.ID, &total.Team.Name,
+			&total.Goals, &total.Assists,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		total.Rank = len(totals) + 1
+		if n := len(totals); n > 0 && totals[n-1].Goals == total.Goals && totals[n-1].Assists == total.Assists {
+			total.Rank = totals[n-1].Rank
+		}
+		totals = append(totals, total)
+	}
+
+	return totals, rows.Err()
+}
+
+// validateGoalCredits checks goals entered by hand: every scorer and assister
+// must play for one of the two teams, the assister for the scorer's team, and
+// each team must be credited exactly the goals it scored. It fills in the team
+// of each credit and returns a message describing the first problem found.
+func validateGoalCredits(credits []goalCredit, squads map[int][]models.Player, homeTeamID, awayTeamID, homeScore, awayScore int) string {
+	teamOf := make(map[int]int)
+	for _, teamID := range []int{homeTeamID, awayTeamID} {
+		for _, player := range squads[teamID] {
+			teamOf[player.ID] = teamID
+		}
+	}
+
+	goals := make(map[int]int)
+	for i := range credits {
+		credit := &credits[i]
+		teamID, ok := teamOf[credit.ScorerID]
+		if !ok {
+			return "Scorer " + strconv.Itoa(credit.ScorerID) + " does not play for either team"
+		}
+		if credit.AssistID != nil {
+			if *credit.AssistID == credit.ScorerID || teamOf[*credit.AssistID] != teamID {
+				return "Assist " + strconv.Itoa(*credit.AssistID) + " must come from another player of the scorer's team"
+			}
+		}
+		credit.TeamID = teamID
+		goals[teamID]++
+	}
+
+	if goals[homeTeamID] != homeScore || goals[awayTeamID] != awayScore {
+		return "The goals list must credit every goal of the result to a player"
+	}
+	return ""
+}
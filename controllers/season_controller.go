@@ -0,0 +1,378 @@
+package controllers
+
+import (
+	"database/sql"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/database"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// GetAllSeasons handles the request to list every season, oldest first
+func GetAllSeasons(c *fiber.Ctx) error {
+	rows, err := database.DB.Query(
+		"SELECT id, competition_id, name, status, started_at, archived_at FROM seasons ORDER BY id",
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get seasons: " + err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var seasons []models.Season
+	for rows.Next() {
+		season, err := scanSeason(rows)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to scan season: " + err.Error(),
+			})
+		}
+		seasons = append(seasons, season)
+	}
+
+	return c.JSON(seasons)
+}
+
+// GetSeasonByID handles the request to get a single season
+func GetSeasonByID(c *fiber.Ctx) error {
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+
+	return c.JSON(season)
+}
+
+// StartNewSeason handles the request to archive the current season of every
+// division and cup and start the next one. Teams move between adjacent divisions
+// according to their final positions and the divisions' promotion, relegation
+// and playoff places, and the fixtures of the new seasons are generated
+// straight away. The archived seasons keep their matches, tables and
+// predictions but can no longer change. Starting a new season while matches
+// are still unplayed is refused unless the request is sent with ?force=true.
+func StartNewSeason(c *fiber.Ctx) error {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Failed to parse request body: " + err.Error(),
+			})
+		}
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if len(req.Name) > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Season name must be at most 100 characters",
+		})
+	}
+
+	// The seed decides the promotion playoffs
+	seed, err := simulationSeed(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid seed: " + err.Error(),
+		})
+	}
+
+	strengths, err := loadTeamStrengths()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load team ratings: " + err.Error(),
+		})
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction: " + err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	divisions, err := loadDivisions(tx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get divisions: " + err.Error(),
+		})
+	}
+	if len(divisions) == 0 {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "No division has an active season",
+		})
+	}
+
+	for _, d := range divisions {
+		var unplayed int
+		err := tx.QueryRow(
+			"SELECT COUNT(*) FROM matches WHERE season_id = $1 AND played = false", d.Season.ID,
+		).Scan(&unplayed)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to check season state: " + err.Error(),
+			})
+		}
+		if unplayed > 0 && !c.QueryBool("force") {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": d.Competition.Name + " still has " + strconv.Itoa(unplayed) + " unplayed matches. " +
+					"They stay unplayed once the season is archived; retry with ?force=true to continue",
+			})
+		}
+	}
+
+	if msg := checkPyramid(divisions); msg != "" {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	if req.Name == "" {
+		var count int
+		err := tx.QueryRow(
+			"SELECT COUNT(*) FROM seasons WHERE competition_id = $1", divisions[0].Competition.ID,
+		).Scan(&count)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to count seasons: " + err.Error(),
+			})
+		}
+		req.Name = "Season " + strconv.Itoa(count+1)
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	movements, playoffs, err := planMovements(divisions, strengths, rng, config.GetConfig())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to play promotion playoffs: " + err.Error(),
+		})
+	}
+
+	seasons, err := rollOverDivisions(tx, divisions, movements, req.Name)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start new season: " + err.Error(),
+		})
+	}
+	
+	// Cups start their next edition with the teams of the new divisions
+	cups, err := rollOverCups(tx, req.Name)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start new cup editions: " + err.Error(),
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction: " + err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":   req.Name + " started in " + strconv.Itoa(len(seasons)) + " divisions and the fixtures have been generated",
+		"seed":      seed,
+		"seasons":   append(seasons, cups...),
+		"movements": movements,
+		"playoffs":  playoffs,
+	})
+}
+
+// GetSeasonMovements handles the request to list the teams promoted or
+// relegated out of a season and into it
+func GetSeasonMovements(c *fiber.Ctx) error {
+	season, err := requestSeason(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Season not found",
+		})
+	}
+
+	movements, err := loadSeasonMovements(database.DB, season.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get movements: " + err.Error(),
+		})
+	}
+
+	return c.JSON(movements)
+}
+
+// GetTeamSeasons handles the request to compare a team across seasons. It
+// returns the team's table row and finishing position in every division season it took part in.
+func GetTeamSeasons(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+
+	if _, err := getTeam(database.DB, id); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Team not found",
+		})
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT s.id, s.competition_id, s.name, s.status, s.started_at, s.archived_at
+		FROM seasons s
+		JOIN league_table lt ON lt.season_id = s.id
+		JOIN competitions c ON s.competition_id = c.id
+		WHERE lt.team_id = $1 AND c.format = $2
+		ORDER BY s.id
+	`, id, models.FormatLeague)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get seasons: " + err.Error(),
+		})
+	}
+	defer rows.Close()
+
+	var seasons []models.Season
+	for rows.Next() {
+		season, err := scanSeason(rows)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to scan season: " + err.Error(),
+			})
+		}
+		seasons = append(seasons, season)
+	}
+	rows.Close()
+
+	records := make([]models.TeamSeasonRecord, 0, len(seasons))
+	for _, season := range seasons {
+		standings, err := rankedSeasonTable(database.DB, season)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to get league table for " + season.Name + ": " + err.Error(),
+			})
+		}
+
+		for _, stats := range standings {
+			if stats.Team.ID == id {
+				stats.TieBreak = nil
+				records = append(records, models.TeamSeasonRecord{Season: season, Stats: stats})
+			}
+		}
+	}
+
+	return c.JSON(records)
+}
+
+// rankedSeasonTable computes a season's league table from its matches and
+// ranks it with the tie-break chain of the season's competition
+func rankedSeasonTable(q querier, season models.Season) ([]models.TeamStats, error) {
+	competition, err := loadCompetition(q, season.CompetitionID)
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := loadSeasonTeams(q, season.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := loadAllResults(q, season.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	standings := computeStandings(teams, results)
+	rankStandings(standings, results, competition.TieBreakers, competition.LotsSeed)
+	return standings, nil
+}
+
+// scanSeason reads a season row selected as id, competition_id, name, status, started_at, archived_at
+func scanSeason(row rowScanner) (models.Season, error) {
+	var season models.Season
+	var startedAt, archivedAt sql.NullTime
+
+	err := row.Scan(&season.ID, &season.CompetitionID, &season.Name, &season.Status, &startedAt, &archivedAt)
+	if err != nil {
+		return season, err
+	}
+
+	if startedAt.Valid {
+		season.StartedAt = startedAt.Time
+	}
+	if archivedAt.Valid {
+		season.ArchivedAt = &archivedAt.Time
+	}
+
+	return season, nil
+}
+
+// loadSeason reads a single season
+func loadSeason(q querier, id int) (models.Season, error) {
+	return scanSeason(q.QueryRow(
+		"SELECT id, competition_id, name, status, started_at, archived_at FROM seasons WHERE id = $1", id,
+	))
+}
+
+// loadActiveSeason reads the active season of a competition
+func loadActiveSeason(q querier, competitionID int) (models.Season, error) {
+	return scanSeason(q.QueryRow(`
+		SELECT id, competition_id, name, status, started_at, archived_at
+		FROM seasons
+		WHERE status = $1 AND competition_id = $2
+		ORDER BY id DESC LIMIT 1
+	`, models.SeasonActive, competitionID))
+}
+
+// loadCurrentSeason reads the active season of the top division, which is
+// the season the endpoints without a season in their route work on
+func loadCurrentSeason(q querier) (models.Season, error) {
+	competition, err := loadDefaultCompetition(q)
+	if err != nil {
+		return models.Season{}, err
+	}
+	return loadActiveSeason(q, competition.ID)
+}
+
+// loadTeamSeason reads the active season of the division a team plays in
+func loadTeamSeason(q querier, teamID int) (models.Season, error) {
+	return scanSeason(q.QueryRow(`
+		SELECT s.id, s.competition_id, s.name, s.status, s.started_at, s.archived_at
+		FROM seasons s
+		JOIN league_table lt ON lt.season_id = s.id
+		JOIN competitions c ON s.competition_id = c.id
+		WHERE lt.team_id = $1 AND s.status = $2 AND c.format = $3
+		ORDER BY s.id DESC LIMIT 1
+	`, teamID, models.SeasonActive, models.FormatLeague))
+}
+
+// checkLeagueSeason refuses to run league endpoints on the season of a cup
+func checkLeagueSeason(q querier, season models.Season) (int, string) {
+	competition, err := loadCompetition(q, season.CompetitionID)
+	if err != nil {
+		return fiber.StatusInternalServerError, "Failed to get competition: " + err.Error()
+	}
+	if competition.Format != models.FormatLeague {
+		return fiber.StatusConflict, season.Name + " of " + competition.Name + " is a cup; use the cup endpoints instead"
+	}
+	return 0, ""
+}
+
+// requestSeason returns the season named by the :seasonId route parameter, or
+// the current season on routes without one
+func requestSeason(c *fiber.Ctx) (models.Season, error) {
+	value := c.Params("seasonId")
+	if value == "" {
+		return loadCurrentSeason(database.DB)
+	}
+
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return models.Season{}, err
+	}
+	return loadSeason(database.DB, id)
+}
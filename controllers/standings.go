@@ -0,0 +1,287 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// matchResult is a played match as seen by the standings code
+type matchResult struct {
+	HomeTeamID int
+	AwayTeamID int
+	HomeScore  int
+	AwayScore  int
+	Week       int
+
+	HomeYellowCards int
+	HomeRedCards    int
+	AwayYellowCards int
+	AwayRedCards    int
+}
+
+// fixture is a scheduled match that has not been played yet.
+// ID is zero until the fixture has been stored in the matches table.
+type fixture struct {
+	ID         int
+	HomeTeamID int
+	AwayTeamID int
+	Week       int
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// loadSeasonTeams returns the teams entered in a season ordered by ID.
+// A team takes part in a season when it has a league table row for it.
+func loadSeasonTeams(q querier, seasonID int) ([]models.Team, error) {
+	rows, err := q.Query(`
+		SELECT t.id, t.name
+		FROM league_table lt
+		JOIN teams t ON lt.team_id = t.id
+		WHERE lt.season_id = $1
+		ORDER BY t.id
+	`, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teams []models.Team
+	for rows.Next() {
+		var team models.Team
+		if err := rows.Scan(&team.ID, &team.Name); err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+
+	return teams, rows.Err()
+}
+
+// loadResults returns the played matches of a season for every week up to and including maxWeek
+func loadResults(q querier, seasonID, maxWeek int) ([]matchResult, error) {
+	rows, err := q.Query(`
+		SELECT home_team_id, away_team_id, home_score, away_score, week,
+		       home_yellow_cards, home_red_cards, away_yellow_cards, away_red_cards
+		FROM matches
+		WHERE season_id = $1 AND played = true AND week <= $2
+		ORDER BY week, id
+	`, seasonID, maxWeek)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []matchResult
+	for rows.Next() {
+		var result matchResult
+		err := rows.Scan(
+			&result.HomeTeamID, &result.AwayTeamID, &result.HomeScore, &result.AwayScore, &result.Week,
+			&result.HomeYellowCards, &result.HomeRedCards, &result.AwayYellowCards, &result.AwayRedCards,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// loadAllResults returns every played match of a season
+func loadAllResults(q querier, seasonID int) ([]matchResult, error) {
+	var maxWeek int
+	err := q.QueryRow("SELECT COALESCE(MAX(week), 0) FROM matches WHERE season_id = $1", seasonID).Scan(&maxWeek)
+	if err != nil {
+		return nil, err
+	}
+	return loadResults(q, seasonID, maxWeek)
+}
+
+// computeLeagueTable computes a season's league table from the match log
+func computeLeagueTable(q querier, seasonID int) ([]models.TeamStats, error) {
+	teams, err := loadSeasonTeams(q, seasonID)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := loadAllResults(q, seasonID)
+	if err != nil {
+		return nil, err
+	}
+
+	return computeStandings(teams, results), nil
+}
+
+// loadStoredLeagueTable reads a season's league_table rows keyed by team ID
+func loadStoredLeagueTable(q querier, seasonID int) (map[int]models.TeamStats, error) {
+	rows, err := q.Query(`
+		SELECT lt.points, lt.played, lt.wins, lt.draws, lt.losses,
+		       lt.goals_for, lt.goals_against, lt.goal_difference,
+		       t.id, t.name
+		FROM league_table lt
+		JOIN teams t ON lt.team_id = t.id
+		WHERE lt.season_id = $1
+	`, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := make(map[int]models.TeamStats)
+	for rows.Next() {
+		var stats models.TeamStats
+		err := rows.Scan(
+			&stats.Points, &stats.Played, &stats.Wins, &stats.Draws, &stats.Losses,
+			&stats.GoalsFor, &stats.GoalsAgainst, &stats.GoalDifference,
+			&stats.Team.ID, &stats.Team.Name,
+		)
+		if err != nil {
+			return nil, err
+		}
+		stored[stats.Team.ID] = stats
+	}
+
+	return stored, rows.Err()
+}
+
+// findLeagueTableDrift compares the stored league table with the one computed
+// from the match log and returns every team whose figures differ
+func findLeagueTableDrift(q querier, seasonID int) ([]models.LeagueTableDrift, error) {
+	expected, err := computeLeagueTable(q, seasonID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute league table: %v", err)
+	}
+
+	stored, err := loadStoredLeagueTable(q, seasonID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stored league table: %v", err)
+	}
+
+	var drift []models.LeagueTableDrift
+	for _, want := range expected {
+		have, ok := stored[want.Team.ID]
+		want.Position = 0
+		if !ok || !sameRecord(have, want) {
+			drift = append(drift, models.LeagueTableDrift{
+				Team:     want.Team,
+				Missing:  !ok,
+				Stored:   have,
+				Expected: want,
+			})
+		}
+	}
+
+	return drift, nil
+}
+
+// sameRecord reports whether two table rows hold the same figures
+func sameRecord(a, b models.TeamStats) bool {
+	return a.Points == b.Points && a.Played == b.Played &&
+		a.Wins == b.Wins && a.Draws == b.Draws && a.Losses == b.Losses &&
+		a.GoalsFor == b.GoalsFor && a.GoalsAgainst == b.GoalsAgainst &&
+		a.GoalDifference == b.GoalDifference
+}
+
+// rebuildLeagueTable overwrites the league_table projection with the
+// standings computed from the matches table. It is called after every change
+// to a match result, so the stored table can never drift from the match log.
+func rebuildLeagueTable(tx *sql.Tx, seasonID int) error {
+	standings, err := computeLeagueTable(tx, seasonID)
+	if err != nil {
+		return err
+	}
+
+	for _, stats := range standings {
+		_, err := tx.Exec(`
+			INSERT INTO league_table (season_id, team_id, points, played, wins, draws, losses,
+			                          goals_for, goals_against, goal_difference, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, CURRENT_TIMESTAMP)
+			ON CONFLICT (season_id, team_id) DO UPDATE SET
+			points = EXCLUDED.points,
+			played = EXCLUDED.played,
+			wins = EXCLUDED.wins,
+			draws = EXCLUDED.draws,
+			losses = EXCLUDED.losses,
+			goals_for = EXCLUDED.goals_for,
+			goals_against = EXCLUDED.goals_against,
+			goal_difference = EXCLUDED.goal_difference,
+			updated_at = EXCLUDED.updated_at
+		`,
+			seasonID, stats.Team.ID, stats.Points, stats.Played, stats.Wins, stats.Draws, stats.Losses,
+			stats.GoalsFor, stats.GoalsAgainst, stats.GoalDifference,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// computeStandings builds a sorted league table from a list of played matches
+func computeStandings(teams []models.Team, results []matchResult) []models.TeamStats {
+	index := make(map[int]int, len(teams))
+	standings := make([]models.TeamStats, len(teams))
+	for i, team := range teams {
+		index[team.ID] = i
+		standings[i].Team = team
+	}
+
+	for _, result := range results {
+		home, okHome := index[result.HomeTeamID]
+		away, okAway := index[result.AwayTeamID]
+		if !okHome || !okAway {
+			continue
+		}
+		addResult(&standings[home], result.HomeScore, result.AwayScore)
+		addResult(&standings[away], result.AwayScore, result.HomeScore)
+	}
+
+	sortStandings(standings)
+	for i := range standings {
+		standings[i].Position = i + 1
+	}
+	return standings
+}
+
+// addResult adds a single match result to a team's statistics
+func addResult(stats *models.TeamStats, goalsFor, goalsAgainst int) {
+	stats.Played++
+	stats.GoalsFor += goalsFor
+	stats.GoalsAgainst += goalsAgainst
+	stats.GoalDifference += goalsFor - goalsAgainst
+
+	if goalsFor > goalsAgainst {
+		stats.Wins++
+		stats.Points += 3
+	} else if goalsFor == goalsAgainst {
+		stats.Draws++
+		stats.Points++
+	} else {
+		stats.Losses++
+	}
+}
+
+// sortStandings orders a table by points, goal difference and goals scored
+func sortStandings(standings []models.TeamStats) {
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.GoalDifference != b.GoalDifference {
+			return a.GoalDifference > b.GoalDifference
+		}
+		if a.GoalsFor != b.GoalsFor {
+			return a.GoalsFor > b.GoalsFor
+		}
+		return a.Team.Name < b.Team.Name
+	})
+}
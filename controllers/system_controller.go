@@ -1,12 +1,19 @@
 package controllers
 
 import (
+	"database/sql"
+	"fmt"
+	"strconv"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/config"
 	"github.com/sametyildirim314/insider_case/database"
+	"github.com/sametyildirim314/insider_case/models"
 )
 
 // ResetSystem handles the request to reset the system
-// This will clear all matches, predictions, and reset the league table
+// This will clear the matches and predictions of the current season of every
+// division and reset their league tables. Archived seasons are left untouched.
 func ResetSystem(c *fiber.Ctx) error {
 	// Start a transaction
 	tx, err := database.DB.Begin()
@@ -16,52 +23,163 @@ func ResetSystem(c *fiber.Ctx) error {
 		})
 	}
 	
-	// Delete all matches
-	_, err = tx.Exec("DELETE FROM matches")
+	rows, err := tx.Query("SELECT id FROM seasons WHERE status = $1 ORDER BY id", models.SeasonActive)
 	if err != nil {
 		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete matches: " + err.Error(),
+			"error": "Failed to get current seasons: " + err.Error(),
 		})
 	}
 	
-	// Delete all predictions
-	_, err = tx.Exec("DELETE FROM predictions")
+	var seasonIDs []int
+	for rows.Next() {
+		var seasonID int
+		if err := rows.Scan(&seasonID); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to scan season: " + err.Error(),
+			})
+		}
+		seasonIDs = append(seasonIDs, seasonID)
+	}
+	rows.Close()
+	
+	for _, seasonID := range seasonIDs {
+		if err := clearSeason(tx, seasonID); err != nil {
+			tx.Rollback()
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to reset system: " + err.Error(),
+			})
+		}
+	}
+	
+	// Commit transaction
+	err = tx.Commit()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction: " + err.Error(),
+		})
+	}
+	
+	return c.JSON(fiber.Map{
+		"message": "System reset successful. All matches, predictions, and league table data of the current seasons have been cleared.",
+	})
+}
+
+// clearSeason deletes all matches and predictions of a season and zeroes its
+// league table inside the given transaction
+func clearSeason(tx *sql.Tx, seasonID int) error {
+	// Delete all predictions and the history of runs they belong to
+	if _, err := tx.Exec("DELETE FROM predictions WHERE season_id = $1", seasonID); err != nil {
+		return fmt.Errorf("failed to delete predictions: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM prediction_runs WHERE season_id = $1", seasonID); err != nil {
+		return fmt.Errorf("failed to delete prediction runs: %v", err)
+	}
+	
+	// Scenarios are laid over the matches about to go
+	if _, err := tx.Exec("DELETE FROM scenarios WHERE season_id = $1", seasonID); err != nil {
+		return fmt.Errorf("failed to delete scenarios: %v", err)
+	}
+	
+	// Delete the cup and group draws; the cup draw refers to the matches
+	if _, err := tx.Exec("DELETE FROM cup_ties WHERE season_id = $1", seasonID); err != nil {
+		return fmt.Errorf("failed to delete cup ties: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM tournament_groups WHERE season_id = $1", seasonID); err != nil {
+		return fmt.Errorf("failed to delete tournament groups: %v", err)
+	}
+	
+	// Ratings are replayed from the season's first played match, found before it goes
+	from, err := firstEloPosition(tx, seasonID, 0)
+	replay := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to find first played match: %v", err)
+	}
+	
+	// Delete all matches
+	if _, err := tx.Exec("DELETE FROM matches WHERE season_id = $1", seasonID); err != nil {
+		return fmt.Errorf("failed to delete matches: %v", err)
+	}
+	
+	// With no matches left the rebuilt table is all zeros
+	if err := rebuildLeagueTable(tx, seasonID); err != nil {
+		return fmt.Errorf("failed to reset league table: %v", err)
+	}
+	
+	// The deleted matches no longer count towards the Elo ratings
+	if replay {
+		if err := rebuildEloRatings(tx, from, config.GetConfig()); err != nil {
+			return err
+		}
+	}
+	
+	return nil
+}
+
+// CheckLeagueTable handles the request to compare the current season's stored
+// league table with the one computed from the played matches, without changing anything
+func CheckLeagueTable(c *fiber.Ctx) error {
+	season, err := loadCurrentSeason(database.DB)
 	if err != nil {
-		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to delete predictions: " + err.Error(),
+			"error": "Failed to get current season: " + err.Error(),
 		})
 	}
 	
-	// Reset league table
-	_, err = tx.Exec(`
-		UPDATE league_table SET 
-		points = 0,
-		played = 0,
-		wins = 0,
-		draws = 0,
-		losses = 0,
-		goals_for = 0,
-		goals_against = 0,
-		goal_difference = 0
-	`)
+	drift, err := findLeagueTableDrift(database.DB, season.ID)
 	if err != nil {
-		tx.Rollback()
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to reset league table: " + err.Error(),
+			"error": "Failed to check league table: " + err.Error(),
 		})
 	}
 	
-	// Commit transaction
-	err = tx.Commit()
+	return c.JSON(fiber.Map{
+		"consistent": len(drift) == 0,
+		"drift": drift,
+	})
+}
+
+// RepairLeagueTable handles the request to rebuild the current season's stored
+// league table from the played matches. The response lists the rows that were corrected.
+func RepairLeagueTable(c *fiber.Ctx) error {
+	tx, err := database.DB.Begin()
 	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction: " + err.Error(),
+		})
+	}
+	defer tx.Rollback()
+	
+	season, err := loadCurrentSeason(tx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get current season: " + err.Error(),
+		})
+	}
+	
+	drift, err := findLeagueTableDrift(tx, season.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check league table: " + err.Error(),
+		})
+	}
+	
+	if err := rebuildLeagueTable(tx, season.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to rebuild league table: " + err.Error(),
+		})
+	}
+	
+	if err := tx.Commit(); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to commit transaction: " + err.Error(),
 		})
 	}
 	
 	return c.JSON(fiber.Map{
-		"message": "System reset successful. All matches, predictions, and league table data have been cleared.",
+		"message": "League table rebuilt from the match log, " + strconv.Itoa(len(drift)) + " rows corrected",
+		"repaired": drift,
 	})
-} 
\ No newline at end of file
+}
@@ -1,7 +1,10 @@
 package controllers
 
 import (
+	"database/sql"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/sametyildirim314/insider_case/database"
@@ -11,7 +14,7 @@ import (
 // GetAllTeams gets all teams from the database and returns them
 func GetAllTeams(c *fiber.Ctx) error {
 	// Fetch teams directly from database
-	rows, err := database.DB.Query("SELECT id, name FROM teams")
+	rows, err := database.DB.Query("SELECT id, name, COALESCE(short_code, ''), COALESCE(country, ''), attack_rating, defence_rating, elo_rating FROM teams ORDER BY id")
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to get teams: " + err.Error(),
@@ -22,7 +25,7 @@ func GetAllTeams(c *fiber.Ctx) error {
 	var teams []models.Team
 	for rows.Next() {
 		var team models.Team
-		err := rows.Scan(&team.ID, &team.Name)
+		err := rows.Scan(&team.ID, &team.Name, &team.ShortCode, &team.Country, &team.AttackRating, &team.DefenceRating, &team.EloRating)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to scan team: " + err.Error(),
@@ -44,8 +47,7 @@ func GetTeamByID(c *fiber.Ctx) error {
 	}
 	
 	// Fetch team directly from database
-	var team models.Team
-	err = database.DB.QueryRow("SELECT id, name FROM teams WHERE id = $1", id).Scan(&team.ID, &team.Name)
+	team, err := getTeam(database.DB, id)
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"error": "Team not found",
@@ -53,4 +55,414 @@ func GetTeamByID(c *fiber.Ctx) error {
 	}
 	
 	return c.JSON(team)
-} 
\ No newline at end of file
+}
+
+// teamRequest is the body accepted by the create and update team endpoints.
+// Fields left out of an update keep their current value. CompetitionID picks
+// the division a new team joins and is only accepted on create.
+type teamRequest struct {
+	Name          *string  `json:"name"`
+	ShortCode     *string  `json:"short_code"`
+	Country       *string  `json:"country"`
+	AttackRating  *float64 `json:"attack_rating"`
+	DefenceRating *float64 `json:"defence_rating"`
+	CompetitionID *int     `json:"competition_id"`
+}
+
+var shortCodePattern = regexp.MustCompile(`^[A-Z0-9]{2,5}$`)
+
+// Limits for the team ratings used by the match engine
+const (
+	minTeamRating = 0.1
+	maxTeamRating = 5.0
+)
+
+// CreateTeam handles the request to add a new team to the current season of
+// its division, which is the top division unless competition_id is given.
+// Adding a team changes the fixture list, so the season's matches, predictions
+// and league table figures are cleared. Once matches have been played this is
+// refused unless the request is sent with ?force=true.
+func CreateTeam(c *fiber.Ctx) error {
+	var req teamRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to parse request body: " + err.Error(),
+		})
+	}
+	
+	if req.Name == nil || req.ShortCode == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Both name and short_code are required",
+		})
+	}
+	
+	team := models.Team{AttackRating: 1.0, DefenceRating: 1.0, EloRating: initialEloRating}
+	if msg := applyTeamRequest(&team, req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction: " + err.Error(),
+		})
+	}
+	defer tx.Rollback()
+	
+	var season models.Season
+	if req.CompetitionID != nil {
+		competition, err := loadCompetition(tx, *req.CompetitionID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Competition not found",
+			})
+		}
+		if competition.Format != models.FormatLeague {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Teams are entered in a division; cups draw their teams from the divisions",
+			})
+		}
+		season, err = loadActiveSeason(tx, competition.ID)
+	} else {
+		season, err = loadCurrentSeason(tx)
+	}
+	if err == sql.ErrNoRows {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Competition has no active season",
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get current season: " + err.Error(),
+		})
+	}
+	
+	if status, msg := checkSeasonChangeAllowed(tx, season.ID, c.QueryBool("force")); msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	
+	if status, msg := checkTeamUnique(tx, team); msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	
+	err = tx.QueryRow(
+		"INSERT INTO teams (name, short_code, country, attack_rating, defence_rating) VALUES ($1, $2, NULLIF($3, ''), $4, $5) RETURNING id",
+		team.Name, team.ShortCode, team.Country, team.AttackRating, team.DefenceRating,
+	).Scan(&team.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create team: " + err.Error(),
+		})
+	}
+	
+	// Give the new team a row in the current season's league table
+	if _, err := tx.Exec("INSERT INTO league_table (season_id, team_id) VALUES ($1, $2)", season.ID, team.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create league table entry: " + err.Error(),
+		})
+	}
+	
+	// The old fixtures do not include the new team
+	if err := clearSeason(tx, season.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to reset season: " + err.Error(),
+		})
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction: " + err.Error(),
+		})
+	}
+	
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Team created successfully. Fixtures will be regenerated on the next simulation.",
+		"team":    team,
+	})
+}
+
+// UpdateTeam handles the request to change a team's name, short code or ratings.
+// The set of teams stays the same, so the season is left untouched.
+func UpdateTeam(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+	
+	var req teamRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to parse request body: " + err.Error(),
+		})
+	}
+	
+	// Teams change division through promotion and relegation only
+	if req.CompetitionID != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "competition_id can only be set when creating a team",
+		})
+	}
+	
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction: " + err.Error(),
+		})
+	}
+	defer tx.Rollback()
+	
+	team, err := getTeam(tx, id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Team not found",
+		})
+	}
+	
+	if msg := applyTeamRequest(&team, req); msg != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	
+	if status, msg := checkTeamUnique(tx, team); msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	
+	_, err = tx.Exec(
+		"UPDATE teams SET name = $1, short_code = $2, country = NULLIF($3, ''), attack_rating = $4, defence_rating = $5 WHERE id = $6",
+		team.Name, team.ShortCode, team.Country, team.AttackRating, team.DefenceRating, team.ID,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update team: " + err.Error(),
+		})
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction: " + err.Error(),
+		})
+	}
+	
+	return c.JSON(fiber.Map{
+		"message": "Team updated successfully",
+		"team":    team,
+	})
+}
+
+// DeleteTeam handles the request to remove a team.
+// Like CreateTeam it clears the current season of the team's division and
+// needs ?force=true once matches have been played. Teams that took part in an archived season are
+// kept so the history of that season stays intact.
+func DeleteTeam(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid team ID",
+		})
+	}
+	
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction: " + err.Error(),
+		})
+	}
+	defer tx.Rollback()
+	
+	team, err := getTeam(tx, id)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Team not found",
+		})
+	}
+	
+	// A team outside every active season has no current season to clear
+	season, err := loadTeamSeason(tx, id)
+	if err != nil && err != sql.ErrNoRows {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get current season: " + err.Error(),
+		})
+	}
+	
+	var archived int
+	err = tx.QueryRow(`
+		SELECT COUNT(*)
+		FROM league_table lt
+		JOIN seasons s ON lt.season_id = s.id
+		WHERE lt.team_id = $1 AND s.status = $2
+	`, id, models.SeasonArchived).Scan(&archived)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check team history: " + err.Error(),
+		})
+	}
+	if archived > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Team " + team.Name + " took part in " + strconv.Itoa(archived) + " other seasons and cannot be deleted",
+		})
+	}
+	
+	// A cup or tournament that has been drawn cannot lose a team
+	var ties int
+	err = tx.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM cup_ties WHERE home_team_id = $1 OR away_team_id = $1) +
+		       (SELECT COUNT(*) FROM tournament_groups WHERE team_id = $1)
+	`, id).Scan(&ties)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to check cup draws: " + err.Error(),
+		})
+	}
+	if ties > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Team " + team.Name + " has been drawn in a cup or tournament and cannot be deleted",
+		})
+	}
+	
+	if status, msg := checkSeasonChangeAllowed(tx, season.ID, c.QueryBool("force")); msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	
+	// Matches and predictions reference the team, so clear them first
+	if season.ID != 0 {
+		if err := clearSeason(tx, season.ID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to reset season: " + err.Error(),
+			})
+		}
+	}
+	
+	if _, err := tx.Exec("DELETE FROM league_table WHERE team_id = $1", id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete league table entry: " + err.Error(),
+		})
+	}
+	
+	// Clearing the season removed every goal credited to the squad
+	if _, err := tx.Exec("DELETE FROM players WHERE team_id = $1", id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete players: " + err.Error(),
+		})
+	}
+	
+	if _, err := tx.Exec("DELETE FROM teams WHERE id = $1", id); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to delete team: " + err.Error(),
+		})
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction: " + err.Error(),
+		})
+	}
+	
+	return c.JSON(fiber.Map{
+		"message": "Team " + team.Name + " deleted successfully. Fixtures will be regenerated on the next simulation.",
+	})
+}
+
+// getTeam loads a single team with its short code and ratings
+func getTeam(q querier, id int) (models.Team, error) {
+	var team models.Team
+	err := q.QueryRow(
+		"SELECT id, name, COALESCE(short_code, ''), COALESCE(country, ''), attack_rating, defence_rating, elo_rating FROM teams WHERE id = $1", id,
+	).Scan(&team.ID, &team.Name, &team.ShortCode, &team.Country, &team.AttackRating, &team.DefenceRating, &team.EloRating)
+	return team, err
+}
+
+// applyTeamRequest copies the fields present in req onto team and validates
+// the result. It returns a message describing the first problem found.
+func applyTeamRequest(team *models.Team, req teamRequest) string {
+	if req.Name != nil {
+		team.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.ShortCode != nil {
+		team.ShortCode = strings.ToUpper(strings.TrimSpace(*req.ShortCode))
+	}
+	if req.Country != nil {
+		team.Country = strings.TrimSpace(*req.Country)
+	}
+	if req.AttackRating != nil {
+		team.AttackRating = *req.AttackRating
+	}
+	if req.DefenceRating != nil {
+		team.DefenceRating = *req.DefenceRating
+	}
+	
+	if team.Name == "" || len(team.Name) > 100 {
+		return "Team name must be between 1 and 100 characters"
+	}
+	if !shortCodePattern.MatchString(team.ShortCode) {
+		return "Short code must be 2 to 5 letters or digits"
+	}
+	if len(team.Country) > 60 {
+		return "Country must be at most 60 characters"
+	}
+	if team.AttackRating < minTeamRating || team.AttackRating > maxTeamRating {
+		return "Attack rating must be between 0.1 and 5.0"
+	}
+	if team.DefenceRating < minTeamRating || team.DefenceRating > maxTeamRating {
+		return "Defence rating must be between 0.1 and 5.0"
+	}
+	
+	return ""
+}
+
+// checkTeamUnique makes sure no other team uses the same name or short code
+func checkTeamUnique(tx *sql.Tx, team models.Team) (int, string) {
+	var count int
+	err := tx.QueryRow(
+		"SELECT COUNT(*) FROM teams WHERE LOWER(name) = LOWER($1) AND id <> $2",
+		team.Name, team.ID,
+	).Scan(&count)
+	if err != nil {
+		return fiber.StatusInternalServerError, "Failed to check team name: " + err.Error()
+	}
+	if count > 0 {
+		return fiber.StatusConflict, "A team named " + team.Name + " already exists"
+	}
+	
+	err = tx.QueryRow(
+		"SELECT COUNT(*) FROM teams WHERE short_code = $1 AND id <> $2",
+		team.ShortCode, team.ID,
+	).Scan(&count)
+	if err != nil {
+		return fiber.StatusInternalServerError, "Failed to check short code: " + err.Error()
+	}
+	if count > 0 {
+		return fiber.StatusConflict, "Short code " + team.ShortCode + " is already in use"
+	}
+	
+	return 0, ""
+}
+
+// checkSeasonChangeAllowed refuses to change the set of teams once matches
+// of the season have been played, unless the caller forces it
+func checkSeasonChangeAllowed(tx *sql.Tx, seasonID int, force bool) (int, string) {
+	var played int
+	err := tx.QueryRow("SELECT COUNT(*) FROM matches WHERE season_id = $1 AND played = true", seasonID).Scan(&played)
+	if err != nil {
+		return fiber.StatusInternalServerError, "Failed to check season state: " + err.Error()
+	}
+	
+	if played > 0 && !force {
+		return fiber.StatusConflict, "The season is in progress (" + strconv.Itoa(played) + " matches played). " +
+			"Changing the teams clears all matches and the league table; retry with ?force=true to continue"
+	}
+	
+	return 0, ""
+}
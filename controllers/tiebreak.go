@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// Names of the tie-break rules a competition can put in its chain.
+// Teams are always ranked on points first; the chain decides the order of
+// teams that are level on points, one rule at a time.
+const (
+	ruleGoalDifference     = "goal_difference"
+	ruleGoalsFor           = "goals_for"
+	ruleHeadToHeadPoints   = "head_to_head_points"
+	ruleHeadToHeadGoalDiff = "head_to_head_goal_difference"
+	ruleAwayGoals          = "away_goals"
+	ruleWins               = "wins"
+	ruleFairPlay           = "fair_play"
+	ruleDrawingOfLots      = "drawing_of_lots"
+)
+
+// tieBreakRule gives every team of a tied group a score; higher scores rank first.
+// Head-to-head rules only look at the matches played between the teams of the group.
+type tieBreakRule func(ctx *tieBreakContext, group []int) map[int]int
+
+var tieBreakRules = map[string]tieBreakRule{
+	ruleGoalDifference: func(ctx *tieBreakContext, group []int) map[int]int {
+		return ctx.scoreEach(group, func(s *models.TeamStats) int { return s.GoalDifference })
+	},
+	ruleGoalsFor: func(ctx *tieBreakContext, group []int) map[int]int {
+		return ctx.scoreEach(group, func(s *models.TeamStats) int { return s.GoalsFor })
+	},
+	ruleWins: func(ctx *tieBreakContext, group []int) map[int]int {
+		return ctx.scoreEach(group, func(s *models.TeamStats) int { return s.Wins })
+	},
+	ruleHeadToHeadPoints: func(ctx *tieBreakContext, group []int) map[int]int {
+		points, _ := ctx.headToHead(group)
+		return points
+	},
+	ruleHeadToHeadGoalDiff: func(ctx *tieBreakContext, group []int) map[int]int {
+		_, goalDifference := ctx.headToHead(group)
+		return goalDifference
+	},
+	ruleAwayGoals: func(ctx *tieBreakContext, group []int) map[int]int {
+		scores := make(map[int]int, len(group))
+		for _, result := range ctx.results {
+			scores[result.AwayTeamID] += result.AwayScore
+		}
+		return scores
+	},
+	ruleFairPlay: func(ctx *tieBreakContext, group []int) map[int]int {
+		// One point off for every yellow card and three for every red card
+		scores := make(map[int]int, len(group))
+		for _, result := range ctx.results {
+			scores[result.HomeTeamID] -= result.HomeYellowCards + 3*result.HomeRedCards
+			scores[result.AwayTeamID] -= result.AwayYellowCards + 3*result.AwayRedCards
+		}
+		return scores
+	},
+	ruleDrawingOfLots: func(ctx *tieBreakContext, group []int) map[int]int {
+		drawn := make([]int, len(group))
+		copy(drawn, group)
+		sort.Ints(drawn)
+		ctx.lots.Shuffle(len(drawn), func(i, j int) {
+			drawn[i], drawn[j] = drawn[j], drawn[i]
+		})
+
+		scores := make(map[int]int, len(drawn))
+		for i, teamID := range drawn {
+			scores[teamID] = len(drawn) - i
+		}
+		return scores
+	},
+}
+
+// parseTieBreakers splits a stored tie-break setting into rule names
+func parseTieBreakers(setting string) []string {
+	var chain []string
+	for _, rule := range strings.Split(setting, ",") {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			chain = append(chain, rule)
+		}
+	}
+	return chain
+}
+
+// validateTieBreakers checks that every rule in a chain exists and is used only once
+func validateTieBreakers(chain []string) string {
+	seen := make(map[string]bool)
+	for _, rule := range chain {
+		if _, ok := tieBreakRules[rule]; !ok {
+			return "Unknown tie-break rule: " + rule
+		}
+		if seen[rule] {
+			return "Tie-break rule used more than once: " + rule
+		}
+		seen[rule] = true
+	}
+	return ""
+}
+
+// containsRule reports whether a chain uses the given rule
+func containsRule(chain []string, rule string) bool {
+	for _, r := range chain {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// tieBreakContext holds what the rules need while ranking one table
+type tieBreakContext struct {
+	stats       map[int]*models.TeamStats
+	results     []matchResult
+	lots        *rand.Rand
+	separatedBy map[[2]int]string
+}
+
+// scoreEach scores every team in the group with a figure from its table row
+func (ctx *tieBreakContext) scoreEach(group []int, figure func(*models.TeamStats) int) map[int]int {
+	scores := make(map[int]int, len(group))
+	for _, teamID := range group {
+		scores[teamID] = figure(ctx.stats[teamID])
+	}
+	return scores
+}
+
+// headToHead builds the mini-league of the matches played between the teams of a group
+func (ctx *tieBreakContext) headToHead(group []int) (map[int]int, map[int]int) {
+	inGroup := make(map[int]bool, len(group))
+	for _, teamID := range group {
+		inGroup[teamID] = true
+	}
+
+	points := make(map[int]int, len(group))
+	goalDifference := make(map[int]int, len(group))
+	for _, result := range ctx.results {
+		if !inGroup[result.HomeTeamID] || !inGroup[result.AwayTeamID] {
+			continue
+		}
+
+		goalDifference[result.HomeTeamID] += result.HomeScore - result.AwayScore
+		goalDifference[result.AwayTeamID] += result.AwayScore - result.HomeScore
+		if result.HomeScore > result.AwayScore {
+			points[result.HomeTeamID] += 3
+		} else if result.HomeScore == result.AwayScore {
+			points[result.HomeTeamID]++
+			points[result.AwayTeamID]++
+		} else {
+			points[result.AwayTeamID] += 3
+		}
+	}
+
+	return points, goalDifference
+}
+
+// resolve orders a group of teams that are level on points by applying the
+// rules of the chain in turn. A rule splits the group into smaller groups of
+// teams with the same score, and the next rule is applied to each of those.
+func (ctx *tieBreakContext) resolve(group []int, chain []string) []int {
+	if len(group) <= 1 || len(chain) == 0 {
+		return group
+	}
+
+	rule := chain[0]
+	scores := tieBreakRules[rule](ctx, group)
+	sort.SliceStable(group, func(i, j int) bool {
+		return scores[group[i]] > scores[group[j]]
+	})
+
+	var ordered []int
+	start := 0
+	for start < len(group) {
+		end := start + 1
+		for end < len(group) && scores[group[end]] == scores[group[start]] {
+			end++
+		}
+
+		// Every team in this sub-group was separated by this rule from the teams below it
+		for _, above := range group[start:end] {
+			for _, below := range group[end:] {
+				ctx.separatedBy[[2]int{above, below}] = rule
+			}
+		}
+
+		ordered = append(ordered, ctx.resolve(group[start:end], chain[1:])...)
+		start = end
+	}
+
+	return ordered
+}
+
+// rankStandings sorts a league table by points and separates teams that are
+// level on points with the competition's tie-break chain. Drawing of lots is
+// always the last resort so the order is never arbitrary; lotsSeed makes the
+// draw repeatable and is reported on every pair it decides. Each team's
+// Position is set, and TieBreak records which rule put it above the next team.
+func rankStandings(standings []models.TeamStats, results []matchResult, chain []string, lotsSeed int64) {
+	rankStandingsWith(standings, results, chain, rand.New(rand.NewSource(lotsSeed)), &lotsSeed)
+}
+
+// rankStandingsWith is rankStandings with a caller-supplied source for the
+// drawing of lots. seed is reported on lots decisions when it is not nil.
+func rankStandingsWith(standings []models.TeamStats, results []matchResult, chain []string, lots *rand.Rand, seed *int64) {
+	if !containsRule(chain, ruleDrawingOfLots) {
+		chain = append(append([]string{}, chain...), ruleDrawingOfLots)
+	}
+
+	// Start from a fixed order so the outcome only depends on the table
+	sort.SliceStable(standings, func(i, j int) bool {
+		if standings[i].Points != standings[j].Points {
+			return standings[i].Points > standings[j].Points
+		}
+		return standings[i].Team.ID < standings[j].Team.ID
+	})
+
+	ctx := &tieBreakContext{
+		stats:       make(map[int]*models.TeamStats, len(standings)),
+		results:     results,
+		lots:        lots,
+		separatedBy: make(map[[2]int]string),
+	}
+	for i := range standings {
+		ctx.stats[standings[i].Team.ID] = &standings[i]
+	}
+
+	var order []int
+	start := 0
+	for start < len(standings) {
+		end := start + 1
+		for end < len(standings) && standings[end].Points == standings[start].Points {
+			end++
+		}
+
+		group := make([]int, 0, end-start)
+		for _, stats := range standings[start:end] {
+			group = append(group, stats.Team.ID)
+		}
+		order = append(order, ctx.resolve(group, chain)...)
+		start = end
+	}
+
+	ranked := make([]models.TeamStats, len(order))
+	for i, teamID := range order {
+		ranked[i] = *ctx.stats[teamID]
+		ranked[i].Position = i + 1
+		ranked[i].TieBreak = nil
+	}
+
+	for i := 0; i+1 < len(ranked); i++ {
+		rule, ok := ctx.separatedBy[[2]int{ranked[i].Team.ID, ranked[i+1].Team.ID}]
+		if !ok {
+			continue
+		}
+		ranked[i].TieBreak = &models.TieBreak{Rule: rule, NextTeamID: ranked[i+1].Team.ID}
+		if rule == ruleDrawingOfLots {
+			ranked[i].TieBreak.Seed = seed
+		}
+	}
+
+	copy(standings, ranked)
+}
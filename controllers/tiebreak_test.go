@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+func makeTeams(n int) []models.Team {
+	teams := make([]models.Team, n)
+	for i := range teams {
+		teams[i] = models.Team{ID: i + 1}
+	}
+	return teams
+}
+
+// rankedOrder returns the team IDs of a ranked table from the top
+func rankedOrder(standings []models.TeamStats) []int {
+	order := make([]int, len(standings))
+	for i, stats := range standings {
+		order[i] = stats.Team.ID
+	}
+	return order
+}
+
+// Teams 1, 2 and 3 finish on 7 points. Team 1 beat both others, so the
+// head-to-head mini-league puts it first; 2 and 3 drew with each other and
+// stay level on it, so goal difference, the next rule, separates them.
+func TestRankStandingsHeadToHeadThenNextRule(t *testing.T) {
+	results := []matchResult{
+		{HomeTeamID: 1, AwayTeamID: 2, HomeScore: 1, AwayScore: 0, Week: 1},
+		{HomeTeamID: 3, AwayTeamID: 4, HomeScore: 1, AwayScore: 0, Week: 1},
+		{HomeTeamID: 1, AwayTeamID: 3, HomeScore: 2, AwayScore: 1, Week: 2},
+		{HomeTeamID: 2, AwayTeamID: 4, HomeScore: 3, AwayScore: 0, Week: 2},
+		{HomeTeamID: 2, AwayTeamID: 3, HomeScore: 1, AwayScore: 1, Week: 3},
+		{HomeTeamID: 1, AwayTeamID: 4, HomeScore: 0, AwayScore: 0, Week: 3},
+		{HomeTeamID: 4, AwayTeamID: 1, HomeScore: 2, AwayScore: 0, Week: 4},
+		{HomeTeamID: 4, AwayTeamID: 2, HomeScore: 0, AwayScore: 1, Week: 5},
+		{HomeTeamID: 4, AwayTeamID: 3, HomeScore: 0, AwayScore: 2, Week: 6},
+	}
+	standings := computeStandings(makeTeams(4), results)
+	rankStandings(standings, results, []string{ruleHeadToHeadPoints, ruleGoalDifference, ruleGoalsFor}, 1)
+
+	if order := rankedOrder(standings); !reflect.DeepEqual(order, []int{1, 2, 3, 4}) {
+		t.Fatalf("got order %v, want [1 2 3 4]", order)
+	}
+	for i, stats := range standings[:3] {
+		if stats.Points != 7 {
+			t.Fatalf("team %d has %d points, want 7", stats.Team.ID, stats.Points)
+		}
+		if stats.Position != i+1 {
+			t.Fatalf("team %d at position %d, want %d", stats.Team.ID, stats.Position, i+1)
+		}
+	}
+
+	want := []*models.TieBreak{
+		{Rule: ruleHeadToHeadPoints, NextTeamID: 2},
+		{Rule: ruleGoalDifference, NextTeamID: 3},
+		nil, // ahead of team 4 on points
+		nil, // last
+	}
+	for i, stats := range standings {
+		if !reflect.DeepEqual(stats.TieBreak, want[i]) {
+			t.Fatalf("team %d: got tie-break %+v, want %+v", stats.Team.ID, stats.TieBreak, want[i])
+		}
+	}
+}
+
+// A rule that splits a group reports itself on every adjacent pair it
+// separates, even when it leaves a smaller group level for the next rule
+func TestRankStandingsReportsRulePerPair(t *testing.T) {
+	standings := []models.TeamStats{
+		{Team: models.Team{ID: 1}, Points: 10, GoalDifference: 2, GoalsFor: 8},
+		{Team: models.Team{ID: 2}, Points: 10, GoalDifference: 5, GoalsFor: 9},
+		{Team: models.Team{ID: 3}, Points: 10, GoalDifference: 2, GoalsFor: 6},
+		{Team: models.Team{ID: 4}, Points: 10, GoalDifference: 5, GoalsFor: 9},
+		{Team: models.Team{ID: 5}, Points: 9},
+	}
+	rankStandings(standings, nil, []string{ruleGoalDifference, ruleGoalsFor}, 42)
+
+	order := rankedOrder(standings)
+	if !reflect.DeepEqual(order[2:], []int{1, 3, 5}) {
+		t.Fatalf("got order %v, want 2 and 4 in either order, then [1 3 5]", order)
+	}
+
+	rules := []string{ruleDrawingOfLots, ruleGoalDifference, ruleGoalsFor, "", ""}
+	for i, stats := range standings {
+		if rules[i] == "" {
+			if stats.TieBreak != nil {
+				t.Fatalf("team %d: got tie-break %+v, want none", stats.Team.ID, stats.TieBreak)
+			}
+			continue
+		}
+		if stats.TieBreak == nil || stats.TieBreak.Rule != rules[i] || stats.TieBreak.NextTeamID != standings[i+1].Team.ID {
+			t.Fatalf("team %d: got tie-break %+v, want %s over team %d", stats.Team.ID, stats.TieBreak, rules[i], standings[i+1].Team.ID)
+		}
+		if (stats.TieBreak.Seed != nil) != (rules[i] == ruleDrawingOfLots) {
+			t.Fatalf("team %d: seed reported %v for rule %s", stats.Team.ID, stats.TieBreak.Seed != nil, rules[i])
+		}
+	}
+	if *standings[0].TieBreak.Seed != 42 {
+		t.Fatalf("got lots seed %d, want 42", *standings[0].TieBreak.Seed)
+	}
+}
+
+// Drawing of lots gives the same order for the same seed, whatever order the
+// table comes in, and the seed decides it
+func TestRankStandingsLotsRepeatable(t *testing.T) {
+	level := func(shuffle *rand.Rand) []models.TeamStats {
+		standings := computeStandings(makeTeams(6), nil)
+		if shuffle != nil {
+			shuffle.Shuffle(len(standings), func(i, j int) {
+				standings[i], standings[j] = standings[j], standings[i]
+			})
+		}
+		return standings
+	}
+
+	orders := make(map[string]bool)
+	shuffle := rand.New(rand.NewSource(3))
+	for seed := int64(1); seed <= 20; seed++ {
+		first := level(nil)
+		rankStandings(first, nil, parseTieBreakers(defaultTieBreakers), seed)
+
+		for i := 0; i < 5; i++ {
+			again := level(shuffle)
+			rankStandings(again, nil, parseTieBreakers(defaultTieBreakers), seed)
+			if !reflect.DeepEqual(again, first) {
+				t.Fatalf("seed %d: got order %v, then %v", seed, rankedOrder(first), rankedOrder(again))
+			}
+		}
+
+		for _, stats := range first[:len(first)-1] {
+			if stats.TieBreak == nil || stats.TieBreak.Rule != ruleDrawingOfLots || *stats.TieBreak.Seed != seed {
+				t.Fatalf("seed %d: team %d got tie-break %+v, want lots with the seed", seed, stats.Team.ID, stats.TieBreak)
+			}
+		}
+		orders[fmt.Sprint(rankedOrder(first))] = true
+	}
+
+	if len(orders) < 2 {
+		t.Fatalf("20 seeds all drew the same order")
+	}
+}
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// How often the match engine books a player or sends one off, per team and match
+const (
+	yellowCardsPerMatch = 1.7
+	redCardsPerMatch    = 0.06
+)
+
+// startingPlayers is the size of a lineup; the rest of a squad is the bench,
+// from which up to maxSubstitutions players come on in the second half
+const (
+	startingPlayers  = 11
+	maxSubstitutions = 3
+)
+
+// timelineMatch is a played match the engine tells the story of. The goals in
+// extra time are counted apart from the goals in normal time.
+type timelineMatch struct {
+	HomeTeamID    int
+	AwayTeamID    int
+	HomeGoals     int
+	AwayGoals     int
+	HomeExtra     int
+	AwayExtra     int
+	ExtraTime     bool
+	HomePenalties *int
+	AwayPenalties *int
+}
+
+// matchTimeline is the ordered event stream of a match with the goals it
+// credited and the cards it showed to each side
+type matchTimeline struct {
+	Events          []models.MatchEvent
+	Credits         []goalCredit
+	HomeYellowCards int
+	HomeRedCards    int
+	AwayYellowCards int
+	AwayRedCards    int
+}
+
+// pendingEvent is an event whose minute and team are drawn before it is known
+// which players take part in it. Events without a team mark a break in play.
+type pendingEvent struct {
+	Minute int
+	Type   string
+	TeamID int
+}
+
+// simulateTimeline spreads a match's goals over the minutes of the match and
+// adds bookings, sendings-off, substitutions and the breaks in play. The
+// events are played out in order, so a goal is credited to a player on the
+// pitch at that minute, a second yellow card becomes a red one and a player
+// sent off takes no further part. Teams without a squad get events without
+// players and no substitutions.
+func simulateTimeline(rng *rand.Rand, m timelineMatch, squads map[int][]models.Player) matchTimeline {
+	length := 90
+	if m.ExtraTime {
+		length = 120
+	}
+
+	var pending []pendingEvent
+	add := func(count, from, to int, eventType string, teamID int) {
+		for i := 0; i < count; i++ {
+			pending = append(pending, pendingEvent{Minute: from + rng.Intn(to-from+1), Type: eventType, TeamID: teamID})
+		}
+	}
+
+	onPitch := make(map[int][]models.Player)
+	bench := make(map[int][]models.Player)
+	sides := []struct {
+		TeamID int
+		Goals  int
+		Extra  int
+	}{
+		{m.HomeTeamID, m.HomeGoals, m.HomeExtra},
+		{m.AwayTeamID, m.AwayGoals, m.AwayExtra},
+	}
+	for _, side := range sides {
+		squad := squads[side.TeamID]
+		starters := len(squad)
+		if starters > startingPlayers {
+			starters = startingPlayers
+		}
+		onPitch[side.TeamID] = append([]models.Player{}, squad[:starters]...)
+		bench[side.TeamID] = append([]models.Player{}, squad[starters:]...)
+
+		substitutions := len(bench[side.TeamID])
+		if substitutions > maxSubstitutions {
+			substitutions = maxSubstitutions
+		}
+
+		add(side.Goals, 1, 90, models.EventGoal, side.TeamID)
+		add(side.Extra, 91, 120, models.EventGoal, side.TeamID)
+		add(samplePoisson(rng, yellowCardsPerMatch), 1, length, models.EventYellowCard, side.TeamID)
+		add(samplePoisson(rng, redCardsPerMatch), 1, length, models.EventRedCard, side.TeamID)
+		add(substitutions, 46, 89, models.EventSubstitution, side.TeamID)
+	}
+
+	pending = append(pending, pendingEvent{Minute: 45, Type: models.EventHalfTime}, pendingEvent{Minute: 90, Type: models.EventFullTime})
+	if m.ExtraTime {
+		pending = append(pending, pendingEvent{Minute: 120, Type: models.EventExtraTime})
+	}
+
+	// Breaks in play come after everything else that happened in their minute
+	sort.SliceStable(pending, func(i, j int) bool {
+		if pending[i].Minute != pending[j].Minute {
+			return pending[i].Minute < pending[j].Minute
+		}
+		return pending[i].TeamID != 0 && pending[j].TeamID == 0
+	})
+
+	var timeline matchTimeline
+	booked := make(map[int]bool)
+	homeScore, awayScore := 0, 0
+	for _, p := range pending {
+		event := models.MatchEvent{Minute: p.Minute, Type: p.Type}
+		if p.TeamID != 0 {
+			teamID := p.TeamID
+			event.TeamID = &teamID
+		}
+
+		switch p.Type {
+		case models.EventGoal:
+			if p.TeamID == m.HomeTeamID {
+				homeScore++
+			} else {
+				awayScore++
+			}
+			if credits := creditGoals(rng, p.TeamID, onPitch[p.TeamID], 1); len(credits) > 0 {
+				credit := credits[0]
+				timeline.Credits = append(timeline.Credits, credit)
+				event.PlayerID = &credit.ScorerID
+				event.RelatedPlayerID = credit.AssistID
+			}
+
+		case models.EventYellowCard, models.EventRedCard:
+			players := onPitch[p.TeamID]
+			if len(players) > 0 {
+				i := rng.Intn(len(players))
+				playerID := players[i].ID
+				event.PlayerID = &playerID
+				if p.Type == models.EventYellowCard && booked[playerID] {
+					event.Type = models.EventRedCard
+				}
+				booked[playerID] = true
+				if event.Type == models.EventRedCard {
+					onPitch[p.TeamID] = append(players[:i:i], players[i+1:]...)
+				}
+			}
+			timeline.countCard(p.TeamID == m.HomeTeamID, event.Type)
+
+		case models.EventSubstitution:
+			off, on, ok := pickSubstitution(rng, onPitch[p.TeamID], bench[p.TeamID])
+			if !ok {
+				continue
+			}
+			offID, onID := onPitch[p.TeamID][off].ID, bench[p.TeamID][on].ID
+			event.PlayerID = &offID
+			event.RelatedPlayerID = &onID
+			onPitch[p.TeamID][off] = bench[p.TeamID][on]
+			bench[p.TeamID] = append(bench[p.TeamID][:on:on], bench[p.TeamID][on+1:]...)
+		}
+
+		event.HomeScore, event.AwayScore = homeScore, awayScore
+		event.Sequence = len(timeline.Events) + 1
+		timeline.Events = append(timeline.Events, event)
+	}
+
+	if m.HomePenalties != nil && m.AwayPenalties != nil {
+		timeline.Events = append(timeline.Events, models.MatchEvent{
+			Sequence:  len(timeline.Events) + 1,
+			Minute:    length,
+			Type:      models.EventPenaltyShootout,
+			HomeScore: *m.HomePenalties,
+			AwayScore: *m.AwayPenalties,
+		})
+	}
+
+	return timeline
+}
+
+// countCard adds a card shown to the home or away side to the match totals
+func (t *matchTimeline) countCard(home bool, eventType string) {
+	switch {
+	case home && eventType == models.EventYellowCard:
+		t.HomeYellowCards++
+	case home:
+		t.HomeRedCards++
+	case eventType == models.EventYellowCard:
+		t.AwayYellowCards++
+	default:
+		t.AwayRedCards++
+	}
+}
+
+// pickSubstitution picks an outfield player to come off and the player from
+// the bench to replace them, preferring one who plays the same position. It
+// returns their indexes in onPitch and bench, and false when no change can be
+// made.
+func pickSubstitution(rng *rand.Rand, onPitch, bench []models.Player) (int, int, bool) {
+	var outfield []int
+	for i, player := range onPitch {
+		if player.Position != models.PositionGoalkeeper {
+			outfield = append(outfield, i)
+		}
+	}
+	if len(outfield) == 0 || len(bench) == 0 {
+		return 0, 0, false
+	}
+
+	off := outfield[rng.Intn(len(outfield))]
+	var samePosition, otherOutfield []int
+	for i, player := range bench {
+		switch player.Position {
+		case onPitch[off].Position:
+			samePosition = append(samePosition, i)
+		case models.PositionGoalkeeper:
+		default:
+			otherOutfield = append(otherOutfield, i)
+		}
+	}
+	for _, candidates := range [][]int{samePosition, otherOutfield} {
+		if len(candidates) > 0 {
+			return off, candidates[rng.Intn(len(candidates))], true
+		}
+	}
+	return 0, 0, false
+}
+
+// storeTimeline replaces the events and credited goals of a match
+func storeTimeline(tx *sql.Tx, matchID int, timeline matchTimeline) error {
+	if err := clearTimeline(tx, matchID); err != nil {
+		return err
+	}
+
+	for _, event := range timeline.Events {
+		_, err := tx.Exec(`
+			INSERT INTO match_events (match_id, sequence, minute, event_type, team_id, player_id, related_player_id, home_score, away_score)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, matchID, event.Sequence, event.Minute, event.Type, event.TeamID, event.PlayerID, event.RelatedPlayerID, event.HomeScore, event.AwayScore)
+		if err != nil {
+			return fmt.Errorf("failed to store event of match %d: %v", matchID, err)
+		}
+	}
+
+	return storeGoals(tx, matchID, timeline.Credits)
+}
+
+// clearTimeline removes the events of a match, whose result no longer matches them
+func clearTimeline(tx *sql.Tx, matchID int) error {
+	if _, err := tx.Exec("DELETE FROM match_events WHERE match_id = $1", matchID); err != nil {
+		return fmt.Errorf("failed to clear events of match %d: %v", matchID, err)
+	}
+	return nil
+}
+
+// loadMatchEvents returns the events of a match in the order they happened,
+// with the names of the players taking part
+func loadMatchEvents(q querier, matchID int) ([]models.MatchEvent, error) {
+	rows, err := q.Query(`
+		SELECT e.id, e.match_id, e.sequence, e.minute, e.event_type, e.team_id,
+		       e.player_id, COALESCE(p.name, ''), e.related_player_id, COALESCE(rp.name, ''),
+		       e.home_score, e.away_score
+		FROM match_events e
+		LEFT JOIN players p ON e.player_id = p.id
+		LEFT JOIN players rp ON e.related_player_id = rp.id
+		WHERE e.match_id = $1
+		ORDER BY e.sequence
+	`, matchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.MatchEvent{}
+	for rows.Next() {
+		var event models.MatchEvent
+		err := rows.Scan(
+			&event.ID, &event.MatchID, &event.Sequence, &event.Minute, &event.Type, &event.TeamID,
+			&event.PlayerID, &event.PlayerName, &event.RelatedPlayerID, &event.RelatedPlayerName,
+			&event.HomeScore, &event.AwayScore,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,474 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// maxGroups is the number of groups a tournament can have, one per letter
+const maxGroups = 26
+
+// maxDrawSteps bounds the search for a group draw that keeps every country
+// apart, so an impossible draw is reported instead of searched for ever
+const maxDrawSteps = 100000
+
+// Errors returned when a tournament cannot move on to its next stage yet
+var (
+	errGroupsDrawn           = errors.New("the groups have already been drawn")
+	errGroupsNotDrawn        = errors.New("the groups have not been drawn yet")
+	errGroupStageUnfinished  = errors.New("the group stage has not been played yet")
+	errCountryProtection     = errors.New("no draw keeps the teams of each country in different groups")
+	errTooFewTournamentTeams = errors.New("every group needs more teams than it sends into the knockout")
+)
+
+// groupName names the group with the given index: A, B, C, ...
+func groupName(index int) string {
+	return string(rune('A' + index))
+}
+
+// makePots ranks the teams by their ratings and splits them into pots of one
+// team per group, strongest first. The last pot is short when the teams do not
+// divide evenly into the groups.
+func makePots(teams []models.Team, groupCount int) [][]models.Team {
+	ranked := append([]models.Team{}, teams...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a := ranked[i].AttackRating * ranked[i].DefenceRating
+		b := ranked[j].AttackRating * ranked[j].DefenceRating
+		if a != b {
+			return a > b
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+
+	var pots [][]models.Team
+	for start := 0; start < len(ranked); start += groupCount {
+		end := start + groupCount
+		if end > len(ranked) {
+			end = len(ranked)
+		}
+		pots = append(pots, ranked[start:end])
+	}
+	return pots
+}
+
+// drawGroups draws every pot into the groups, one team per group from each
+// pot, so that no group holds two teams from the same country. Teams without a
+// country can be drawn anywhere. The teams of each pot are drawn in a random
+// order and each goes into the first group that still leaves a valid draw for
+// the teams after it, as in the UEFA draws. It reports false when no such draw
+// exists.
+func drawGroups(pots [][]models.Team, groupCount int, rng *rand.Rand) ([][]models.Team, bool) {
+	var order []models.Team
+	var potOf []int
+	for p, pot := range pots {
+		drawn := append([]models.Team{}, pot...)
+		rng.Shuffle(len(drawn), func(i, j int) {
+			drawn[i], drawn[j] = drawn[j], drawn[i]
+		})
+		for _, team := range drawn {
+			order = append(order, team)
+			potOf = append(potOf, p)
+		}
+	}
+
+	groups := make([][]models.Team, groupCount)
+	filled := make([]int, groupCount) // number of pots each group has a team from
+	steps := 0
+
+	var place func(i int) bool
+	place = func(i int) bool {
+		if i == len(order) {
+			return true
+		}
+		steps++
+		if steps > maxDrawSteps {
+			return false
+		}
+
+		team := order[i]
+		for g := range groups {
+			if filled[g] != potOf[i] || clashesWith(groups[g], team) {
+				continue
+			}
+
+			groups[g] = append(groups[g], team)
+			filled[g]++
+			if place(i + 1) {
+				return true
+			}
+			groups[g] = groups[g][:len(groups[g])-1]
+			filled[g]--
+		}
+		return false
+	}
+
+	if !place(0) {
+		return nil, false
+	}
+	return groups, true
+}
+
+// clashesWith reports whether a group already holds a team from the team's country
+func clashesWith(group []models.Team, team models.Team) bool {
+	if team.Country == "" {
+		return false
+	}
+	for _, other := range group {
+		if other.Country == team.Country {
+			return true
+		}
+	}
+	return false
+}
+
+// groupQualifier is a team that went through the group stage
+type groupQualifier struct {
+	Group   string
+	Country string
+	Stats   models.TeamStats
+}
+
+// seedKnockout pairs the qualifiers for the first knockout round. They are
+// ranked by group position, then points, goal difference and goals scored;
+// the top half is seeded and each seeded team is drawn against an unseeded
+// team from another group and country. When no draw keeps every country apart
+// only the groups are kept apart, and failing that the pairing is free. The
+// seeded team comes second in each pair so it hosts the second leg.
+func seedKnockout(qualifiers []groupQualifier, rng *rand.Rand) [][2]int {
+	ranked := append([]groupQualifier{}, qualifiers...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i].Stats, ranked[j].Stats
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.GoalDifference != b.GoalDifference {
+			return a.GoalDifference > b.GoalDifference
+		}
+		if a.GoalsFor != b.GoalsFor {
+			return a.GoalsFor > b.GoalsFor
+		}
+		return a.Team.ID < b.Team.ID
+	})
+
+	half := len(ranked) / 2
+	seeded := ranked[:half]
+	unseeded := append([]groupQualifier{}, ranked[half:]...)
+	rng.Shuffle(len(seeded), func(i, j int) {
+		seeded[i], seeded[j] = seeded[j], seeded[i]
+	})
+	rng.Shuffle(len(unseeded), func(i, j int) {
+		unseeded[i], unseeded[j] = unseeded[j], unseeded[i]
+	})
+
+	for _, protectCountries := range []bool{true, false} {
+		for _, protectGroups := range []bool{true, false} {
+			allowed := func(a, b groupQualifier) bool {
+				if protectGroups && a.Group == b.Group {
+					return false
+				}
+				return !protectCountries || a.Country == "" || a.Country != b.Country
+			}
+			if pairs, ok := pairQualifiers(seeded, unseeded, allowed); ok {
+				return pairs
+			}
+		}
+	}
+	return nil
+}
+
+// pairQualifiers matches every seeded team with an unseeded one that allowed
+// accepts, searching depth first in the given order
+func pairQualifiers(seeded, unseeded []groupQualifier, allowed func(a, b groupQualifier) bool) ([][2]int, bool) {
+	used := make([]bool, len(unseeded))
+	pairs := make([][2]int, len(seeded))
+
+	var match func(i int) bool
+	match = func(i int) bool {
+		if i == len(seeded) {
+			return true
+		}
+		for j, opponent := range unseeded {
+			if used[j] || !allowed(seeded[i], opponent) {
+				continue
+			}
+			used[j] = true
+			pairs[i] = [2]int{opponent.Stats.Team.ID, seeded[i].Stats.Team.ID}
+			if match(i + 1) {
+				return true
+			}
+			used[j] = false
+		}
+		return false
+	}
+
+	return pairs, match(0)
+}
+
+// loadTournamentEntrants returns the teams entered in a tournament season with
+// their country and ratings
+func loadTournamentEntrants(q querier, seasonID int) ([]models.Team, error) {
+	rows, err := q.Query(`
+		SELECT t.id, t.name, COALESCE(t.short_code, ''), COALESCE(t.country, ''), t.attack_rating, t.defence_rating
+		FROM league_table lt
+		JOIN teams t ON lt.team_id = t.id
+		WHERE lt.season_id = $1
+		ORDER BY t.id
+	`, seasonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teams []models.Team
+	for rows.Next() {
+		var team models.Team
+		err := rows.Scan(&team.ID, &team.Name, &team.ShortCode, &team.Country, &team.AttackRating, &team.DefenceRating)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+
+	return teams, rows.Err()
+}
+
+// drawTournamentGroups draws the entrants of a tournament season into groups
+// and generates a round-robin in every group, inside the given transaction.
+// All groups play their n-th round in the same week.
+func drawTournamentGroups(tx *sql.Tx, season models.Season, competition models.Competition, rng *rand.Rand) error {
+	var drawn int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM tournament_groups WHERE season_id = $1", season.ID).Scan(&drawn); err != nil {
+		return err
+	}
+	if drawn > 0 {
+		return errGroupsDrawn
+	}
+
+	teams, err := loadTournamentEntrants(tx, season.ID)
+	if err != nil {
+		return err
+	}
+	if len(teams)/competition.GroupCount <= competition.GroupQualifiers {
+		return errTooFewTournamentTeams
+	}
+
+	groups, ok := drawGroups(makePots(teams, competition.GroupCount), competition.GroupCount, rng)
+	if !ok {
+		return errCountryProtection
+	}
+
+	for g, group := range groups {
+		var ids []int
+		for pot, team := range group {
+			_, err := tx.Exec(
+				"INSERT INTO tournament_groups (season_id, team_id, group_name, pot) VALUES ($1, $2, $3, $4)",
+				season.ID, team.ID, groupName(g), pot+1,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to store group entry: %v", err)
+			}
+			ids = append(ids, team.ID)
+		}
+
+		for _, fixture := range roundRobinFixtures(ids) {
+			_, err := tx.Exec(
+				"INSERT INTO matches (season_id, home_team_id, away_team_id, week, played) VALUES ($1, $2, $3, $4, false)",
+				season.ID, fixture.HomeTeamID, fixture.AwayTeamID, fixture.Week,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to store group match: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// groupStageWeeks returns the number of weeks of a tournament season's group
+// stage, which are the weeks of every match that is not a knockout leg
+func groupStageWeeks(q querier, seasonID int) (int, error) {
+	var weeks int
+	err := q.QueryRow(`
+		SELECT COALESCE(MAX(m.week), 0)
+		FROM matches m
+		WHERE m.season_id = $1
+		AND NOT EXISTS (
+			SELECT 1 FROM cup_ties ct WHERE ct.first_leg_id = m.id OR ct.second_leg_id = m.id
+		)
+	`, seasonID).Scan(&weeks)
+	return weeks, err
+}
+
+// nextGroupWeek returns the first group stage week with unplayed matches, or 0
+// once the group stage is over
+func nextGroupWeek(q querier, seasonID int) (int, error) {
+	weeks, err := groupStageWeeks(q, seasonID)
+	if err != nil {
+		return 0, err
+	}
+
+	var week int
+	err = q.QueryRow(
+		"SELECT COALESCE(MIN(week), 0) FROM matches WHERE season_id = $1 AND played = false AND week <= $2",
+		seasonID, weeks,
+	).Scan(&week)
+	return week, err
+}
+
+// loadTournamentGroups returns the groups of a tournament season, each ranked
+// from its group stage matches with the competition's tie-break chain
+func loadTournamentGroups(q querier, season models.Season, competition models.Competition) ([]models.TournamentGroup, error) {
+	rows, err := q.Query(`
+		SELECT tg.group_name, t.id, t.name
+		FROM tournament_groups tg
+		JOIN teams t ON tg.team_id = t.id
+		WHERE tg.season_id = $1
+		ORDER BY tg.group_name, tg.pot
+	`, season.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	members := make(map[string][]models.Team)
+	for rows.Next() {
+		var name string
+		var team models.Team
+		if err := rows.Scan(&name, &team.ID, &team.Name); err != nil {
+			return nil, err
+		}
+		if _, ok := members[name]; !ok {
+			names = append(names, name)
+		}
+		members[name] = append(members[name], team)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	weeks, err := groupStageWeeks(q, season.ID)
+	if err != nil {
+		return nil, err
+	}
+	results, err := loadResults(q, season.ID, weeks)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]models.TournamentGroup, 0, len(names))
+	for _, name := range names {
+		inGroup := make(map[int]bool)
+		for _, team := range members[name] {
+			inGroup[team.ID] = true
+		}
+		var groupResults []matchResult
+		for _, result := range results {
+			if inGroup[result.HomeTeamID] && inGroup[result.AwayTeamID] {
+				groupResults = append(groupResults, result)
+			}
+		}
+
+		standings := computeStandings(members[name], groupResults)
+		rankStandings(standings, groupResults, competition.TieBreakers, competition.LotsSeed)
+		groups = append(groups, models.TournamentGroup{Name: name, Standings: standings})
+	}
+
+	return groups, nil
+}
+
+// drawTournamentKnockout draws the next knockout round of a tournament season
+// inside the given transaction. The first round is drawn from the qualifiers of
+// a finished group stage with seedKnockout; later rounds follow the bracket, so
+// the winners of neighbouring ties meet. It returns the round drawn.
+func drawTournamentKnockout(tx *sql.Tx, season models.Season, competition models.Competition, rng *rand.Rand) (int, error) {
+	round, field, err := nextCupField(tx, season.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	var pairs [][2]int
+	if round == 0 {
+		groups, err := loadTournamentGroups(tx, season, competition)
+		if err != nil {
+			return 0, err
+		}
+		if len(groups) == 0 {
+			return 0, errGroupsNotDrawn
+		}
+
+		week, err := nextGroupWeek(tx, season.ID)
+		if err != nil {
+			return 0, err
+		}
+		if week != 0 {
+			return 0, errGroupStageUnfinished
+		}
+
+		countries, err := loadTournamentEntrants(tx, season.ID)
+		if err != nil {
+			return 0, err
+		}
+		countryOf := make(map[int]string, len(countries))
+		for _, team := range countries {
+			countryOf[team.ID] = team.Country
+		}
+
+		var qualifiers []groupQualifier
+		for _, group := range groups {
+			for _, stats := range group.Standings[:competition.GroupQualifiers] {
+				qualifiers = append(qualifiers, groupQualifier{
+					Group:   group.Name,
+					Country: countryOf[stats.Team.ID],
+					Stats:   stats,
+				})
+			}
+		}
+		pairs = seedKnockout(qualifiers, rng)
+		if !competition.TwoLegged {
+			// In a single match the seeded team hosts
+			for i := range pairs {
+				pairs[i][0], pairs[i][1] = pairs[i][1], pairs[i][0]
+			}
+		}
+	} else {
+		for i := 0; i+1 < len(field); i += 2 {
+			pairs = append(pairs, [2]int{field[i], field[i+1]})
+		}
+	}
+
+	round++
+	if err := insertCupRound(tx, season.ID, competition.TwoLegged, round, nil, pairs); err != nil {
+		return 0, err
+	}
+	return round, nil
+}
+
+// loadTournament builds the groups and knockout bracket of a tournament season
+func loadTournament(q querier, competition models.Competition, season models.Season) (models.Tournament, error) {
+	tournament := models.Tournament{Competition: competition, Season: season}
+
+	groups, err := loadTournamentGroups(q, season, competition)
+	if err != nil {
+		return tournament, err
+	}
+	tournament.Groups = groups
+
+	bracket, err := loadCupBracket(q, competition, season)
+	if err != nil {
+		return tournament, err
+	}
+	tournament.Knockout = bracket.Rounds
+	tournament.Champion = bracket.Champion
+
+	return tournament, nil
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"math/rand"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/config"
+	"github.com/sametyildirim314/insider_case/database"
+	"github.com/sametyildirim314/insider_case/models"
+)
+
+// GetTournament handles the request to view a tournament's current edition,
+// or the tournament season given in the route: the ranked groups and every
+// knockout round drawn so far
+func GetTournament(c *fiber.Ctx) error {
+	competition, season, status, msg := requestCompetitionSeason(c, models.FormatTournament)
+	if msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+
+	tournament, err := loadTournament(database.DB, competition, season)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get tournament: " + err.Error(),
+		})
+	}
+
+	return c.JSON(tournament)
+}
+
+// DrawTournament handles the request to make a tournament's next draw. The
+// first draw puts the teams into groups from pots ranked by their ratings,
+// keeping teams from the same country apart, and generates the group
+// fixtures. Once the group stage is over the next draw seeds the qualifiers
+// into the knockout, and every draw after that pairs the winners of
+// neighbouring ties. The draw is random unless a seed is given with ?seed=.
+func DrawTournament(c *fiber.Ctx) error {
+	seed, err := simulationSeed(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid seed: " + err.Error(),
+		})
+	}
+
+	competition, season, status, msg := requestCompetitionSeason(c, models.FormatTournament)
+	if msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	if season.Status != models.SeasonActive {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Season " + season.Name + " is archived and cannot be drawn",
+		})
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to start transaction: " + err.Error(),
+		})
+	}
+	defer tx.Rollback()
+
+	// Lock the season so two draws cannot interleave
+	if _, err := tx.Exec("SELECT id FROM seasons WHERE id = $1 FOR UPDATE", season.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to lock season: " + err.Error(),
+		})
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	message := "Successfully drew the groups"
+
+	err = drawTournamentGroups(tx, season, competition, rng)
+	if err == errGroupsDrawn {
+		var round int
+		round, err = drawTournamentKnockout(tx, season, competition, rng)
+		message = "Successfully drew knockout round " + strconv.Itoa(round)
+	}
+	switch err {
+	case nil:
+	case errGroupsNotDrawn, errGroupStageUnfinished, errCupRoundPending, errCupFinished, errCountryProtection, errTooFewTournamentTeams:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Failed to make the draw: " + err.Error(),
+		})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to make the draw: " + err.Error(),
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to commit transaction: " + err.Error(),
+		})
+	}
+
+	tournament, err := loadTournament(database.DB, competition, season)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get tournament after the draw",
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":    message,
+		"seed":       seed,
+		"tournament": tournament,
+	})
+}
+
+// SimulateTournament handles the request to play the next stage of a
+// tournament: the next week of the group stage while group matches are left,
+// otherwise every tie of the knockout round drawn last
+func SimulateTournament(c *fiber.Ctx) error {
+	seed, err := simulationSeed(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid seed: " + err.Error(),
+		})
+	}
+
+	competition, season, status, msg := requestCompetitionSeason(c, models.FormatTournament)
+	if msg != "" {
+		return c.Status(status).JSON(fiber.Map{
+			"error": msg,
+		})
+	}
+	if season.Status != models.SeasonActive {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Season " + season.Name + " is archived and cannot be simulated",
+		})
+	}
+
+	week, err := nextGroupWeek(database.DB, season.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get the group stage: " + err.Error(),
+		})
+	}
+
+	var message string
+	if week != 0 {
+		fixtures, err := loadUnplayedFixtures(season.ID, week)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to get matches for week " + strconv.Itoa(week) + ": " + err.Error(),
+			})
+		}
+		if err := playMatches(season.ID, fixtures, seed); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to simulate week " + strconv.Itoa(week) + ": " + err.Error(),
+			})
+		}
+		message = "Successfully simulated week " + strconv.Itoa(week) + " of the group stage"
+	} else {
+		strengths, err := loadTeamStrengths()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load team ratings: " + err.Error(),
+			})
+		}
+
+		tx, err := database.DB.Begin()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to start transaction: " + err.Error(),
+			})
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec("SELECT id FROM seasons WHERE id = $1 FOR UPDATE", season.ID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to lock season: " + err.Error(),
+			})
+		}
+
+		round, err := playCupRound(tx, season, competition, seed, strengths, config.GetConfig())
+		if err == errCupNotDrawn {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Failed to simulate round: " + err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to simulate round: " + err.Error(),
+			})
+		}
+
+		if err := tx.Commit(); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to commit transaction: " + err.Error(),
+			})
+		}
+		message = "Successfully simulated knockout round " + strconv.Itoa(round)
+	}
+
+	tournament, err := loadTournament(database.DB, competition, season)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get tournament after simulation",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":    message,
+		"seed":       seed,
+		"tournament": tournament,
+	})
+}
@@ -1,9 +1,28 @@
 -- Teams table
 CREATE TABLE IF NOT EXISTS teams (
     id SERIAL PRIMARY KEY,
-    name VARCHAR(100) NOT NULL
+    name VARCHAR(100) NOT NULL,
+    short_code VARCHAR(5),
+    attack_rating DECIMAL(4,2) NOT NULL DEFAULT 1.00,
+    defence_rating DECIMAL(4,2) NOT NULL DEFAULT 1.00
 );
 
+-- Ratings used by the match engine (added after the first release)
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS attack_rating DECIMAL(4,2) NOT NULL DEFAULT 1.00;
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS defence_rating DECIMAL(4,2) NOT NULL DEFAULT 1.00;
+
+-- Short codes and uniqueness (added with the team management API)
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS short_code VARCHAR(5);
+UPDATE teams SET short_code = 'MUN' WHERE name = 'Manchester United' AND short_code IS NULL;
+UPDATE teams SET short_code = 'LIV' WHERE name = 'Liverpool' AND short_code IS NULL;
+UPDATE teams SET short_code = 'CHE' WHERE name = 'Chelsea' AND short_code IS NULL;
+UPDATE teams SET short_code = 'ARS' WHERE name = 'Arsenal' AND short_code IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS teams_name_unique ON teams (LOWER(name));
+CREATE UNIQUE INDEX IF NOT EXISTS teams_short_code_unique ON teams (short_code);
+
+-- Country of a team, used to keep teams from the same country apart in tournament draws
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS country VARCHAR(60);
+
 -- Matches table
 CREATE TABLE IF NOT EXISTS matches (
     id SERIAL PRIMARY KEY,
@@ -13,13 +32,66 @@ CREATE TABLE IF NOT EXISTS matches (
     away_score INTEGER,
     week INTEGER NOT NULL,
     played BOOLEAN DEFAULT false,
+    seed BIGINT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Random seed of the simulation that produced the result (added for reproducible runs)
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS seed BIGINT;
+
+-- Cards shown in the match, used by the fair play tie-breaker
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS home_yellow_cards INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS home_red_cards INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS away_yellow_cards INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS away_red_cards INTEGER NOT NULL DEFAULT 0;
+
+-- Cup matches can go to extra time (included in the score) and penalties
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS extra_time BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS home_penalties INTEGER;
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS away_penalties INTEGER;
+
+-- Competitions and the tie-break chain used to rank their tables
+CREATE TABLE IF NOT EXISTS competitions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    tie_breakers TEXT NOT NULL DEFAULT 'goal_difference,goals_for,head_to_head_points,head_to_head_goal_difference,away_goals,wins,fair_play,drawing_of_lots',
+    lots_seed BIGINT NOT NULL DEFAULT (FLOOR(RANDOM() * 1000000000))::BIGINT,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+INSERT INTO competitions (name)
+SELECT 'Premier League' WHERE NOT EXISTS (SELECT 1 FROM competitions);
+
+-- League competitions are divisions of a pyramid, ordered by tier (1 is the top).
+-- At season rollover the bottom relegation_places teams of a division swap with
+-- the top promotion_places teams of the division below, plus the winner of a
+-- playoff between the next playoff_places teams of the lower division.
+ALTER TABLE competitions ADD COLUMN IF NOT EXISTS tier INTEGER;
+ALTER TABLE competitions ADD COLUMN IF NOT EXISTS promotion_places INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE competitions ADD COLUMN IF NOT EXISTS relegation_places INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE competitions ADD COLUMN IF NOT EXISTS playoff_places INTEGER NOT NULL DEFAULT 0;
+
+UPDATE competitions SET tier = 1
+WHERE id = (SELECT MIN(id) FROM competitions)
+AND NOT EXISTS (SELECT 1 FROM competitions WHERE tier IS NOT NULL);
+
+CREATE UNIQUE INDEX IF NOT EXISTS competitions_tier_unique ON competitions (tier);
+
+-- Competitions are either league divisions or knockout cups. Cups have no tier;
+-- their ties are single matches or two legs, optionally with the away goals rule.
+ALTER TABLE competitions ADD COLUMN IF NOT EXISTS format VARCHAR(20) NOT NULL DEFAULT 'league';
+ALTER TABLE competitions ADD COLUMN IF NOT EXISTS two_legged BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE competitions ADD COLUMN IF NOT EXISTS away_goals_rule BOOLEAN NOT NULL DEFAULT false;
+
+-- Tournaments play a group stage first and send the top group_qualifiers teams
+-- of each of the group_count groups into a knockout
+ALTER TABLE competitions ADD COLUMN IF NOT EXISTS group_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE competitions ADD COLUMN IF NOT EXISTS group_qualifiers INTEGER NOT NULL DEFAULT 0;
+
 -- League table
 CREATE TABLE IF NOT EXISTS league_table (
     id SERIAL PRIMARY KEY,
-    team_id INTEGER REFERENCES teams(id) UNIQUE,
+    team_id INTEGER REFERENCES teams(id),
     points INTEGER DEFAULT 0,
     played INTEGER DEFAULT 0,
     wins INTEGER DEFAULT 0,
@@ -38,22 +110,285 @@ CREATE TABLE IF NOT EXISTS predictions (
     predicted_position INTEGER NOT NULL,
     predicted_points INTEGER NOT NULL,
     prediction_percentage DECIMAL(5,2),
+    expected_points DECIMAL(6,2),
+    points_lower INTEGER,
+    points_upper INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Points confidence interval of each prediction (added with the position matrix)
+ALTER TABLE predictions ADD COLUMN IF NOT EXISTS expected_points DECIMAL(6,2);
+ALTER TABLE predictions ADD COLUMN IF NOT EXISTS points_lower INTEGER;
+ALTER TABLE predictions ADD COLUMN IF NOT EXISTS points_upper INTEGER;
+
+-- Probability of each predicted team finishing in every league position
+CREATE TABLE IF NOT EXISTS prediction_positions (
+    id SERIAL PRIMARY KEY,
+    prediction_id INTEGER REFERENCES predictions(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    probability DECIMAL(5,2) NOT NULL,
+    UNIQUE (prediction_id, position)
+);
+
+-- Seasons: every match, league table row and prediction belongs to one.
+-- Only the active season of a competition can change; archived seasons are read-only.
+CREATE TABLE IF NOT EXISTS seasons (
+    id SERIAL PRIMARY KEY,
+    competition_id INTEGER REFERENCES competitions(id),
+    name VARCHAR(100) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    archived_at TIMESTAMP
+);
+
+INSERT INTO seasons (competition_id, name)
+SELECT id, 'Season 1' FROM competitions
+WHERE NOT EXISTS (SELECT 1 FROM seasons)
+ORDER BY id LIMIT 1;
+
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS season_id INTEGER REFERENCES seasons(id);
+ALTER TABLE league_table ADD COLUMN IF NOT EXISTS season_id INTEGER REFERENCES seasons(id);
+ALTER TABLE predictions ADD COLUMN IF NOT EXISTS season_id INTEGER REFERENCES seasons(id);
+
+-- Rows written before seasons existed belong to the first season
+UPDATE matches SET season_id = (SELECT MIN(id) FROM seasons) WHERE season_id IS NULL;
+UPDATE league_table SET season_id = (SELECT MIN(id) FROM seasons) WHERE season_id IS NULL;
+UPDATE predictions SET season_id = (SELECT MIN(id) FROM seasons) WHERE season_id IS NULL;
+
+-- A team has one league table row per season instead of one overall
+ALTER TABLE league_table DROP CONSTRAINT IF EXISTS league_table_team_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS league_table_season_team_unique ON league_table (season_id, team_id);
+CREATE INDEX IF NOT EXISTS matches_season_week_idx ON matches (season_id, week);
+
+-- Promotions and relegations made when a new season was started
+CREATE TABLE IF NOT EXISTS team_movements (
+    id SERIAL PRIMARY KEY,
+    team_id INTEGER REFERENCES teams(id),
+    from_season_id INTEGER REFERENCES seasons(id),
+    to_season_id INTEGER REFERENCES seasons(id),
+    movement VARCHAR(20) NOT NULL,
+    via_playoff BOOLEAN NOT NULL DEFAULT false,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
--- Insert default teams if they don't exist
-INSERT INTO teams (name)
-SELECT 'Manchester United' WHERE NOT EXISTS (SELECT 1 FROM teams WHERE name = 'Manchester United');
+-- Cup ties: one row per pairing of a knockout round. The legs are stored in
+-- matches; away_team_id is NULL when the home team was drawn a bye.
+CREATE TABLE IF NOT EXISTS cup_ties (
+    id SERIAL PRIMARY KEY,
+    season_id INTEGER REFERENCES seasons(id),
+    round INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    home_team_id INTEGER REFERENCES teams(id),
+    away_team_id INTEGER REFERENCES teams(id),
+    first_leg_id INTEGER REFERENCES matches(id),
+    second_leg_id INTEGER REFERENCES matches(id),
+    winner_id INTEGER REFERENCES teams(id),
+    decided_by VARCHAR(20),
+    UNIQUE (season_id, round, position)
+);
+
+-- Tournament groups: the group each team was drawn into and the pot it was drawn from
+CREATE TABLE IF NOT EXISTS tournament_groups (
+    id SERIAL PRIMARY KEY,
+    season_id INTEGER REFERENCES seasons(id),
+    team_id INTEGER REFERENCES teams(id),
+    group_name VARCHAR(2) NOT NULL,
+    pot INTEGER NOT NULL,
+    UNIQUE (season_id, team_id)
+);
+
+-- Players: the squad of each team
+CREATE TABLE IF NOT EXISTS players (
+    id SERIAL PRIMARY KEY,
+    team_id INTEGER NOT NULL REFERENCES teams(id),
+    name VARCHAR(100) NOT NULL,
+    position VARCHAR(3) NOT NULL,
+    rating DECIMAL(4,2) NOT NULL DEFAULT 1.00,
+    shirt_number INTEGER NOT NULL,
+    UNIQUE (team_id, shirt_number)
+);
+
+-- Goals of a match with their scorer and optional assister. The goals go with
+-- the match when it is deleted.
+CREATE TABLE IF NOT EXISTS match_goals (
+    id SERIAL PRIMARY KEY,
+    match_id INTEGER NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+    team_id INTEGER NOT NULL REFERENCES teams(id),
+    scorer_id INTEGER NOT NULL REFERENCES players(id),
+    assist_id INTEGER REFERENCES players(id)
+);
+
+CREATE INDEX IF NOT EXISTS match_goals_match_id ON match_goals (match_id);
+
+-- Timeline of a simulated match: goals, cards, substitutions and breaks in play
+-- in the order they happened, with the score after each event
+CREATE TABLE IF NOT EXISTS match_events (
+    id SERIAL PRIMARY KEY,
+    match_id INTEGER NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+    sequence INTEGER NOT NULL,
+    minute INTEGER NOT NULL,
+    event_type VARCHAR(20) NOT NULL,
+    team_id INTEGER REFERENCES teams(id),
+    player_id INTEGER REFERENCES players(id) ON DELETE SET NULL,
+    related_player_id INTEGER REFERENCES players(id) ON DELETE SET NULL,
+    home_score INTEGER NOT NULL,
+    away_score INTEGER NOT NULL,
+    UNIQUE (match_id, sequence)
+);
+
+-- Elo rating of every team, updated after each played match, and the order in
+-- which the matches were played, which the ratings depend on
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS elo_rating DECIMAL(7,2) NOT NULL DEFAULT 1500.00;
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS played_at TIMESTAMP;
+
+-- Each team's Elo rating before and after every match it played
+CREATE TABLE IF NOT EXISTS team_ratings_history (
+    id SERIAL PRIMARY KEY,
+    team_id INTEGER NOT NULL REFERENCES teams(id),
+    match_id INTEGER NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+    rating_before DECIMAL(7,2) NOT NULL,
+    rating DECIMAL(7,2) NOT NULL,
+    UNIQUE (team_id, match_id)
+);
+
+-- Injuries and suspensions, each caused by a match, keeping a player, or a
+-- share of a team without a squad, out of the weeks from_week to until_week
+CREATE TABLE IF NOT EXISTS absences (
+    id SERIAL PRIMARY KEY,
+    season_id INTEGER NOT NULL REFERENCES seasons(id),
+    team_id INTEGER NOT NULL REFERENCES teams(id),
+    player_id INTEGER REFERENCES players(id) ON DELETE CASCADE,
+    match_id INTEGER NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+    reason VARCHAR(20) NOT NULL,
+    detail VARCHAR(100) NOT NULL,
+    from_week INTEGER NOT NULL,
+    until_week INTEGER NOT NULL,
+    strength_loss DECIMAL(5,3) NOT NULL
+);
+
+-- What-if scenarios: hypothetical results laid over a season in memory,
+-- never written to the matches or league table
+CREATE TABLE IF NOT EXISTS scenarios (
+    id SERIAL PRIMARY KEY,
+    season_id INTEGER NOT NULL REFERENCES seasons(id),
+    name VARCHAR(100) NOT NULL,
+    seed BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS scenario_results (
+    id SERIAL PRIMARY KEY,
+    scenario_id INTEGER NOT NULL REFERENCES scenarios(id) ON DELETE CASCADE,
+    match_id INTEGER NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+    home_score INTEGER NOT NULL,
+    away_score INTEGER NOT NULL,
+    UNIQUE (scenario_id, match_id)
+);
+
+-- Every run of the prediction model, so earlier forecasts are kept. The
+-- predictions of a run hang off it; the latest run is the current forecast.
+CREATE TABLE IF NOT EXISTS prediction_runs (
+    id SERIAL PRIMARY KEY,
+    season_id INTEGER NOT NULL REFERENCES seasons(id),
+    week INTEGER NOT NULL,
+    model_version VARCHAR(40) NOT NULL,
+    simulations INTEGER NOT NULL DEFAULT 0,
+    seed BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE predictions ADD COLUMN IF NOT EXISTS run_id INTEGER REFERENCES prediction_runs(id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS predictions_run_idx ON predictions (run_id);
+
+-- Predictions stored before runs existed become one run per season, made
+-- after the last week played by then. This only happens while there are no
+-- runs yet, on the first start after the upgrade.
+WITH legacy AS (
+    INSERT INTO prediction_runs (season_id, week, model_version, created_at)
+    SELECT p.season_id,
+           COALESCE((SELECT MAX(m.week) FROM matches m WHERE m.season_id = p.season_id AND m.played), 0),
+           'unknown', COALESCE(MIN(p.created_at), CURRENT_TIMESTAMP)
+    FROM predictions p
+    WHERE p.run_id IS NULL AND p.season_id IS NOT NULL
+      AND NOT EXISTS (SELECT 1 FROM prediction_runs)
+    GROUP BY p.season_id
+    RETURNING id, season_id
+)
+UPDATE predictions p SET run_id = legacy.id
+FROM legacy
+WHERE p.season_id = legacy.season_id AND p.run_id IS NULL;
 
-INSERT INTO teams (name)
-SELECT 'Liverpool' WHERE NOT EXISTS (SELECT 1 FROM teams WHERE name = 'Liverpool');
+-- The match engine a competition plays with and its parameters as a JSON
+-- object; an empty engine uses MATCH_ENGINE. Every simulated match records
+-- the engine that decided it.
+ALTER TABLE competitions ADD COLUMN IF NOT EXISTS match_engine VARCHAR(30) NOT NULL DEFAULT '';
+ALTER TABLE competitions ADD COLUMN IF NOT EXISTS engine_params TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS engine VARCHAR(30);
 
-INSERT INTO teams (name)
-SELECT 'Chelsea' WHERE NOT EXISTS (SELECT 1 FROM teams WHERE name = 'Chelsea');
+-- Insert the default teams on a fresh database only, so teams removed
+-- through the API are not brought back on the next start
+INSERT INTO teams (name, short_code, attack_rating, defence_rating)
+SELECT * FROM (VALUES
+    ('Manchester United', 'MUN', 1.10, 1.05),
+    ('Liverpool', 'LIV', 1.30, 1.20),
+    ('Chelsea', 'CHE', 1.15, 1.10),
+    ('Arsenal', 'ARS', 1.35, 1.25)
+) AS defaults (name, short_code, attack_rating, defence_rating)
+WHERE NOT EXISTS (SELECT 1 FROM teams);
 
-INSERT INTO teams (name)
-SELECT 'Arsenal' WHERE NOT EXISTS (SELECT 1 FROM teams WHERE name = 'Arsenal');
+-- On a fresh database, enter every team in the first season
+INSERT INTO league_table (season_id, team_id)
+SELECT (SELECT MIN(id) FROM seasons), id FROM teams
+WHERE NOT EXISTS (SELECT 1 FROM league_table);
 
--- Create initial league table entries for each team if they don't exist
-INSERT INTO league_table (team_id)
-SELECT id FROM teams WHERE NOT EXISTS (SELECT 1 FROM league_table WHERE team_id = teams.id); 
\ No newline at end of file
+-- Give the default teams a starting eleven when the players table is new
+INSERT INTO players (team_id, name, position, rating, shirt_number)
+SELECT t.id, squad.name, squad.position, squad.rating, squad.shirt_number
+FROM (VALUES
+    ('MUN', 'André Onana', 'GK', 1.00, 24),
+    ('MUN', 'Diogo Dalot', 'DEF', 1.00, 20),
+    ('MUN', 'Raphaël Varane', 'DEF', 1.05, 19),
+    ('MUN', 'Lisandro Martínez', 'DEF', 1.05, 6),
+    ('MUN', 'Luke Shaw', 'DEF', 0.95, 23),
+    ('MUN', 'Casemiro', 'MID', 1.00, 18),
+    ('MUN', 'Kobbie Mainoo', 'MID', 0.95, 37),
+    ('MUN', 'Bruno Fernandes', 'MID', 1.25, 8),
+    ('MUN', 'Marcus Rashford', 'FWD', 1.10, 10),
+    ('MUN', 'Rasmus Højlund', 'FWD', 1.05, 11),
+    ('MUN', 'Alejandro Garnacho', 'FWD', 1.05, 17),
+    ('LIV', 'Alisson', 'GK', 1.20, 1),
+    ('LIV', 'Trent Alexander-Arnold', 'DEF', 1.25, 66),
+    ('LIV', 'Virgil van Dijk', 'DEF', 1.20, 4),
+    ('LIV', 'Ibrahima Konaté', 'DEF', 1.05, 5),
+    ('LIV', 'Andrew Robertson', 'DEF', 1.10, 26),
+    ('LIV', 'Alexis Mac Allister', 'MID', 1.15, 10),
+    ('LIV', 'Dominik Szoboszlai', 'MID', 1.10, 8),
+    ('LIV', 'Curtis Jones', 'MID', 1.00, 17),
+    ('LIV', 'Mohamed Salah', 'FWD', 1.40, 11),
+    ('LIV', 'Darwin Núñez', 'FWD', 1.10, 9),
+    ('LIV', 'Luis Díaz', 'FWD', 1.15, 7),
+    ('CHE', 'Robert Sánchez', 'GK', 0.95, 1),
+    ('CHE', 'Reece James', 'DEF', 1.10, 24),
+    ('CHE', 'Thiago Silva', 'DEF', 1.05, 6),
+    ('CHE', 'Levi Colwill', 'DEF', 1.00, 26),
+    ('CHE', 'Marc Cucurella', 'DEF', 0.95, 3),
+    ('CHE', 'Moisés Caicedo', 'MID', 1.05, 25),
+    ('CHE', 'Enzo Fernández', 'MID', 1.10, 8),
+    ('CHE', 'Cole Palmer', 'MID', 1.30, 20),
+    ('CHE', 'Raheem Sterling', 'FWD', 1.05, 7),
+    ('CHE', 'Nicolas Jackson', 'FWD', 1.05, 15),
+    ('CHE', 'Mykhailo Mudryk', 'FWD', 0.95, 10),
+    ('ARS', 'David Raya', 'GK', 1.10, 22),
+    ('ARS', 'Ben White', 'DEF', 1.10, 4),
+    ('ARS', 'William Saliba', 'DEF', 1.25, 2),
+    ('ARS', 'Gabriel Magalhães', 'DEF', 1.20, 6),
+    ('ARS', 'Oleksandr Zinchenko', 'DEF', 1.00, 35),
+    ('ARS', 'Declan Rice', 'MID', 1.25, 41),
+    ('ARS', 'Martin Ødegaard', 'MID', 1.30, 8),
+    ('ARS', 'Kai Havertz', 'MID', 1.10, 29),
+    ('ARS', 'Bukayo Saka', 'FWD', 1.35, 7),
+    ('ARS', 'Gabriel Jesus', 'FWD', 1.10, 9),
+    ('ARS', 'Gabriel Martinelli', 'FWD', 1.10, 11)
+) AS squad (short_code, name, position, rating, shirt_number)
+JOIN teams t ON t.short_code = squad.short_code
+WHERE NOT EXISTS (SELECT 1 FROM players);
@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"os"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -25,6 +26,11 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	
+	// Commands such as ./main backtest run and exit instead of serving the API
+	if runCommand(os.Args[1:]) {
+		return
+	}
+	
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
 		AppName: "Premier League Simulation",
@@ -43,6 +49,13 @@ func main() {
 	routes.SetupLeagueRoutes(app)
 	routes.SetupPredictionRoutes(app)
 	routes.SetupSystemRoutes(app)
+	routes.SetupCompetitionRoutes(app)
+	routes.SetupSeasonRoutes(app)
+	routes.SetupCupRoutes(app)
+	routes.SetupTournamentRoutes(app)
+	routes.SetupPlayerRoutes(app)
+	routes.SetupLiveRoutes(app)
+	routes.SetupScenarioRoutes(app)
 	
 	// Add a simple health check route
 	app.Get("/health", func(c *fiber.Ctx) error {
@@ -0,0 +1,34 @@
+package models
+
+// Reasons a player or team is without someone
+const (
+	AbsenceInjury     = "injury"
+	AbsenceSuspension = "suspension"
+)
+
+// Absence is an injury or suspension that keeps a player out of a team's
+// matches from FromWeek to UntilWeek of a season. Teams without a squad get
+// absences without a player. StrengthLoss is the share of the team's attack
+// and defence ratings it costs while it lasts.
+type Absence struct {
+	ID           int     `json:"id"`
+	SeasonID     int     `json:"season_id"`
+	TeamID       int     `json:"team_id"`
+	Player       *Player `json:"player,omitempty"`
+	MatchID      int     `json:"match_id"`
+	Reason       string  `json:"reason"`
+	Detail       string  `json:"detail"`
+	FromWeek     int     `json:"from_week"`
+	UntilWeek    int     `json:"until_week"`
+	StrengthLoss float64 `json:"strength_loss"`
+}
+
+// TeamAvailability lists who a team is without in a week of a season and how
+// much weaker that makes it
+type TeamAvailability struct {
+	Team         Team      `json:"team"`
+	SeasonID     int       `json:"season_id"`
+	Week         int       `json:"week"`
+	StrengthLoss float64   `json:"strength_loss"`
+	Unavailable  []Absence `json:"unavailable"`
+}
@@ -0,0 +1,53 @@
+package models
+
+// BacktestProbability is the title probability the prediction model gave a
+// team at one point of a replayed season, from 0 to 1
+type BacktestProbability struct {
+	Team        Team    `json:"team"`
+	Probability float64 `json:"probability"`
+	Champion    bool    `json:"champion"`
+}
+
+// BacktestWeek is the forecast made after a week of a replayed season, week 0
+// being before a ball was kicked, and how well it did
+type BacktestWeek struct {
+	Week          int                   `json:"week"`
+	BrierScore    float64               `json:"brier_score"`
+	LogLoss       float64               `json:"log_loss"`
+	Probabilities []BacktestProbability `json:"probabilities"`
+}
+
+// BacktestSeason is a completed season replayed week by week. ModelVersion
+// names the prediction model with the match engine of the season's competition.
+type BacktestSeason struct {
+	Season       Season         `json:"season"`
+	ModelVersion string         `json:"model_version"`
+	Champion     Team           `json:"champion"`
+	BrierScore   float64        `json:"brier_score"`
+	LogLoss      float64        `json:"log_loss"`
+	Weeks        []BacktestWeek `json:"weeks"`
+}
+
+// CalibrationBin groups the forecasts whose probability fell in a range and
+// compares how often they came true with how likely they were said to be
+type CalibrationBin struct {
+	Lower             float64 `json:"lower"`
+	Upper             float64 `json:"upper"`
+	Forecasts         int     `json:"forecasts"`
+	MeanProbability   float64 `json:"mean_probability"`
+	ObservedFrequency float64 `json:"observed_frequency"`
+}
+
+// BacktestReport scores the prediction model against completed seasons. The
+// Brier score and log loss are averaged over the forecasts, one per season
+// and week; lower is better for both.
+type BacktestReport struct {
+	ModelVersion string           `json:"model_version"`
+	Simulations  int              `json:"simulations"`
+	Seed         int64            `json:"seed"`
+	Forecasts    int              `json:"forecasts"`
+	BrierScore   float64          `json:"brier_score"`
+	LogLoss      float64          `json:"log_loss"`
+	Calibration  []CalibrationBin `json:"calibration"`
+	Seasons      []BacktestSeason `json:"seasons"`
+}
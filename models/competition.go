@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Competition formats
+const (
+	FormatLeague     = "league"
+	FormatCup        = "cup"
+	FormatTournament = "tournament"
+)
+
+// Competition represents a tournament the teams play in, together with the
+// rules used to rank its table. League competitions are divisions of a
+// pyramid: Tier 1 is the top division, and the places decide how many teams
+// move between adjacent tiers when a new season starts. Cup competitions are
+// knockouts with single or two-legged ties and have no tier. Tournaments play
+// GroupCount groups first and send the top GroupQualifiers teams of each group
+// into a knockout played like a cup.
+type Competition struct {
+	ID               int       `json:"id"`
+	Name             string    `json:"name"`
+	TieBreakers      []string  `json:"tie_breakers"`
+	LotsSeed         int64     `json:"lots_seed"`
+	Format           string    `json:"format"`
+	Tier             int       `json:"tier,omitempty"`
+	PromotionPlaces  int       `json:"promotion_places"`
+	RelegationPlaces int       `json:"relegation_places"`
+	PlayoffPlaces    int       `json:"playoff_places"`
+	TwoLegged        bool      `json:"two_legged"`
+	AwayGoalsRule    bool      `json:"away_goals_rule"`
+	GroupCount       int       `json:"group_count,omitempty"`
+	GroupQualifiers  int       `json:"group_qualifiers,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+
+	// MatchEngine names the engine the matches are simulated with, with the
+	// parameters it takes; empty uses MATCH_ENGINE
+	MatchEngine  string             `json:"match_engine,omitempty"`
+	EngineParams map[string]float64 `json:"engine_params,omitempty"`
+}
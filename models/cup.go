@@ -0,0 +1,40 @@
+package models
+
+// How a cup tie was decided
+const (
+	DecidedByBye       = "bye"
+	DecidedByScore     = "score"
+	DecidedByAwayGoals = "away_goals"
+	DecidedByExtraTime = "extra_time"
+	DecidedByPenalties = "penalties"
+)
+
+// CupTie is one pairing of a knockout round. AwayTeam is nil when the home
+// team was drawn a bye. The aggregate includes extra time but not penalties.
+type CupTie struct {
+	ID            int     `json:"id"`
+	Round         int     `json:"round"`
+	Position      int     `json:"position"`
+	HomeTeam      Team    `json:"home_team"`
+	AwayTeam      *Team   `json:"away_team"`
+	Legs          []Match `json:"legs"`
+	HomeAggregate int     `json:"home_aggregate"`
+	AwayAggregate int     `json:"away_aggregate"`
+	Winner        *Team   `json:"winner"`
+	DecidedBy     string  `json:"decided_by,omitempty"`
+}
+
+// CupRound is one round of a cup bracket
+type CupRound struct {
+	Round int      `json:"round"`
+	Name  string   `json:"name"`
+	Ties  []CupTie `json:"ties"`
+}
+
+// CupBracket is every round drawn so far in one edition of a cup
+type CupBracket struct {
+	Competition Competition `json:"competition"`
+	Season      Season      `json:"season"`
+	Rounds      []CupRound  `json:"rounds"`
+	Champion    *Team       `json:"champion"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// MatchEngineInfo describes a match engine competitions can pick. Default is
+// set on the engine of competitions that do not pick one.
+type MatchEngineInfo struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Default     bool          `json:"default"`
+	Params      []EngineParam `json:"params"`
+}
+
+// EngineParam is a parameter a match engine takes, with its default and the
+// range of values it accepts
+type EngineParam struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Default     float64 `json:"default"`
+	Min         float64 `json:"min"`
+	Max         float64 `json:"max"`
+}
@@ -0,0 +1,29 @@
+package models
+
+// Types of the updates pushed to the watchers of a live week
+const (
+	LiveKickOff  = "kick_off"
+	LiveEvent    = "event"
+	LiveFinished = "finished"
+)
+
+// LiveScore is the score of one match of a live week at the current minute
+type LiveScore struct {
+	MatchID   int  `json:"match_id"`
+	HomeTeam  Team `json:"home_team"`
+	AwayTeam  Team `json:"away_team"`
+	HomeScore int  `json:"home_score"`
+	AwayScore int  `json:"away_score"`
+	Finished  bool `json:"finished"`
+}
+
+// LiveUpdate is one message of a live week's stream. Every update carries the
+// scores of all matches; the provisional league table is sent at kick-off,
+// after every goal and at the end.
+type LiveUpdate struct {
+	Type   string      `json:"type"`
+	Minute int         `json:"minute"`
+	Event  *MatchEvent `json:"event,omitempty"`
+	Scores []LiveScore `json:"scores"`
+	Table  []TeamStats `json:"table,omitempty"`
+}
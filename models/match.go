@@ -4,14 +4,20 @@ import "time"
 
 // Match represents a match between two teams
 type Match struct {
-	ID          int       `json:"id"`
-	HomeTeamID  int       `json:"home_team_id"`
-	AwayTeamID  int       `json:"away_team_id"`
-	HomeTeam    Team      `json:"home_team"`
-	AwayTeam    Team      `json:"away_team"`
-	HomeScore   *int      `json:"home_score"`
-	AwayScore   *int      `json:"away_score"`
-	Week        int       `json:"week"`
-	Played      bool      `json:"played"`
-	CreatedAt   time.Time `json:"created_at"`
-} 
\ No newline at end of file
+	ID            int       `json:"id"`
+	SeasonID      int       `json:"season_id"`
+	HomeTeamID    int       `json:"home_team_id"`
+	AwayTeamID    int       `json:"away_team_id"`
+	HomeTeam      Team      `json:"home_team"`
+	AwayTeam      Team      `json:"away_team"`
+	HomeScore     *int      `json:"home_score"`
+	AwayScore     *int      `json:"away_score"`
+	Week          int       `json:"week"`
+	Played        bool      `json:"played"`
+	Seed          *int64    `json:"seed,omitempty"`
+	Engine        *string   `json:"engine,omitempty"`
+	ExtraTime     bool      `json:"extra_time,omitempty"`
+	HomePenalties *int      `json:"home_penalties,omitempty"`
+	AwayPenalties *int      `json:"away_penalties,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+// Types of the events in a match timeline
+const (
+	EventGoal            = "goal"
+	EventYellowCard      = "yellow_card"
+	EventRedCard         = "red_card"
+	EventSubstitution    = "substitution"
+	EventHalfTime        = "half_time"
+	EventFullTime        = "full_time"
+	EventExtraTime       = "extra_time"
+	EventPenaltyShootout = "penalty_shootout"
+)
+
+// MatchEvent is one moment of a simulated match, in the order it happened.
+// PlayerID is the scorer, the player booked or the player coming off;
+// RelatedPlayerID is the assister of a goal or the player coming on.
+// HomeScore and AwayScore are the score after the event, except for the
+// penalty shootout, where they are the penalties scored.
+type MatchEvent struct {
+	ID                int    `json:"id"`
+	MatchID           int    `json:"match_id"`
+	Sequence          int    `json:"sequence"`
+	Minute            int    `json:"minute"`
+	Type              string `json:"type"`
+	TeamID            *int   `json:"team_id,omitempty"`
+	PlayerID          *int   `json:"player_id,omitempty"`
+	PlayerName        string `json:"player_name,omitempty"`
+	RelatedPlayerID   *int   `json:"related_player_id,omitempty"`
+	RelatedPlayerName string `json:"related_player_name,omitempty"`
+	HomeScore         int    `json:"home_score"`
+	AwayScore         int    `json:"away_score"`
+}
@@ -0,0 +1,30 @@
+package models
+
+// Player positions
+const (
+	PositionGoalkeeper = "GK"
+	PositionDefender   = "DEF"
+	PositionMidfielder = "MID"
+	PositionForward    = "FWD"
+)
+
+// Player is a member of a team's squad. Rating works like the team ratings,
+// with 1.00 as average; better-rated players score and assist more often.
+type Player struct {
+	ID          int     `json:"id"`
+	TeamID      int     `json:"team_id"`
+	Name        string  `json:"name"`
+	Position    string  `json:"position"`
+	Rating      float64 `json:"rating"`
+	ShirtNumber int     `json:"shirt_number"`
+}
+
+// PlayerTotal is a player's goals and assists over a season, ranked for the
+// top scorer and top assist lists
+type PlayerTotal struct {
+	Rank    int    `json:"rank"`
+	Player  Player `json:"player"`
+	Team    Team   `json:"team"`
+	Goals   int    `json:"goals"`
+	Assists int    `json:"assists"`
+}
@@ -5,10 +5,56 @@ import "time"
 // Prediction represents a prediction for a team's final position
 type Prediction struct {
 	ID                 int       `json:"id"`
+	SeasonID           int       `json:"season_id"`
+	RunID              int       `json:"run_id,omitempty"`
 	TeamID             int       `json:"team_id"`
 	Team               Team      `json:"team"`
 	PredictedPosition  int       `json:"predicted_position"`
 	PredictedPoints    int       `json:"predicted_points"`
 	PredictionPercentage float64   `json:"prediction_percentage"`
+	ExpectedPoints     float64   `json:"expected_points"`
+	PointsLower        int       `json:"points_lower"`
+	PointsUpper        int       `json:"points_upper"`
+	Positions          []PositionProbability `json:"positions,omitempty"`
+	Outlook            *TeamOutlook `json:"outlook,omitempty"`
 	CreatedAt          time.Time `json:"created_at"`
-} 
\ No newline at end of file
+}
+
+// PositionProbability is the chance of a team finishing in a given position
+type PositionProbability struct {
+	Position    int     `json:"position"`
+	Probability float64 `json:"probability"`
+} 
+
+// PredictionRun is one run of the prediction model on a season: the week it
+// was made after and the model that made it
+type PredictionRun struct {
+	ID           int       `json:"id"`
+	SeasonID     int       `json:"season_id"`
+	Week         int       `json:"week"`
+	ModelVersion string    `json:"model_version"`
+	Simulations  int       `json:"simulations"`
+	Seed         int64     `json:"seed"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// WeekProbability is a team's title probability as predicted after a week
+type WeekProbability struct {
+	Week        int     `json:"week"`
+	RunID       int     `json:"run_id"`
+	Probability float64 `json:"probability"`
+}
+
+// TeamProbabilityHistory is a team's title probability week by week
+type TeamProbabilityHistory struct {
+	Team     Team              `json:"team"`
+	Timeline []WeekProbability `json:"timeline"`
+}
+
+// PredictionHistory is every prediction run of a season and the title race
+// they tell, one point per team and week from the last run made after it
+type PredictionHistory struct {
+	SeasonID int                      `json:"season_id"`
+	Runs     []PredictionRun          `json:"runs"`
+	Teams    []TeamProbabilityHistory `json:"teams"`
+}
@@ -0,0 +1,34 @@
+package models
+
+// RatingPoint is a team's Elo rating after one of its matches
+type RatingPoint struct {
+	MatchID      int     `json:"match_id"`
+	SeasonID     int     `json:"season_id"`
+	Week         int     `json:"week"`
+	Opponent     Team    `json:"opponent"`
+	Home         bool    `json:"home"`
+	GoalsFor     int     `json:"goals_for"`
+	GoalsAgainst int     `json:"goals_against"`
+	RatingBefore float64 `json:"rating_before"`
+	Rating       float64 `json:"rating"`
+	Change       float64 `json:"change"`
+}
+
+// TeamRatingCurve is a team's current Elo rating with its rating after every match
+type TeamRatingCurve struct {
+	Team    Team          `json:"team"`
+	Rating  float64       `json:"rating"`
+	History []RatingPoint `json:"history"`
+}
+
+// PowerRanking ranks a team of a season by Elo rating instead of points.
+// PositionDifference is the number of places the team stands higher in the
+// power ranking than in the league table.
+type PowerRanking struct {
+	Rank               int     `json:"rank"`
+	Team               Team    `json:"team"`
+	Rating             float64 `json:"rating"`
+	TablePosition      int     `json:"table_position"`
+	Points             int     `json:"points"`
+	PositionDifference int     `json:"position_difference"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// ScenarioResult is a hypothetical result a scenario fixes for one match of
+// its season, in place of the real one or of the match being simulated
+type ScenarioResult struct {
+	MatchID   int  `json:"match_id"`
+	Week      int  `json:"week"`
+	HomeTeam  Team `json:"home_team"`
+	AwayTeam  Team `json:"away_team"`
+	HomeScore int  `json:"home_score"`
+	AwayScore int  `json:"away_score"`
+	// Played is set when the match has really been played
+	Played bool `json:"played"`
+}
+
+// Scenario is a saved what-if: a set of hypothetical results laid over a
+// season, and the seed its predictions are simulated with
+type Scenario struct {
+	ID        int              `json:"id"`
+	SeasonID  int              `json:"season_id"`
+	Name      string           `json:"name"`
+	Seed      int64            `json:"seed"`
+	Results   []ScenarioResult `json:"results"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// ScenarioProjection is a scenario worked out on its season as it stands: the
+// table with the hypothetical results in and the predictions for the rest
+type ScenarioProjection struct {
+	Scenario    Scenario     `json:"scenario"`
+	Table       []TeamStats  `json:"table"`
+	Predictions []Prediction `json:"predictions"`
+}
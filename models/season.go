@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Season statuses; only the active season of a competition can change
+const (
+	SeasonActive   = "active"
+	SeasonArchived = "archived"
+)
+
+// Season represents one edition of a competition
+type Season struct {
+	ID            int        `json:"id"`
+	CompetitionID int        `json:"competition_id"`
+	Name          string     `json:"name"`
+	Status        string     `json:"status"`
+	StartedAt     time.Time  `json:"started_at"`
+	ArchivedAt    *time.Time `json:"archived_at,omitempty"`
+}
+
+// TeamSeasonRecord is a team's league table row and finishing position in one season
+type TeamSeasonRecord struct {
+	Season Season    `json:"season"`
+	Stats  TeamStats `json:"stats"`
+}
+
+// Movements of a team between divisions at season rollover
+const (
+	MovementPromoted  = "promoted"
+	MovementRelegated = "relegated"
+)
+
+// TeamMovement records a team moving to another division for a new season
+type TeamMovement struct {
+	Team              Team   `json:"team"`
+	FromCompetitionID int    `json:"from_competition_id"`
+	ToCompetitionID   int    `json:"to_competition_id"`
+	FromSeasonID      int    `json:"from_season_id"`
+	ToSeasonID        int    `json:"to_season_id"`
+	Movement          string `json:"movement"`
+	ViaPlayoff        bool   `json:"via_playoff"`
+}
+
+// PlayoffMatch is one match of a promotion playoff
+type PlayoffMatch struct {
+	CompetitionID int    `json:"competition_id"`
+	Round         int    `json:"round"`
+	HomeTeam      Team   `json:"home_team"`
+	AwayTeam      Team   `json:"away_team"`
+	HomeScore     int    `json:"home_score"`
+	AwayScore     int    `json:"away_score"`
+	WinnerID      int    `json:"winner_id"`
+	Engine        string `json:"engine"`
+}
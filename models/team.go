@@ -2,12 +2,18 @@ package models
 
 // Team represents a football team in the Premier League
 type Team struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID            int     `json:"id"`
+	Name          string  `json:"name"`
+	ShortCode     string  `json:"short_code,omitempty"`
+	Country       string  `json:"country,omitempty"`
+	AttackRating  float64 `json:"attack_rating,omitempty"`
+	DefenceRating float64 `json:"defence_rating,omitempty"`
+	EloRating     float64 `json:"elo_rating,omitempty"`
 }
 
 // TeamStats represents a team with its statistics for the league table
 type TeamStats struct {
+	Position      int  `json:"position,omitempty"`
 	Team          Team `json:"team"`
 	Points        int  `json:"points"`
 	Played        int  `json:"played"`
@@ -17,4 +23,49 @@ type TeamStats struct {
 	GoalsFor      int  `json:"goals_for"`
 	GoalsAgainst  int  `json:"goals_against"`
 	GoalDifference int  `json:"goal_difference"`
-} 
\ No newline at end of file
+	// PositionChange is the number of places gained since the previous week
+	PositionChange *int `json:"position_change,omitempty"`
+	// Form lists the team's last results in the season, oldest first, as W, D and L
+	Form string `json:"form,omitempty"`
+	// TieBreak explains why the team is ranked above the next team when both are level on points
+	TieBreak *TieBreak `json:"tie_break,omitempty"`
+	// Outlook is what the team can still achieve with the matches left to play
+	Outlook *TeamOutlook `json:"outlook,omitempty"`
+}
+
+// TeamOutlook is the best and worst position a team can still finish in over
+// every possible result of the remaining matches, on points. MagicNumber is
+// the number of points the team must still take to be sure of the title,
+// whatever else happens; it is missing when even winning every match would
+// not be enough. Top, ClinchedTop and EliminatedFromTop answer the same
+// questions for a top-N finish when one is asked for. Undetermined is set when
+// the positions could not be worked out within the search limit; only the
+// magic number, and a title it shows is won, are given then.
+type TeamOutlook struct {
+	BestPosition        int  `json:"best_position"`
+	WorstPosition       int  `json:"worst_position"`
+	ClinchedTitle       bool `json:"clinched_title"`
+	EliminatedFromTitle bool `json:"eliminated_from_title"`
+	MagicNumber         *int `json:"magic_number"`
+	Top                 int  `json:"top,omitempty"`
+	ClinchedTop         bool `json:"clinched_top,omitempty"`
+	EliminatedFromTop   bool `json:"eliminated_from_top,omitempty"`
+	Undetermined        bool `json:"undetermined,omitempty"`
+}
+
+// TieBreak names the rule that separated a team from the team ranked directly below it
+type TieBreak struct {
+	Rule       string `json:"rule"`
+	NextTeamID int    `json:"next_team_id"`
+	// Seed is set when the teams had to be separated by drawing lots
+	Seed *int64 `json:"seed,omitempty"`
+} 
+
+// LeagueTableDrift describes a team whose stored league table row does not
+// match the figures computed from its played matches
+type LeagueTableDrift struct {
+	Team     Team      `json:"team"`
+	Missing  bool      `json:"missing"`
+	Stored   TeamStats `json:"stored"`
+	Expected TeamStats `json:"expected"`
+}
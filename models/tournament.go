@@ -0,0 +1,18 @@
+package models
+
+// TournamentGroup is one group of a tournament's group stage, ranked with the
+// competition's tie-break chain
+type TournamentGroup struct {
+	Name      string      `json:"name"`
+	Standings []TeamStats `json:"standings"`
+}
+
+// Tournament is one edition of a group stage plus knockout competition.
+// Knockout is empty until the group stage is over and the bracket is drawn.
+type Tournament struct {
+	Competition Competition       `json:"competition"`
+	Season      Season            `json:"season"`
+	Groups      []TournamentGroup `json:"groups"`
+	Knockout    []CupRound        `json:"knockout"`
+	Champion    *Team             `json:"champion"`
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/controllers"
+)
+
+// SetupCompetitionRoutes sets up all routes for competitions
+func SetupCompetitionRoutes(app *fiber.App) {
+	api := app.Group("/api")
+	competitions := api.Group("/competitions")
+	
+	competitions.Get("/", controllers.GetAllCompetitions)
+	competitions.Get("/engines", controllers.GetMatchEngines)
+	competitions.Get("/:id", controllers.GetCompetitionByID)
+	competitions.Post("/", controllers.CreateCompetition)
+	competitions.Put("/:id", controllers.UpdateCompetition)
+	competitions.Put("/:id/tie-breakers", controllers.UpdateTieBreakers)
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/controllers"
+)
+
+// SetupCupRoutes sets up all routes for knockout cups.
+// They work on the cup's active edition; past editions are read through the season routes.
+func SetupCupRoutes(app *fiber.App) {
+	api := app.Group("/api")
+	cups := api.Group("/cups")
+
+	cups.Get("/:id/bracket", controllers.GetCupBracket)
+	cups.Post("/:id/draw", controllers.DrawCupRound)
+	cups.Post("/:id/simulate", controllers.SimulateCupRound)
+}
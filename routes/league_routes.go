@@ -12,4 +12,5 @@ func SetupLeagueRoutes(app *fiber.App) {
 	
 	league.Get("/table", controllers.GetLeagueTable)
 	league.Get("/table/week/:week", controllers.GetLeagueTableForWeek)
+	league.Get("/power-rankings", controllers.GetPowerRankings)
 } 
\ No newline at end of file
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/controllers"
+)
+
+// SetupLiveRoutes sets up the routes for following weeks played live.
+// They stream server-sent events; a week is played live with ?live=true on the simulate routes.
+func SetupLiveRoutes(app *fiber.App) {
+	api := app.Group("/api")
+	live := api.Group("/live")
+
+	live.Get("/weeks/:week", controllers.WatchLiveWeek)
+}
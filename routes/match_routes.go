@@ -14,4 +14,6 @@ func SetupMatchRoutes(app *fiber.App) {
 	matches.Get("/week/:week", controllers.GetMatchesByWeek)
 	matches.Post("/simulate/:week", controllers.SimulateWeek)
 	matches.Post("/simulate-all", controllers.SimulateAllRemainingMatches)
+	matches.Get("/:id/events", controllers.GetMatchEvents)
+	matches.Put("/:id", controllers.UpdateMatchResult)
 } 
\ No newline at end of file
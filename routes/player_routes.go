@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/controllers"
+)
+
+// SetupPlayerRoutes sets up all routes for players.
+// Squads are listed and extended through the team routes.
+func SetupPlayerRoutes(app *fiber.App) {
+	api := app.Group("/api")
+	players := api.Group("/players")
+
+	players.Get("/top-scorers", controllers.GetTopScorers)
+	players.Get("/top-assists", controllers.GetTopAssists)
+	players.Put("/:id", controllers.UpdatePlayer)
+	players.Delete("/:id", controllers.DeletePlayer)
+}
@@ -11,4 +11,8 @@ func SetupPredictionRoutes(app *fiber.App) {
 	predictions := api.Group("/predictions")
 	
 	predictions.Get("/", controllers.GetPredictions)
+	predictions.Get("/positions", controllers.GetPositionProbabilities)
+	predictions.Get("/history", controllers.GetPredictionHistory)
+	predictions.Get("/backtest", controllers.GetBacktest)
+	predictions.Post("/generate", controllers.GenerateChampionshipProbabilities)
 } 
\ No newline at end of file
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/controllers"
+)
+
+// SetupScenarioRoutes sets up all routes for what-if scenarios.
+// Scenarios are created on the current season here, or on any league season through the season routes.
+func SetupScenarioRoutes(app *fiber.App) {
+	api := app.Group("/api")
+	scenarios := api.Group("/scenarios")
+
+	scenarios.Get("/", controllers.GetScenarios)
+	scenarios.Post("/", controllers.CreateScenario)
+	scenarios.Get("/:id", controllers.GetScenario)
+	scenarios.Delete("/:id", controllers.DeleteScenario)
+}
@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/controllers"
+)
+
+// SetupSeasonRoutes sets up all routes for seasons.
+// The simulation routes refuse archived seasons, so those stay read-only.
+func SetupSeasonRoutes(app *fiber.App) {
+	api := app.Group("/api")
+	seasons := api.Group("/seasons")
+	
+	seasons.Get("/", controllers.GetAllSeasons)
+	seasons.Post("/", controllers.StartNewSeason)
+	seasons.Get("/:seasonId", controllers.GetSeasonByID)
+	seasons.Get("/:seasonId/movements", controllers.GetSeasonMovements)
+	seasons.Get("/:seasonId/bracket", controllers.GetCupBracket)
+	seasons.Get("/:seasonId/tournament", controllers.GetTournament)
+	seasons.Get("/:seasonId/live/:week", controllers.WatchLiveWeek)
+	seasons.Get("/:seasonId/matches", controllers.GetAllMatches)
+	seasons.Get("/:seasonId/matches/week/:week", controllers.GetMatchesByWeek)
+	seasons.Post("/:seasonId/matches/simulate/:week", controllers.SimulateWeek)
+	seasons.Post("/:seasonId/matches/simulate-all", controllers.SimulateAllRemainingMatches)
+	seasons.Get("/:seasonId/table", controllers.GetLeagueTable)
+	seasons.Get("/:seasonId/table/week/:week", controllers.GetLeagueTableForWeek)
+	seasons.Get("/:seasonId/power-rankings", controllers.GetPowerRankings)
+	seasons.Get("/:seasonId/top-scorers", controllers.GetTopScorers)
+	seasons.Get("/:seasonId/top-assists", controllers.GetTopAssists)
+	seasons.Get("/:seasonId/teams/:id/unavailable", controllers.GetTeamAvailability)
+	seasons.Get("/:seasonId/predictions", controllers.GetPredictions)
+	seasons.Get("/:seasonId/predictions/positions", controllers.GetPositionProbabilities)
+	seasons.Get("/:seasonId/predictions/history", controllers.GetPredictionHistory)
+	seasons.Get("/:seasonId/predictions/backtest", controllers.GetBacktest)
+	seasons.Post("/:seasonId/predictions/generate", controllers.GenerateChampionshipProbabilities)
+	seasons.Get("/:seasonId/scenarios", controllers.GetScenarios)
+	seasons.Post("/:seasonId/scenarios", controllers.CreateScenario)
+}
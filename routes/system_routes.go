@@ -11,4 +11,6 @@ func SetupSystemRoutes(app *fiber.App) {
 	system := api.Group("/system")
 	
 	system.Post("/reset", controllers.ResetSystem)
+	system.Get("/league-table/check", controllers.CheckLeagueTable)
+	system.Post("/league-table/repair", controllers.RepairLeagueTable)
 } 
\ No newline at end of file
@@ -12,4 +12,12 @@ func SetupTeamRoutes(app *fiber.App) {
 	
 	teams.Get("/", controllers.GetAllTeams)
 	teams.Get("/:id", controllers.GetTeamByID)
+	teams.Get("/:id/seasons", controllers.GetTeamSeasons)
+	teams.Get("/:id/players", controllers.GetTeamPlayers)
+	teams.Get("/:id/ratings", controllers.GetTeamRatings)
+	teams.Get("/:id/unavailable", controllers.GetTeamAvailability)
+	teams.Post("/:id/players", controllers.CreatePlayer)
+	teams.Post("/", controllers.CreateTeam)
+	teams.Put("/:id", controllers.UpdateTeam)
+	teams.Delete("/:id", controllers.DeleteTeam)
 } 
\ No newline at end of file
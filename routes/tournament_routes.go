@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sametyildirim314/insider_case/controllers"
+)
+
+// SetupTournamentRoutes sets up all routes for group stage plus knockout tournaments.
+// They work on the tournament's active edition; past editions are read through the season routes.
+func SetupTournamentRoutes(app *fiber.App) {
+	api := app.Group("/api")
+	tournaments := api.Group("/tournaments")
+
+	tournaments.Get("/:id", controllers.GetTournament)
+	tournaments.Post("/:id/draw", controllers.DrawTournament)
+	tournaments.Post("/:id/simulate", controllers.SimulateTournament)
+}